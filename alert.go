@@ -0,0 +1,331 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAlertQueueFull 待发送的告警已满，告警被丢弃
+var ErrAlertQueueFull = errors.New("h2sanlog: alert queue full, drop")
+
+// AlertConfig 告警 sink 配置，WebhookURL 和 SentryDSN 至少设置一个，都设置时同时发送
+type AlertConfig struct {
+	// MinLevel 发送的最低级别，默认 LogLevelError
+	MinLevel uint8
+	// WebhookURL 通用 webhook 地址，请求体为 JSONEncoder 编码的日志，附带 suppressed 字段
+	WebhookURL string
+	// Headers webhook 请求附带的头
+	Headers map[string]string
+	// SentryDSN Sentry 项目的 DSN，如 https://<key>@o0.ingest.sentry.io/<project>
+	SentryDSN string
+	// Environment Sentry 事件的 environment
+	Environment string
+	// DedupWindow 同一 logger 名+消息在窗口内只发送一次，期间被抑制的条数随下一次告警发送，默认 5 分钟
+	DedupWindow time.Duration
+	// PerMinute 每分钟最多发送的告警数，默认 10
+	PerMinute int
+	// QueueSize 待发送告警的上限，默认 256
+	QueueSize int
+	// Timeout 单个请求的超时，默认 10 秒
+	Timeout time.Duration
+	// Client 发送请求的 http.Client，默认 http.DefaultClient
+	Client *http.Client
+	// OnError 发送失败回调，默认输出到 stderr
+	OnError func(error)
+}
+
+// AlertSink 把高级别日志（附带字段和调用栈）转发到 Sentry 或 webhook，按消息去重并限制发送频率，
+// 实现 EntryWriter，一般通过 MultiWriter 或 Router 与文件 sink 同时使用
+type AlertSink struct {
+	cfg    AlertConfig
+	dedup  *RateLimiter
+	limit  *RateLimiter
+	sentry *sentryTarget
+	jobs   chan alertJob
+	done   chan struct{}
+	stop   chan struct{}
+	once   sync.Once
+}
+
+type alertJob struct {
+	url     string
+	headers map[string]string
+	body    []byte
+}
+
+// sentryTarget 由 DSN 解析出的 envelope 接口地址和鉴权头
+type sentryTarget struct {
+	dsn  string
+	url  string
+	auth string
+}
+
+// NewAlertSink 新建告警 sink 并启动发送goroutine
+func NewAlertSink(cfg AlertConfig) (*AlertSink, error) {
+	if cfg.WebhookURL == "" && cfg.SentryDSN == "" {
+		return nil, errors.New("h2sanlog: alert sink needs WebhookURL or SentryDSN")
+	}
+	if cfg.MinLevel == LogLevelNull {
+		cfg.MinLevel = LogLevelError
+	}
+	if cfg.DedupWindow <= 0 {
+		cfg.DedupWindow = 5 * time.Minute
+	}
+	if cfg.PerMinute <= 0 {
+		cfg.PerMinute = 10
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 256
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = stderrError
+	}
+	s := &AlertSink{cfg: cfg, jobs: make(chan alertJob, cfg.QueueSize), done: make(chan struct{}), stop: make(chan struct{})}
+	if cfg.SentryDSN != "" {
+		t, err := parseSentryDSN(cfg.SentryDSN)
+		if err != nil {
+			return nil, err
+		}
+		s.sentry = t
+	}
+	s.dedup = NewRateLimiter(1/cfg.DedupWindow.Seconds(), 1, nil)
+	s.limit = NewRateLimiter(float64(cfg.PerMinute)/60, cfg.PerMinute, func(*Entry) string { return "" })
+	go s.run()
+	return s, nil
+}
+
+// parseSentryDSN 解析 https://<key>@<host>/<project>
+func parseSentryDSN(dsn string) (*sentryTarget, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("h2sanlog: bad sentry dsn: %w", err)
+	}
+	project := strings.TrimPrefix(u.Path, "/")
+	prefix := ""
+	if i := strings.LastIndexByte(project, '/'); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	if u.User == nil || u.User.Username() == "" || project == "" {
+		return nil, errors.New("h2sanlog: bad sentry dsn: want scheme://key@host/project")
+	}
+	return &sentryTarget{
+		dsn:  dsn,
+		url:  fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		auth: "Sentry sentry_version=7, sentry_client=h2sanlog/1.0, sentry_key=" + u.User.Username(),
+	}, nil
+}
+
+// WriteEntry 实现 EntryWriter，低于 MinLevel、重复或超过频率限制的日志直接忽略
+func (s *AlertSink) WriteEntry(e *Entry) error {
+	if e.Level < s.cfg.MinLevel {
+		return nil
+	}
+	ok, suppressed := s.dedup.Allow(e)
+	if !ok {
+		return nil
+	}
+	if ok, _ := s.limit.Allow(e); !ok {
+		return nil
+	}
+	var err error
+	if s.cfg.WebhookURL != "" {
+		err = s.webhook(e, suppressed)
+	}
+	if s.sentry != nil {
+		if e2 := s.sentryEvent(e, suppressed); e2 != nil && err == nil {
+			err = e2
+		}
+	}
+	return err
+}
+
+// Write 已编码的一行日志按行首的 [LEVEL] 标签识别级别，整行作为消息
+func (s *AlertSink) Write(p []byte) (int, error) {
+	level := sniffLevel(p)
+	if level < s.cfg.MinLevel {
+		return len(p), nil
+	}
+	e := &Entry{Time: time.Now(), Level: level, Message: string(bytes.TrimRight(p, "\n"))}
+	if err := s.WriteEntry(e); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *AlertSink) webhook(e *Entry, suppressed int) error {
+	c := *e
+	c.Fields = append(append([]Field(nil), e.Fields...), Field{Key: "suppressed", Value: suppressed})
+	body, err := JSONEncoder{}.Encode(&c)
+	if err != nil {
+		return err
+	}
+	h := map[string]string{"Content-Type": "application/json"}
+	for k, v := range s.cfg.Headers {
+		h[k] = v
+	}
+	return s.enqueue(alertJob{url: s.cfg.WebhookURL, headers: h, body: body})
+}
+
+func (s *AlertSink) sentryEvent(e *Entry, suppressed int) error {
+	var id [16]byte
+	rand.Read(id[:])
+	eventID := hex.EncodeToString(id[:])
+	extra := make(map[string]interface{}, len(e.Fields)+2)
+	for _, f := range e.Fields {
+		extra[f.Key] = sentryValue(f.Interface())
+	}
+	if suppressed > 0 {
+		extra["suppressed"] = suppressed
+	}
+	if e.Caller != "" {
+		extra["caller"] = e.Caller
+	}
+	if len(e.Stack) > 0 {
+		extra["stacktrace"] = string(e.Stack)
+	}
+	host, _ := os.Hostname()
+	event := map[string]interface{}{
+		"event_id":    eventID,
+		"timestamp":   e.Time.UTC().Format(time.RFC3339Nano),
+		"level":       sentryLevel(e.Level),
+		"logger":      e.Name,
+		"platform":    "go",
+		"server_name": host,
+		"message":     map[string]string{"formatted": e.Message},
+		"extra":       extra,
+	}
+	if s.cfg.Environment != "" {
+		event["environment"] = s.cfg.Environment
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	header, _ := json.Marshal(map[string]string{"event_id": eventID, "dsn": s.sentry.dsn})
+	b.Write(header)
+	b.WriteString("\n{\"type\":\"event\",\"length\":")
+	fmt.Fprint(&b, len(data))
+	b.WriteString("}\n")
+	b.Write(data)
+	b.WriteByte('\n')
+	h := map[string]string{"Content-Type": "application/x-sentry-envelope", "X-Sentry-Auth": s.sentry.auth}
+	return s.enqueue(alertJob{url: s.sentry.url, headers: h, body: b.Bytes()})
+}
+
+// sentryValue 不能直接编码为 JSON 的字段值转为字符串
+func sentryValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case error:
+		return t.Error()
+	case []byte:
+		return string(t)
+	case fmt.Stringer:
+		return t.String()
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+func sentryLevel(level uint8) string {
+	switch level {
+	case LogLevelFatal:
+		return "fatal"
+	case LogLevelError:
+		return "error"
+	case LogLevelWarning:
+		return "warning"
+	case LogLevelInfo:
+		return "info"
+	}
+	return "debug"
+}
+
+func (s *AlertSink) enqueue(j alertJob) error {
+	select {
+	case <-s.stop:
+		return ErrClosed
+	default:
+	}
+	select {
+	case s.jobs <- j:
+		return nil
+	default:
+		return ErrAlertQueueFull
+	}
+}
+
+// Close 发送剩余告警后停止，最多等待 SetExitTimeout 的设置
+func (s *AlertSink) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	t := time.NewTimer(exitTimeout)
+	defer t.Stop()
+	select {
+	case <-s.done:
+		return nil
+	case <-t.C:
+		return ErrFlushTimeout
+	}
+}
+
+func (s *AlertSink) run() {
+	defer close(s.done)
+	for {
+		select {
+		case j := <-s.jobs:
+			s.send(j)
+		case <-s.stop:
+			for {
+				select {
+				case j := <-s.jobs:
+					s.send(j)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *AlertSink) send(j alertJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.url, bytes.NewReader(j.body))
+	if err == nil {
+		for k, v := range j.headers {
+			req.Header.Set(k, v)
+		}
+		var resp *http.Response
+		if resp, err = s.cfg.Client.Do(req); err == nil {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+			}
+		}
+	}
+	if err != nil {
+		s.cfg.OnError(fmt.Errorf("send alert to %s fail:%w", j.url, err))
+	}
+}
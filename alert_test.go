@@ -0,0 +1,175 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// alertRequest 告警服务收到的一个请求
+type alertRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// newAlertServer 记录收到的请求，Close AlertSink 后读取
+func newAlertServer(t *testing.T) (*httptest.Server, func() []alertRequest) {
+	var mu sync.Mutex
+	var reqs []alertRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, alertRequest{path: r.URL.Path, header: r.Header, body: b})
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []alertRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]alertRequest(nil), reqs...)
+	}
+}
+
+func newTestAlertSink(t *testing.T, cfg AlertConfig) *AlertSink {
+	t.Helper()
+	s, err := NewAlertSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestAlertWebhookBody(t *testing.T) {
+	srv, got := newAlertServer(t)
+	s := newTestAlertSink(t, AlertConfig{WebhookURL: srv.URL + "/hook", Headers: map[string]string{"X-Token": "t"}})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.WriteEntry(&Entry{Time: now, Level: LogLevelInfo, Message: "ignored"})
+	s.WriteEntry(&Entry{Time: now, Level: LogLevelError, Name: "db", Message: "down", Fields: []Field{Int("port", 5432)}})
+	s.Close()
+	reqs := got()
+	if len(reqs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(reqs))
+	}
+	r := reqs[0]
+	if r.path != "/hook" || r.header.Get("X-Token") != "t" || r.header.Get("Content-Type") != "application/json" {
+		t.Fatalf("path %q headers %v", r.path, r.header)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatalf("body %q: %v", r.body, err)
+	}
+	want := map[string]interface{}{"level": "ERROR", "logger": "db", "msg": "down", "port": 5432.0, "suppressed": 0.0}
+	for k, v := range want {
+		if body[k] != v {
+			t.Fatalf("%s = %v, want %v (body %s)", k, body[k], v, r.body)
+		}
+	}
+}
+
+func TestAlertSentryEnvelope(t *testing.T) {
+	srv, got := newAlertServer(t)
+	dsn := strings.Replace(srv.URL, "http://", "http://pubkey@", 1) + "/sentry/42"
+	s := newTestAlertSink(t, AlertConfig{SentryDSN: dsn, Environment: "prod"})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.WriteEntry(&Entry{Time: now, Level: LogLevelFatal, Name: "api", Message: "panic", Caller: "main.go:9 main",
+		Stack: []byte("goroutine 1"), Fields: []Field{Err(errors.New("boom")), String("user", "u1")}})
+	s.Close()
+	reqs := got()
+	if len(reqs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(reqs))
+	}
+	r := reqs[0]
+	if r.path != "/sentry/api/42/envelope/" || r.header.Get("Content-Type") != "application/x-sentry-envelope" {
+		t.Fatalf("path %q content type %q", r.path, r.header.Get("Content-Type"))
+	}
+	if auth := r.header.Get("X-Sentry-Auth"); !strings.Contains(auth, "sentry_version=7") || !strings.Contains(auth, "sentry_key=pubkey") {
+		t.Fatalf("X-Sentry-Auth = %q", auth)
+	}
+	// envelope 头、item 头、事件各占一行
+	lines := bytes.Split(bytes.TrimSuffix(r.body, []byte("\n")), []byte("\n"))
+	if len(lines) != 3 {
+		t.Fatalf("envelope has %d lines: %q", len(lines), r.body)
+	}
+	var header map[string]string
+	var item struct {
+		Type   string `json:"type"`
+		Length int    `json:"length"`
+	}
+	var event struct {
+		EventID     string                 `json:"event_id"`
+		Timestamp   string                 `json:"timestamp"`
+		Level       string                 `json:"level"`
+		Logger      string                 `json:"logger"`
+		Environment string                 `json:"environment"`
+		Message     map[string]string      `json:"message"`
+		Extra       map[string]interface{} `json:"extra"`
+	}
+	for i, v := range []interface{}{&header, &item, &event} {
+		if err := json.Unmarshal(lines[i], v); err != nil {
+			t.Fatalf("line %d %q: %v", i, lines[i], err)
+		}
+	}
+	if header["dsn"] != dsn || len(header["event_id"]) != 32 || header["event_id"] != event.EventID {
+		t.Fatalf("envelope header = %v, event id %q", header, event.EventID)
+	}
+	if item.Type != "event" || item.Length != len(lines[2]) {
+		t.Fatalf("item header = %+v, event length %d", item, len(lines[2]))
+	}
+	if event.Level != "fatal" || event.Logger != "api" || event.Environment != "prod" ||
+		event.Message["formatted"] != "panic" || event.Timestamp != "2026-03-01T12:00:00Z" {
+		t.Fatalf("event = %+v", event)
+	}
+	extra := map[string]interface{}{"error": "boom", "user": "u1", "caller": "main.go:9 main", "stacktrace": "goroutine 1"}
+	for k, v := range extra {
+		if event.Extra[k] != v {
+			t.Fatalf("extra %s = %v, want %v", k, event.Extra[k], v)
+		}
+	}
+	if _, ok := event.Extra["suppressed"]; ok {
+		t.Fatal("suppressed sent without suppressed alerts")
+	}
+}
+
+// 窗口内重复的告警只发送一次，窗口过后发送时附带被抑制的条数；超过每分钟上限的告警丢弃
+func TestAlertDedupAndLimit(t *testing.T) {
+	srv, got := newAlertServer(t)
+	s := newTestAlertSink(t, AlertConfig{WebhookURL: srv.URL, DedupWindow: 10 * time.Second, PerMinute: 3})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		s.WriteEntry(&Entry{Time: now.Add(time.Duration(i) * time.Second), Level: LogLevelError, Message: "same"})
+	}
+	s.WriteEntry(&Entry{Time: now.Add(11 * time.Second), Level: LogLevelError, Message: "same"})
+	s.WriteEntry(&Entry{Time: now.Add(12 * time.Second), Level: LogLevelError, Message: "other"})
+	s.WriteEntry(&Entry{Time: now.Add(13 * time.Second), Level: LogLevelError, Message: "third"})
+	s.Close()
+	var sent []string
+	for _, r := range got() {
+		var body map[string]interface{}
+		json.Unmarshal(r.body, &body)
+		sent = append(sent, body["msg"].(string)+"/"+strconv.Itoa(int(body["suppressed"].(float64))))
+	}
+	if strings.Join(sent, ",") != "same/0,same/2,other/0" {
+		t.Fatalf("sent = %v", sent)
+	}
+}
+
+func TestAlertConfigErrors(t *testing.T) {
+	if _, err := NewAlertSink(AlertConfig{}); err == nil {
+		t.Fatal("want error without WebhookURL and SentryDSN")
+	}
+	for _, dsn := range []string{"https://o0.ingest.sentry.io/1", "https://key@o0.ingest.sentry.io/"} {
+		if _, err := NewAlertSink(AlertConfig{SentryDSN: dsn}); err == nil {
+			t.Fatalf("want error for dsn %q", dsn)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package h2sanlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrArchiveQueueFull 待上传队列已满
+var ErrArchiveQueueFull = errors.New("h2sanlog: archive queue full")
+
+// Uploader 上传一个已轮转的日志文件，由业务使用 S3/OSS 等对象存储的 SDK 实现
+type Uploader interface {
+	Upload(ctx context.Context, name string, r io.Reader, size int64) error
+}
+
+// ArchiverConfig 归档上传配置，避免白天高峰期批量上传昨天的日志打满网卡
+type ArchiverConfig struct {
+	// BytesPerSecond 所有并发上传共享的带宽上限，0 表示不限
+	BytesPerSecond int64
+	// Concurrency 同时上传的文件数，默认 1
+	Concurrency int
+	// Windows 允许上传的时间段，为空表示任何时间；只使用 Start/End/Days
+	Windows []ScheduleWindow
+	// Location 时间段使用的时区，默认本地时区
+	Location *time.Location
+	// RemoveAfterUpload 上传成功后删除本地文件
+	RemoveAfterUpload bool
+	// OnError 上传失败回调，默认输出到 stderr
+	OnError func(path string, err error)
+}
+
+// Archiver 按带宽、并发和时间段限制把日志文件上传到对象存储
+type Archiver struct {
+	uploader Uploader
+	cfg      ArchiverConfig
+	schedule *Schedule
+	limiter  *byteLimiter
+	paths    chan string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewArchiver 新建归档器并启动 Concurrency 个上传goroutine
+func NewArchiver(u Uploader, cfg ArchiverConfig) (*Archiver, error) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(path string, err error) {
+			stderrError(fmt.Errorf("archive file path:%s fail:%w", path, err))
+		}
+	}
+	a := &Archiver{uploader: u, cfg: cfg, paths: make(chan string, 1024)}
+	if len(cfg.Windows) > 0 {
+		s, err := NewSchedule(cfg.Windows, cfg.Location)
+		if err != nil {
+			return nil, err
+		}
+		a.schedule = s
+	}
+	if cfg.BytesPerSecond > 0 {
+		a.limiter = newByteLimiter(cfg.BytesPerSecond)
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	for i := 0; i < cfg.Concurrency; i++ {
+		a.wg.Add(1)
+		go a.work()
+	}
+	return a, nil
+}
+
+// Enqueue 加入待上传文件，队列满返回 ErrArchiveQueueFull
+func (a *Archiver) Enqueue(path string) error {
+	select {
+	case a.paths <- path:
+		return nil
+	default:
+		return ErrArchiveQueueFull
+	}
+}
+
+// EnqueueRotated 把 w 已轮转的文件（不含当前正在写的文件）全部加入待上传
+func (a *Archiver) EnqueueRotated(w *FileWriter) error {
+	w.lock()
+	active := filepath.Clean(w.filePath)
+	w.mu.Unlock()
+	for _, f := range w.logFiles() {
+		if f.path == active {
+			continue
+		}
+		if err := a.Enqueue(f.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 停止上传，正在上传的文件会被取消
+func (a *Archiver) Close() error {
+	a.cancel()
+	a.wg.Wait()
+	return nil
+}
+
+func (a *Archiver) work() {
+	defer a.wg.Done()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case path := <-a.paths:
+			if !a.waitWindow() {
+				return
+			}
+			if err := a.upload(path); err != nil && a.ctx.Err() == nil {
+				a.cfg.OnError(path, err)
+			}
+		}
+	}
+}
+
+// waitWindow 等待进入允许上传的时间段，归档器关闭返回false
+func (a *Archiver) waitWindow() bool {
+	for a.schedule != nil && a.schedule.active(time.Now()) == nil {
+		select {
+		case <-a.ctx.Done():
+			return false
+		case <-time.After(30 * time.Second):
+		}
+	}
+	return a.ctx.Err() == nil
+}
+
+func (a *Archiver) upload(path string) error {
+	f, err := openShared(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var r io.Reader = f
+	if a.limiter != nil {
+		r = &throttledReader{ctx: a.ctx, r: f, l: a.limiter}
+	}
+	if err := a.uploader.Upload(a.ctx, filepath.Base(path), r, fi.Size()); err != nil {
+		return err
+	}
+	if a.cfg.RemoveAfterUpload {
+		f.Close()
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// byteLimiter 按字节的令牌桶，容量为一秒的带宽
+type byteLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newByteLimiter(bytesPerSecond int64) *byteLimiter {
+	return &byteLimiter{rate: float64(bytesPerSecond), last: time.Now()}
+}
+
+// reserve 预占 n 个字节的额度，返回需要等待的时长
+func (l *byteLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// throttledReader 按 byteLimiter 限速读取
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *byteLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if max := int(t.l.rate); len(p) > max && max > 0 {
+		p = p[:max]
+	}
+	if len(p) > 32<<10 {
+		p = p[:32<<10]
+	}
+	n, err := t.r.Read(p)
+	if d := t.l.reserve(n); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,64 @@
+package h2sanlog
+
+import "bytes"
+
+// atomicAppendMax 单次追加写的上限，不超过 PIPE_BUF 和页大小，本地文件系统上多个进程同时追加不会交错
+const atomicAppendMax = 4096
+
+// WithAtomicAppend 多个进程追加同一个文件时保证行不交错：每次 write 只包含完整的行且不超过 4096 字节，
+// 批量写入按行拆成多次 write。超过 4096 字节的单条日志 split 为 true 时按 4096 字节切成多行，
+// 否则整条丢弃并返回 ErrEntryTooLarge
+func WithAtomicAppend(split bool) FileOption {
+	return func(w *FileWriter) {
+		w.atomicAppend = true
+		w.atomicSplit = split
+	}
+}
+
+// atomicReject 开启 WithAtomicAppend 且不切分时，n 字节的单条日志是否超限
+func (w *FileWriter) atomicReject(n int) bool {
+	return w.atomicAppend && !w.atomicSplit && n > atomicAppendMax
+}
+
+// writeAtomic 把 p 按行拆成不超过 atomicAppendMax 的多次 write，返回实际写入文件的字节数，调用方需持有锁
+func (w *FileWriter) writeAtomic(p []byte) (int, error) {
+	var scratch [atomicAppendMax]byte
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > atomicAppendMax {
+			chunk = p[:atomicAppendMax]
+			if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+				chunk = p[:i+1]
+			} else if w.atomicSplit {
+				// 单行超限，切下的部分补换行单独成行
+				n := copy(scratch[:atomicAppendMax-1], p)
+				scratch[n] = '\n'
+				wn, err := w.writer.Write(scratch[:])
+				written += wn
+				if err != nil {
+					return written, err
+				}
+				p = p[n:]
+				continue
+			} else if i := bytes.IndexByte(p, '\n'); i >= 0 {
+				chunk = p[:i+1]
+			}
+		}
+		wn, err := w.writer.Write(chunk)
+		written += wn
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// writeActive 写入当前日志文件，开启 WithAtomicAppend 时按行拆分，调用方需持有锁
+func (w *FileWriter) writeActive(p []byte) (int, error) {
+	if w.atomicAppend {
+		return w.writeAtomic(p)
+	}
+	return w.writer.Write(p)
+}
@@ -0,0 +1,199 @@
+package h2sanlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrAuditTampered 审计日志哈希链或签名校验失败
+var ErrAuditTampered = errors.New("h2sanlog: audit log tampered")
+
+// auditRecord 审计日志的一行，Sig 非空时为签名检查点，否则为一条日志
+type auditRecord struct {
+	Seq  uint64 `json:"seq"`
+	Prev string `json:"prev,omitempty"`
+	Data string `json:"data,omitempty"`
+	Hash string `json:"hash"`
+	Sig  []byte `json:"sig,omitempty"`
+}
+
+// AuditWriter 防篡改审计日志：每次 Write 写一行 JSON 记录，哈希为 sha256(上一条哈希||序号||内容)，
+// 形成哈希链；每 every 条写一个对当前哈希的 ed25519 签名检查点，用 VerifyAudit 校验
+type AuditWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	key   ed25519.PrivateKey
+	every int
+	seq   uint64
+	prev  [sha256.Size]byte
+	n     int
+}
+
+// NewAuditWriter 新建审计 writer，every<=0 时只在调用 Checkpoint 时签名；
+// 最后一个检查点之后的记录无法通过 VerifyAudit，关闭前需调用 Checkpoint
+func NewAuditWriter(w io.Writer, key ed25519.PrivateKey, every int) *AuditWriter {
+	return &AuditWriter{w: w, key: key, every: every}
+}
+
+// Resume 从已有审计日志（如重启前正在写的文件）的最后一条记录接续哈希链，需在第一次 Write 前调用
+func (a *AuditWriter) Resume(r io.Reader) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for sc.Scan() {
+		var rec auditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("h2sanlog: resume audit: %w", err)
+		}
+		h, err := hex.DecodeString(rec.Hash)
+		if err != nil || len(h) != sha256.Size {
+			return fmt.Errorf("h2sanlog: resume audit seq %d: bad hash", rec.Seq)
+		}
+		a.seq = rec.Seq
+		copy(a.prev[:], h)
+	}
+	return sc.Err()
+}
+
+// Write 把 p（去掉末尾换行）作为一条审计记录写入下层 writer，成功时返回 len(p)
+func (a *AuditWriter) Write(p []byte) (int, error) {
+	return a.write(p, plainWrite)
+}
+
+// WriteSync 实现 SyncWriter，把审计记录同步写入下层 writer
+func (a *AuditWriter) WriteSync(p []byte) (int, error) {
+	return a.write(p, writeSync)
+}
+
+func (a *AuditWriter) write(p []byte, write func(io.Writer, []byte) (int, error)) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	data := string(bytes.TrimSuffix(p, []byte{'\n'}))
+	rec := auditRecord{Seq: a.seq + 1, Prev: hex.EncodeToString(a.prev[:]), Data: data}
+	sum := auditHash(a.prev[:], rec.Seq, data)
+	rec.Hash = hex.EncodeToString(sum[:])
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	line = append(line, '\n')
+	if a.every > 0 && a.n+1 >= a.every {
+		if line, err = a.appendCheckpoint(line, rec.Seq, sum); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := write(a.w, line); err != nil {
+		return 0, err
+	}
+	a.seq, a.prev = rec.Seq, sum
+	a.n++
+	if a.every > 0 && a.n >= a.every {
+		a.n = 0
+	}
+	return len(p), nil
+}
+
+// Checkpoint 立即写一个签名检查点，可由定时器周期性调用
+func (a *AuditWriter) Checkpoint() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	line, err := a.appendCheckpoint(nil, a.seq, a.prev)
+	if err != nil {
+		return err
+	}
+	if _, err := a.w.Write(line); err != nil {
+		return err
+	}
+	a.n = 0
+	return nil
+}
+
+// appendCheckpoint 追加对 seq 和 sum 的签名检查点
+func (a *AuditWriter) appendCheckpoint(line []byte, seq uint64, sum [sha256.Size]byte) ([]byte, error) {
+	cp := auditRecord{Seq: seq, Hash: hex.EncodeToString(sum[:])}
+	cp.Sig = ed25519.Sign(a.key, checkpointMessage(seq, sum[:]))
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(line, b...), '\n'), nil
+}
+
+func auditHash(prev []byte, seq uint64, data string) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(prev)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	h.Write(b[:])
+	h.Write([]byte(data))
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+func checkpointMessage(seq uint64, sum []byte) []byte {
+	msg := make([]byte, 8, 8+len(sum))
+	binary.BigEndian.PutUint64(msg, seq)
+	return append(msg, sum...)
+}
+
+// VerifyAudit 校验审计日志的哈希链和全部检查点签名，返回日志记录数和检查点数。
+// 哈希链不带密钥，可以整体重算，所以每条记录都必须被之后的签名检查点覆盖：
+// 有记录但没有检查点、或最后一个检查点之后还有记录时返回 ErrAuditTampered，
+// 关闭或切换文件前需要调用 Checkpoint。第一条记录的上一条哈希无法在单个文件内校验，按其声明的值接续
+func VerifyAudit(r io.Reader, pub ed25519.PublicKey) (records, checkpoints int, err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	var prev []byte
+	var seq uint64
+	// unsigned 最后一个检查点之后的记录数
+	unsigned := 0
+	for sc.Scan() {
+		var rec auditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return records, checkpoints, fmt.Errorf("%w: %v", ErrAuditTampered, err)
+		}
+		if rec.Sig != nil {
+			sum, err := hex.DecodeString(rec.Hash)
+			if prev == nil {
+				//文件以检查点开头，按其声明的值接续
+				prev, seq = sum, rec.Seq
+			}
+			if err != nil || rec.Seq != seq || !bytes.Equal(sum, prev) ||
+				!ed25519.Verify(pub, checkpointMessage(rec.Seq, sum), rec.Sig) {
+				return records, checkpoints, fmt.Errorf("%w: checkpoint seq %d", ErrAuditTampered, rec.Seq)
+			}
+			checkpoints++
+			unsigned = 0
+			continue
+		}
+		p, err := hex.DecodeString(rec.Prev)
+		if err != nil || (prev != nil && (!bytes.Equal(p, prev) || rec.Seq != seq+1)) {
+			return records, checkpoints, fmt.Errorf("%w: broken chain at seq %d", ErrAuditTampered, rec.Seq)
+		}
+		sum := auditHash(p, rec.Seq, rec.Data)
+		if hex.EncodeToString(sum[:]) != rec.Hash {
+			return records, checkpoints, fmt.Errorf("%w: hash mismatch at seq %d", ErrAuditTampered, rec.Seq)
+		}
+		prev, seq = sum[:], rec.Seq
+		records++
+		unsigned++
+	}
+	if err := sc.Err(); err != nil {
+		return records, checkpoints, err
+	}
+	if unsigned > 0 {
+		return records, checkpoints, fmt.Errorf("%w: %d records after last checkpoint", ErrAuditTampered, unsigned)
+	}
+	return records, checkpoints, nil
+}
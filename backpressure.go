@@ -0,0 +1,44 @@
+package h2sanlog
+
+import "time"
+
+// WithNeverDrop 队列满时按级别区别对待：级别不低于 level（如 LogLevelWarning）的日志阻塞等待队列空位，
+// 最多等 timeout，timeout<=0 时一直等到写入成功或 writer 关闭；低于 level 的日志照常丢弃（或写入 WithSpill 的溢出文件）。
+// 级别按行首的 [LEVEL] 标签或 JSON 的 level 字段识别，识别不出级别的日志按低级别处理
+func WithNeverDrop(level uint8, timeout time.Duration) FileOption {
+	return func(w *FileWriter) {
+		w.neverDrop = level
+		w.neverDropWait = timeout
+	}
+}
+
+// mustKeep 队列满时 p 是否需要等待而不是丢弃
+func (w *FileWriter) mustKeep(p []byte) bool {
+	return w.neverDrop != LogLevelNull && sniffLevel(p) >= w.neverDrop
+}
+
+// pushWait 等待队列空位入队，超时或 writer 关闭时返回 false
+func (w *FileWriter) pushWait(buf *[]byte) bool {
+	var deadline time.Time
+	if w.neverDropWait > 0 {
+		deadline = time.Now().Add(w.neverDropWait)
+	}
+	delay := 50 * time.Microsecond
+	for {
+		select {
+		case <-w.done:
+			return false
+		default:
+		}
+		if w.queue.push(buf) {
+			return true
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(delay)
+		if delay < time.Millisecond {
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WithRetentionBudget 设置本 writer 所有日志文件（各天的日志文件和 .full 文件）的总预算：
+// 文件数超过 maxFiles 或总大小超过 maxBytes 时从最旧的文件开始删除，当前文件不删；
+// 不等于 0 的限制才生效。在启动和每次轮转后检查，与 maxNum、RetentionPolicy 同时生效
+func WithRetentionBudget(maxFiles int, maxBytes int64) FileOption {
+	return func(w *FileWriter) {
+		w.budgetFiles = maxFiles
+		w.budgetBytes = maxBytes
+	}
+}
+
+// WithMaxTotalSize 限制本 writer 所有日志文件的总大小，同 WithRetentionBudget(0, n)，但在写盘路径上也检查：
+// 总大小即将超过 n 时先删除最旧的文件，只剩当前文件仍超限时轮转当前文件后删除，无论流量多大占用的磁盘都不超过 n
+func WithMaxTotalSize(n int64) FileOption {
+	return func(w *FileWriter) {
+		w.budgetBytes = n
+		w.quota = true
+	}
+}
+
+// overQuota 写入 n 字节后是否超过 WithMaxTotalSize，调用方需持有锁
+func (w *FileWriter) overQuota(n int) bool {
+	return w.quota && w.budgetBytes > 0 && w.retained+w.size+int64(n) > w.budgetBytes
+}
+
+// enforceQuota 写入 n 字节前按 WithMaxTotalSize 删除旧文件，必要时轮转当前文件，调用方需持有锁。
+// 使用上次扫描目录后记下的文件，不再逐次扫描
+func (w *FileWriter) enforceQuota(n int) error {
+	w.trimBudget(int64(n))
+	if w.size == 0 || !w.overQuota(n) {
+		return nil
+	}
+	if err := w.rotateFull(w.size); err != nil {
+		return err
+	}
+	w.trimBudget(int64(n))
+	return nil
+}
+
+// retainedFile 总预算统计的非当前日志文件
+type retainedFile struct {
+	path string
+	size int64
+}
+
+// enforceBudget 重新扫描目录，记录非当前文件及其总大小后按总预算删除最旧的文件，reserve 为即将写入的字节数，
+// 在启动和轮转后调用，调用方需持有锁
+func (w *FileWriter) enforceBudget(reserve int64) {
+	if w.budgetFiles <= 0 && w.budgetBytes <= 0 {
+		return
+	}
+	active := filepath.Clean(w.filePath)
+	w.retained, w.retainedFiles = 0, w.retainedFiles[:0]
+	for _, f := range w.logFiles() {
+		if f.path != active {
+			w.retained += f.info.Size()
+			w.retainedFiles = append(w.retainedFiles, retainedFile{f.path, f.info.Size()})
+		}
+	}
+	w.trimBudget(reserve)
+}
+
+// trimBudget 按总预算从最旧的文件开始删除记下的非当前文件，当前文件不删，调用方需持有锁
+func (w *FileWriter) trimBudget(reserve int64) {
+	for len(w.retainedFiles) > 0 {
+		if (w.budgetFiles <= 0 || len(w.retainedFiles)+1 <= w.budgetFiles) &&
+			(w.budgetBytes <= 0 || w.retained+w.size+reserve <= w.budgetBytes) {
+			return
+		}
+		f := w.retainedFiles[0]
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			w.onError(fmt.Errorf("remove file path:%s fail:%w", f.path, err))
+			// 删除失败的文件不再计入，下次扫描目录时重新统计
+			w.forgetRetained(f.path)
+			continue
+		}
+		w.removed(f.path)
+	}
+}
+
+// forgetRetained 从记下的非当前文件中去掉 path，调用方需持有锁
+func (w *FileWriter) forgetRetained(path string) {
+	for i, f := range w.retainedFiles {
+		if f.path == path {
+			w.retained -= f.size
+			w.retainedFiles = append(w.retainedFiles[:i], w.retainedFiles[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package h2sanlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// dirFiles 返回 dir 下的文件名和总大小
+func dirFiles(t *testing.T, dir string) ([]string, int64) {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var total int64
+	for _, f := range files {
+		names = append(names, f.Name())
+		total += f.Size()
+	}
+	sort.Strings(names)
+	return names, total
+}
+
+// writeLines 逐条写入并等待写盘
+func writeLines(w *FileWriter, lines ...string) {
+	for _, s := range lines {
+		w.Write([]byte(s))
+		w.Flush(time.Second)
+	}
+}
+
+func TestRetentionBudgetFiles(t *testing.T) {
+	var removed []string
+	w := newTestWriter(t, 10, 0, WithFilePattern("app.log"), WithRetentionBudget(2, 0),
+		WithOnRemove(func(path string) { removed = append(removed, filepath.Base(path)) }))
+	dir := filepath.Dir(w.fileName)
+	writeLines(w, "line-001\n", "line-002\n", "line-003\n", "line-004\n")
+	// 当前文件也计入文件数，每次轮转后删除最旧的 .full 文件
+	names, _ := dirFiles(t, dir)
+	if want := []string{"app.log", "app.log.full.3.log"}; !stringsEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if want := []string{"app.log.full.1.log", "app.log.full.2.log"}; !stringsEqual(removed, want) {
+		t.Fatalf("removed = %v, want %v", removed, want)
+	}
+	if got := activeContent(t, w); got != "line-004\n" {
+		t.Fatalf("active = %q", got)
+	}
+}
+
+func TestRetentionBudgetBytes(t *testing.T) {
+	w := newTestWriter(t, 10, 0, WithFilePattern("app.log"), WithRetentionBudget(0, 20))
+	dir := filepath.Dir(w.fileName)
+	// 只在轮转后检查，两次轮转之间当前文件的写入最多超出预算 maxSize
+	for i := 0; i < 6; i++ {
+		writeLines(w, "12345678\n")
+		if _, total := dirFiles(t, dir); total > 20+10 {
+			t.Fatalf("after %d lines total = %d", i+1, total)
+		}
+	}
+	if names, total := dirFiles(t, dir); len(names) != 3 || total != 27 {
+		t.Fatalf("files = %v, total %d", names, total)
+	}
+}
+
+// 启动时检查预算，按修改时间从最旧的文件开始删除，不属于本 writer 的文件不动
+func TestRetentionBudgetOnStart(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	for i, name := range []string{"app.2026-01-03.log", "app.2026-01-01.log", "app.2026-01-02.log", "other.log"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := old.Add(time.Duration(i) * time.Minute)
+		if name == "app.2026-01-03.log" {
+			mtime = old.Add(10 * time.Minute)
+		}
+		os.Chtimes(path, mtime, mtime)
+	}
+	w, err := NewFileWriter(filepath.Join(dir, "app"), 0, 0, WithRetentionBudget(3, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	names, _ := dirFiles(t, dir)
+	want := []string{"app.2026-01-02.log", "app.2026-01-03.log", filepath.Base(activePath(w)), "other.log"}
+	sort.Strings(want)
+	if !stringsEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WithMaxTotalSize 在写盘路径上检查，任何时候磁盘占用都不超过限制
+func TestMaxTotalSize(t *testing.T) {
+	removed := 0
+	w := newTestWriter(t, 0, 0, WithFilePattern("app.log"), WithMaxTotalSize(30),
+		WithOnRemove(func(string) { removed++ }))
+	dir := filepath.Dir(w.fileName)
+	for i := 0; i < 20; i++ {
+		writeLines(w, "12345678\n")
+		if _, total := dirFiles(t, dir); total > 30 {
+			t.Fatalf("after %d lines total = %d", i+1, total)
+		}
+	}
+	// 只剩当前文件仍超限时轮转当前文件，轮转出的文件放不下下一条日志也被删除
+	names, total := dirFiles(t, dir)
+	if len(names) != 1 || total != 18 {
+		t.Fatalf("files = %v, total %d", names, total)
+	}
+	if removed != 6 {
+		t.Fatalf("removed %d files, want 6", removed)
+	}
+}
+
+// 总大小超限删除旧文件后仍按 maxSize 轮转当前文件
+func TestMaxTotalSizeKeepsMaxSize(t *testing.T) {
+	w := newTestWriter(t, 20, 0, WithFilePattern("app.log"), WithMaxTotalSize(40))
+	dir := filepath.Dir(w.fileName)
+	for i := 0; i < 20; i++ {
+		writeLines(w, "12345678\n")
+		names, total := dirFiles(t, dir)
+		if total > 40 {
+			t.Fatalf("after %d lines total = %d", i+1, total)
+		}
+		for _, name := range names {
+			if fi, _ := os.Stat(filepath.Join(dir, name)); fi.Size() > 20 {
+				t.Fatalf("after %d lines %s size = %d", i+1, name, fi.Size())
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package h2sanlog
+
+import (
+	"strconv"
+	"time"
+)
+
+// Buffer 池化的日志缓冲，调用方直接把内容追加进去后用 FileWriter.WriteBuffer 整个交给 writer，
+// 省去 Write 的复制和格式化的中间分配，适合对延迟敏感的热路径：
+//
+//	b := h2sanlog.GetBuffer()
+//	b.AppendTime(time.Now(), time.RFC3339).AppendString(" user=").AppendInt(id).AppendByte('\n')
+//	w.WriteBuffer(b)
+type Buffer []byte
+
+// GetBuffer 从池中取一个空 Buffer
+func GetBuffer() *Buffer {
+	return (*Buffer)(getBuf())
+}
+
+// Free 不写入时把 Buffer 放回池，之后不能再使用
+func (b *Buffer) Free() {
+	putBuf((*[]byte)(b))
+}
+
+// Bytes 返回已追加的内容
+func (b *Buffer) Bytes() []byte { return *b }
+
+// Len 返回已追加的字节数
+func (b *Buffer) Len() int { return len(*b) }
+
+// Reset 清空内容，保留底层空间
+func (b *Buffer) Reset() { *b = (*b)[:0] }
+
+// AppendByte 追加一个字节
+func (b *Buffer) AppendByte(c byte) *Buffer {
+	*b = append(*b, c)
+	return b
+}
+
+// AppendBytes 追加字节
+func (b *Buffer) AppendBytes(p []byte) *Buffer {
+	*b = append(*b, p...)
+	return b
+}
+
+// AppendString 追加字符串
+func (b *Buffer) AppendString(s string) *Buffer {
+	*b = append(*b, s...)
+	return b
+}
+
+// AppendQuoted 追加带双引号并转义的字符串
+func (b *Buffer) AppendQuoted(s string) *Buffer {
+	*b = strconv.AppendQuote(*b, s)
+	return b
+}
+
+// AppendInt 追加十进制整数
+func (b *Buffer) AppendInt(n int64) *Buffer {
+	*b = strconv.AppendInt(*b, n, 10)
+	return b
+}
+
+// AppendUint 追加十进制无符号整数
+func (b *Buffer) AppendUint(n uint64) *Buffer {
+	*b = strconv.AppendUint(*b, n, 10)
+	return b
+}
+
+// AppendFloat 追加浮点数，格式同 strconv.FormatFloat(f, 'g', -1, 64)
+func (b *Buffer) AppendFloat(f float64) *Buffer {
+	*b = strconv.AppendFloat(*b, f, 'g', -1, 64)
+	return b
+}
+
+// AppendBool 追加 true 或 false
+func (b *Buffer) AppendBool(v bool) *Buffer {
+	*b = strconv.AppendBool(*b, v)
+	return b
+}
+
+// AppendTime 按 layout 追加时间
+func (b *Buffer) AppendTime(t time.Time, layout string) *Buffer {
+	*b = t.AppendFormat(*b, layout)
+	return b
+}
+
+// WriteBuffer 把 b 直接入队，不再复制；无论成功与否 b 的所有权都交给 writer，调用后不能再使用。
+// 内容需自行以换行结尾，超过 WithMaxEntryBytes 时同 Write 截断或丢弃
+func (w *FileWriter) WriteBuffer(b *Buffer) (int, error) {
+	buf := (*[]byte)(b)
+	total := len(*buf)
+	*buf = (*buf)[:w.entryLen(total)]
+	return w.enqueue(buf, total)
+}
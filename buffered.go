@@ -0,0 +1,74 @@
+package h2sanlog
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+)
+
+// WithBufferedWrites 当前日志文件外包一层 size 字节的 bufio.Writer，写满才写盘，减少高流量时的 write 次数；
+// 缓冲中最后一次写入后空闲超过 idle 时写盘，低流量时日志也能及时落盘，idle<=0 时只在写满、Flush、轮转和关闭时写盘。
+// 缓冲中的日志在进程崩溃时丢失；WithFileLock 多进程共享或 WithAtomicAppend 时不生效
+func WithBufferedWrites(size int, idle time.Duration) FileOption {
+	return func(w *FileWriter) {
+		w.bufSize = size
+		w.bufIdle = idle
+	}
+}
+
+// activeFile 把写入转到当前日志文件，文件重建后缓冲中的日志写入新文件
+type activeFile struct{ w *FileWriter }
+
+func (f activeFile) Write(p []byte) (int, error) { return f.w.file.Write(p) }
+
+// buffered 是否开启 WithBufferedWrites
+func (w *FileWriter) buffered() bool {
+	return w.bufSize > 0 && !w.useFileLock && !w.atomicAppend
+}
+
+// bufferActive 开启缓冲时写入经过缓冲，调用方需持有锁
+func (w *FileWriter) bufferActive() {
+	if !w.buffered() {
+		return
+	}
+	if w.buf == nil {
+		w.buf = bufio.NewWriterSize(activeFile{w}, w.bufSize)
+	}
+	w.writer = w.buf
+}
+
+// flushBuffer 把缓冲写入当前日志文件，失败时丢弃缓冲，避免 bufio.Writer 记住错误后不再写入，调用方需持有锁
+func (w *FileWriter) flushBuffer() {
+	if w.buf == nil || w.buf.Buffered() == 0 {
+		return
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.resetBuffer()
+		w.onError(fmt.Errorf("flush buffer path:%s fail:%w", w.filePath, err))
+	}
+}
+
+// resetBuffer 写入失败后丢弃缓冲中的日志，调用方需持有锁
+func (w *FileWriter) resetBuffer() {
+	if w.buf != nil {
+		w.buf.Reset(activeFile{w})
+	}
+}
+
+// bufferLoop 缓冲中的日志空闲超过 bufIdle 时写盘
+func (w *FileWriter) bufferLoop() {
+	ticker := w.clock.NewTicker(w.bufIdle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+		case <-w.done:
+			return
+		}
+		w.lock()
+		if w.now().Sub(w.lastWrite) >= w.bufIdle {
+			w.flushBuffer()
+		}
+		w.mu.Unlock()
+	}
+}
@@ -0,0 +1,133 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// burstBuckets 统计窗口切分的桶数，窗口按桶滑动
+const burstBuckets = 10
+
+// BurstAlert 一次突发告警
+type BurstAlert struct {
+	// Level Count 窗口内级别不低于 Level 的日志条数
+	Level  uint8
+	Count  int
+	Window time.Duration
+	Time   time.Time
+	// Name Message 触发告警的那条日志，便于定位
+	Name    string
+	Message string
+}
+
+// BurstMonitor 按级别统计滑动窗口内的日志条数，级别不低于 level 的日志在 window 内达到 threshold 条时调用告警回调。
+// 触发后不再重复告警，直到窗口内条数回落到 threshold 的一半以下
+type BurstMonitor struct {
+	level     uint8
+	threshold int
+	window    time.Duration
+	fn        func(BurstAlert)
+
+	mu      sync.Mutex
+	buckets [burstBuckets]burstBucket
+	firing  bool
+}
+
+type burstBucket struct {
+	slot   int64
+	counts [LogLevelFatal + 1]int
+}
+
+// NewBurstMonitor 新建突发监控，fn 在新的goroutine中调用，不阻塞写日志
+func NewBurstMonitor(level uint8, threshold int, window time.Duration, fn func(BurstAlert)) *BurstMonitor {
+	return &BurstMonitor{level: level, threshold: threshold, window: window, fn: fn}
+}
+
+// SetBurstMonitor 设置突发监控，nil 取消；统计在过滤之后、采样和限流之前，反映真实的日志量。
+// 子logger与父logger共用同一个监控，多个 logger 可共用一个监控统计总量
+func (l *Logger) SetBurstMonitor(m *BurstMonitor) {
+	l.burst = m
+}
+
+// Count 返回当前窗口内 level 级别的日志条数，无效的级别返回 0
+func (m *BurstMonitor) Count(level uint8) int {
+	if level > LogLevelFatal {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur := m.slot(time.Now())
+	n := 0
+	for i := range m.buckets {
+		if b := &m.buckets[i]; b.slot > cur-burstBuckets {
+			n += b.counts[level]
+		}
+	}
+	return n
+}
+
+func (m *BurstMonitor) slot(t time.Time) int64 {
+	d := int64(m.window / burstBuckets)
+	if d <= 0 {
+		d = 1
+	}
+	return t.UnixNano() / d
+}
+
+// observe 记录一条日志，达到阈值时异步调用告警回调
+func (m *BurstMonitor) observe(e *Entry) {
+	m.mu.Lock()
+	cur := m.slot(e.Time)
+	b := &m.buckets[cur%burstBuckets]
+	if b.slot != cur {
+		*b = burstBucket{slot: cur}
+	}
+	b.counts[e.Level]++
+	n := 0
+	for i := range m.buckets {
+		if b := &m.buckets[i]; b.slot > cur-burstBuckets {
+			for lv := int(m.level); lv <= LogLevelFatal; lv++ {
+				n += b.counts[lv]
+			}
+		}
+	}
+	fire := false
+	if !m.firing && n >= m.threshold && e.Level >= m.level {
+		m.firing, fire = true, true
+	} else if m.firing && n < m.threshold/2 {
+		m.firing = false
+	}
+	m.mu.Unlock()
+	if fire && m.fn != nil {
+		go m.fn(BurstAlert{Level: m.level, Count: n, Window: m.window, Time: e.Time, Name: e.Name, Message: e.Message})
+	}
+}
+
+// WebhookBurstAlert 返回把告警以 JSON POST 到 url 的回调，可直接传给 NewBurstMonitor；发送失败输出到 stderr
+func WebhookBurstAlert(url string) func(BurstAlert) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(a BurstAlert) {
+		body, _ := json.Marshal(map[string]interface{}{
+			"level":   levelNames[a.Level],
+			"count":   a.Count,
+			"window":  a.Window.String(),
+			"time":    a.Time.Format(time.RFC3339Nano),
+			"logger":  a.Name,
+			"message": a.Message,
+		})
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+		if err != nil {
+			stderrError(fmt.Errorf("send burst alert to %s fail:%w", url, err))
+		}
+	}
+}
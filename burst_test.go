@@ -0,0 +1,132 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// burstRecorder 把告警回调转成 channel
+func burstRecorder() (chan BurstAlert, func(BurstAlert)) {
+	ch := make(chan BurstAlert, 16)
+	return ch, func(a BurstAlert) { ch <- a }
+}
+
+// expectAlerts 等待 n 条告警，之后不再有告警
+func expectAlerts(t *testing.T, ch chan BurstAlert, n int) []BurstAlert {
+	t.Helper()
+	var got []BurstAlert
+	for len(got) < n {
+		select {
+		case a := <-ch:
+			got = append(got, a)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d alerts, want %d", len(got), n)
+		}
+	}
+	select {
+	case a := <-ch:
+		t.Fatalf("unexpected alert %+v", a)
+	case <-time.After(20 * time.Millisecond):
+	}
+	return got
+}
+
+func TestBurstFiring(t *testing.T) {
+	ch, fn := burstRecorder()
+	m := NewBurstMonitor(LogLevelError, 4, 10*time.Second, fn)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// 低于 level 的日志不计入
+	for i := 0; i < 10; i++ {
+		m.observe(&Entry{Time: now, Level: LogLevelWarning, Message: "warn"})
+	}
+	for i := 0; i < 3; i++ {
+		m.observe(&Entry{Time: now.Add(time.Duration(i) * time.Second), Level: LogLevelError, Message: "err"})
+	}
+	expectAlerts(t, ch, 0)
+	// 更高级别也计入，达到阈值触发一次，之后不重复
+	m.observe(&Entry{Time: now.Add(3 * time.Second), Level: LogLevelFatal, Name: "db", Message: "fatal"})
+	for i := 0; i < 5; i++ {
+		m.observe(&Entry{Time: now.Add(4 * time.Second), Level: LogLevelError, Message: "err"})
+	}
+	a := expectAlerts(t, ch, 1)[0]
+	want := BurstAlert{Level: LogLevelError, Count: 4, Window: 10 * time.Second, Time: now.Add(3 * time.Second), Name: "db", Message: "fatal"}
+	if a != want {
+		t.Fatalf("alert = %+v, want %+v", a, want)
+	}
+}
+
+// 窗口滑过后条数回落到阈值一半以下时重新布防，再次达到阈值再告警
+func TestBurstRearm(t *testing.T) {
+	ch, fn := burstRecorder()
+	m := NewBurstMonitor(LogLevelError, 4, 10*time.Second, fn)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		m.observe(&Entry{Time: now, Level: LogLevelError})
+	}
+	expectAlerts(t, ch, 1)
+	// 窗口内还有 3 条，不低于阈值一半，仍在告警状态
+	for i := 0; i < 3; i++ {
+		m.observe(&Entry{Time: now.Add(9 * time.Second), Level: LogLevelError})
+	}
+	m.observe(&Entry{Time: now.Add(15 * time.Second), Level: LogLevelError})
+	expectAlerts(t, ch, 0)
+	// 之前的日志都滑出窗口，1 条低于阈值一半，重新布防
+	m.observe(&Entry{Time: now.Add(20 * time.Second), Level: LogLevelInfo})
+	for i := 0; i < 3; i++ {
+		m.observe(&Entry{Time: now.Add(20 * time.Second), Level: LogLevelError})
+	}
+	if a := expectAlerts(t, ch, 1)[0]; a.Count != 4 {
+		t.Fatalf("Count = %d, want 4", a.Count)
+	}
+}
+
+func TestBurstLoggerCount(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetLevel(LogLevelError)
+	ch, fn := burstRecorder()
+	m := NewBurstMonitor(LogLevelError, 3, time.Minute, fn)
+	l.SetBurstMonitor(m)
+	// 子logger共用父logger的监控，被级别过滤的日志不计入
+	child := l.Named("child")
+	l.Info("filtered")
+	l.Error("a")
+	child.Error("b")
+	if n := m.Count(LogLevelError); n != 2 {
+		t.Fatalf("Count = %d, want 2", n)
+	}
+	child.Error("c")
+	if a := expectAlerts(t, ch, 1)[0]; a.Name != "child" || a.Message != "c" {
+		t.Fatalf("alert = %+v", a)
+	}
+	if n := m.Count(LogLevelInfo); n != 0 {
+		t.Fatalf("Count(INFO) = %d", n)
+	}
+}
+
+func TestWebhookBurstAlert(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer srv.Close()
+	WebhookBurstAlert(srv.URL)(BurstAlert{Level: LogLevelError, Count: 7, Window: time.Minute,
+		Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Name: "db", Message: "down"})
+	var got map[string]interface{}
+	if err := json.Unmarshal(<-bodies, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"level": "ERROR", "count": 7.0, "window": "1m0s",
+		"time": "2026-03-01T12:00:00Z", "logger": "db", "message": "down"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package h2sanlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CatalogEntry 目录中一个已轮转文件的元数据
+type CatalogEntry struct {
+	Path string `json:"path"`
+	// Start End 文件中首末次写盘的时间，启用目录前已存在的文件 Start 为零值、End 为修改时间
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Size  int64     `json:"size"`
+	// Compressed Uploaded 由压缩、归档工具通过 UpdateCatalog 标记
+	Compressed bool `json:"compressed"`
+	Uploaded   bool `json:"uploaded"`
+}
+
+// catalog 已轮转文件的索引，每次变化整体重写到 path
+type catalog struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*CatalogEntry
+}
+
+// WithCatalog 维护本 writer 所有已轮转文件的索引（起止时间、大小、压缩/上传标记），保存为 JSON，
+// 保留和归档工具通过 Catalog/UpdateCatalog 使用，不必再解析文件名；path 为空时为 <fileName>.catalog
+func WithCatalog(path string) FileOption {
+	return func(w *FileWriter) {
+		if path == "" {
+			path = w.fileName + ".catalog"
+		}
+		w.catalog = &catalog{path: path, entries: make(map[string]*CatalogEntry)}
+	}
+}
+
+// loadCatalog 读取已有索引，去掉已不存在的文件，补上索引之外的已轮转文件
+func (w *FileWriter) loadCatalog() {
+	c := w.catalog
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data, err := ioutil.ReadFile(c.path); err == nil {
+		var list []*CatalogEntry
+		if err := json.Unmarshal(data, &list); err != nil {
+			w.onError(fmt.Errorf("load catalog path:%s fail:%w", c.path, err))
+		}
+		for _, e := range list {
+			if _, err := os.Stat(e.Path); err == nil {
+				c.entries[e.Path] = e
+			}
+		}
+	}
+	active := filepath.Clean(w.filePath)
+	delete(c.entries, active)
+	for _, f := range w.logFiles() {
+		if f.path == active || c.entries[f.path] != nil {
+			continue
+		}
+		c.entries[f.path] = &CatalogEntry{Path: f.path, End: f.info.ModTime(), Size: f.info.Size()}
+	}
+	w.saveCatalog()
+}
+
+// catalogAdd 记录刚轮转的文件
+func (w *FileWriter) catalogAdd(path string, start, end time.Time) {
+	c := w.catalog
+	if c == nil {
+		return
+	}
+	path = filepath.Clean(path)
+	e := &CatalogEntry{Path: path, Start: start, End: end}
+	if fi, err := os.Stat(path); err == nil {
+		e.Size = fi.Size()
+		if end.IsZero() {
+			e.End = fi.ModTime()
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = e
+	w.saveCatalog()
+}
+
+// catalogRemove 删除已被清理的文件
+func (w *FileWriter) catalogRemove(path string) {
+	c := w.catalog
+	if c == nil {
+		return
+	}
+	path = filepath.Clean(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[path]; ok {
+		delete(c.entries, path)
+		w.saveCatalog()
+	}
+}
+
+// list 按结束时间从旧到新返回索引的副本，调用方需持有 c.mu
+func (c *catalog) list() []CatalogEntry {
+	list := make([]CatalogEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].End.Before(list[j].End) })
+	return list
+}
+
+// saveCatalog 先写临时文件再重命名，避免崩溃时留下半个索引，调用方需持有 c.mu
+func (w *FileWriter) saveCatalog() {
+	c := w.catalog
+	data, err := json.MarshalIndent(c.list(), "", "  ")
+	if err == nil {
+		tmp := c.path + ".tmp"
+		if err = ioutil.WriteFile(tmp, data, 0666); err == nil {
+			err = os.Rename(tmp, c.path)
+		}
+	}
+	if err != nil {
+		w.onError(fmt.Errorf("save catalog path:%s fail:%w", c.path, err))
+	}
+}
+
+// Catalog 返回所有已轮转文件的元数据，按结束时间从旧到新；未开启 WithCatalog 时返回 nil
+func (w *FileWriter) Catalog() []CatalogEntry {
+	c := w.catalog
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list()
+}
+
+// UpdateCatalog 修改 path 的元数据（如压缩、上传后设置标记），path 不在索引中时返回 os.ErrNotExist。
+// 压缩后文件改名时在 fn 中修改 Path 即可
+func (w *FileWriter) UpdateCatalog(path string, fn func(e *CatalogEntry)) error {
+	c := w.catalog
+	if c == nil {
+		return fmt.Errorf("h2sanlog: catalog not enabled")
+	}
+	path = filepath.Clean(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok {
+		return fmt.Errorf("h2sanlog: catalog %s: %w", path, os.ErrNotExist)
+	}
+	fn(e)
+	if e.Path != path {
+		delete(c.entries, path)
+		c.entries[e.Path] = e
+	}
+	w.saveCatalog()
+	return nil
+}
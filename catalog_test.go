@@ -0,0 +1,96 @@
+package h2sanlog
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCatalogTracksRotation(t *testing.T) {
+	w := newTestWriter(t, 10, 2, WithCatalog(""), WithFilePattern("app.log"))
+	for _, s := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		w.Write([]byte(s))
+		w.Flush(time.Second)
+	}
+	// 轮转了三次，保留最新的两个
+	cat := w.Catalog()
+	rotated := rotatedFiles(t, w)
+	if len(cat) != 2 || len(rotated) != 2 {
+		t.Fatalf("catalog %v, rotated %v", cat, rotated)
+	}
+	for i, e := range cat {
+		if e.Path != rotated[i] || e.Size != 9 || e.Start.IsZero() || e.End.Before(e.Start) {
+			t.Fatalf("entry %d = %+v", i, e)
+		}
+	}
+	data, err := ioutil.ReadFile(w.fileName + ".catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []CatalogEntry
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved) != 2 {
+		t.Fatalf("saved catalog %s: %v", data, err)
+	}
+}
+
+func TestCatalogReload(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app")
+	w, err := NewFileWriter(name, 10, 0, WithCatalog(""), WithFilePattern("app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n"} {
+		w.Write([]byte(s))
+		w.Flush(time.Second)
+	}
+	files := rotatedFiles(t, w)
+	w.Close()
+	// 重启前删掉一个文件、放入一个索引之外的文件
+	os.Remove(files[0])
+	extra := filepath.Join(dir, "app.log.full.9.log")
+	ioutil.WriteFile(extra, []byte("x\n"), 0644)
+
+	w, err = NewFileWriter(name, 10, 0, WithCatalog(""), WithFilePattern("app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	got := map[string]CatalogEntry{}
+	for _, e := range w.Catalog() {
+		got[e.Path] = e
+	}
+	if len(got) != 2 || got[files[1]].Start.IsZero() || !got[extra].Start.IsZero() || got[extra].Size != 2 {
+		t.Fatalf("catalog after reload = %+v", got)
+	}
+}
+
+func TestUpdateCatalog(t *testing.T) {
+	w := newTestWriter(t, 10, 0, WithCatalog(""), WithFilePattern("app.log"))
+	for _, s := range []string{"aaaaaaaa\n", "bbbbbbbb\n"} {
+		w.Write([]byte(s))
+		w.Flush(time.Second)
+	}
+	path := w.Catalog()[0].Path
+	err := w.UpdateCatalog(path, func(e *CatalogEntry) {
+		e.Compressed = true
+		e.Path += ".gz"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := w.Catalog()[0]; e.Path != path+".gz" || !e.Compressed {
+		t.Fatalf("entry = %+v", e)
+	}
+	if err := w.UpdateCatalog(path, func(*CatalogEntry) {}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("old path: err = %v", err)
+	}
+	plain := newTestWriter(t, 0, 0)
+	if plain.Catalog() != nil || plain.UpdateCatalog(path, func(*CatalogEntry) {}) == nil {
+		t.Fatal("catalog not enabled but usable")
+	}
+}
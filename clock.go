@@ -0,0 +1,73 @@
+package h2sanlog
+
+import "time"
+
+// Clock FileWriter 使用的时间源，测试时注入假时钟即可驱动按天轮转和每分钟检查，不用真的等到零点
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer Clock 创建的定时器
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker Clock 创建的周期定时器
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// WithClock 设置时间源，默认使用系统时间
+func WithClock(c Clock) FileOption {
+	return func(w *FileWriter) {
+		if c != nil {
+			w.clock = c
+		}
+	}
+}
+
+// WithLocation 设置日志文件名中的日期和按天轮转的零点使用的时区，默认本地时区，
+// 多地域部署时统一时区可以让各机器的文件名一致
+func WithLocation(loc *time.Location) FileOption {
+	return func(w *FileWriter) {
+		if loc != nil {
+			w.location = loc
+		}
+	}
+}
+
+// WithUTC 文件名日期和按天轮转使用 UTC，等同于 WithLocation(time.UTC)
+func WithUTC() FileOption {
+	return WithLocation(time.UTC)
+}
+
+// now 返回按 WithLocation 换算后的当前时间
+func (w *FileWriter) now() time.Time {
+	if w.location != nil {
+		return w.clock.Now().In(w.location)
+	}
+	return w.clock.Now()
+}
+
+// systemClock 基于 time 包的默认时间源
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return sysTimer{time.NewTimer(d)} }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return sysTicker{time.NewTicker(d)} }
+
+type sysTimer struct{ t *time.Timer }
+
+func (t sysTimer) C() <-chan time.Time { return t.t.C }
+func (t sysTimer) Stop() bool          { return t.t.Stop() }
+
+type sysTicker struct{ t *time.Ticker }
+
+func (t sysTicker) C() <-chan time.Time { return t.t.C }
+func (t sysTicker) Stop()               { t.t.Stop() }
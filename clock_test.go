@@ -0,0 +1,162 @@
+package h2sanlog_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2san/h2sanlog"
+	"github.com/h2san/h2sanlog/h2sanlogtest"
+)
+
+// advanceUntil 每次把 clock 推进 step，直到 cond 成立；推进之间让出时间给后台 goroutine，
+// 不需要知道被测 goroutine 何时创建定时器
+func advanceUntil(t *testing.T, clock *h2sanlogtest.FakeClock, step time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		clock.Add(step)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func newClockWriter(t *testing.T, clock *h2sanlogtest.FakeClock, maxSize int64, opts ...h2sanlog.FileOption) (*h2sanlog.FileWriter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	opts = append([]h2sanlog.FileOption{h2sanlog.WithClock(clock), h2sanlog.WithUTC()}, opts...)
+	w, err := h2sanlog.NewFileWriter(filepath.Join(dir, "app"), maxSize, 0, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w, dir
+}
+
+func TestFakeClockDailyRotation(t *testing.T) {
+	clock := h2sanlogtest.NewFakeClock(time.Date(2026, 3, 1, 23, 59, 30, 0, time.UTC))
+	w, dir := newClockWriter(t, clock, 0)
+	w.Write([]byte("a\n"))
+	w.Flush(time.Second)
+	clock.Add(time.Minute)
+	w.Write([]byte("b\n"))
+	w.Flush(time.Second)
+	if got := readFile(t, filepath.Join(dir, "app.2026-03-01.log")); got != "a\n" {
+		t.Fatalf("day 1 = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.2026-03-02.log")); got != "b\n" {
+		t.Fatalf("day 2 = %q", got)
+	}
+}
+
+// 零点之后没有写入时，每分钟的检查也要切换到新一天的文件
+func TestFakeClockIdleDailyRotation(t *testing.T) {
+	clock := h2sanlogtest.NewFakeClock(time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC))
+	_, dir := newClockWriter(t, clock, 0)
+	next := filepath.Join(dir, "app.2026-03-02.log")
+	advanceUntil(t, clock, 10*time.Second, "idle rotation", func() bool { return exists(next) })
+}
+
+func TestFakeClockHourlyPattern(t *testing.T) {
+	clock := h2sanlogtest.NewFakeClock(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC))
+	w, dir := newClockWriter(t, clock, 0, h2sanlog.WithFilePattern("app.%Y%m%d%H.log"))
+	w.Write([]byte("nine\n"))
+	w.Flush(time.Second)
+	clock.Add(45 * time.Minute)
+	w.Write([]byte("ten\n"))
+	w.Flush(time.Second)
+	if got := readFile(t, filepath.Join(dir, "app.2026030109.log")); got != "nine\n" {
+		t.Fatalf("09 = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.2026030110.log")); got != "ten\n" {
+		t.Fatalf("10 = %q", got)
+	}
+}
+
+func TestFakeClockBufferIdleFlush(t *testing.T) {
+	clock := h2sanlogtest.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	w, dir := newClockWriter(t, clock, 0, h2sanlog.WithBufferedWrites(4096, time.Second))
+	path := filepath.Join(dir, "app.2026-03-01.log")
+	w.Write([]byte("buffered\n"))
+	// 消费者写入缓冲后才开始计算空闲时间
+	time.Sleep(10 * time.Millisecond)
+	if got := readFile(t, path); got != "" {
+		t.Fatalf("written before idle: %q", got)
+	}
+	advanceUntil(t, clock, 500*time.Millisecond, "idle flush", func() bool { return readFile(t, path) == "buffered\n" })
+}
+
+// failOnce 轮转到 dir，dir 不存在时重命名失败
+type failOnce struct{ dir string }
+
+func (p failOnce) RotateName(active string, existing []string) string {
+	return filepath.Join(p.dir, filepath.Base(active)+".full.1.log")
+}
+
+// 轮转失败后继续写原文件，一分钟内不再重试，一分钟后重试成功
+func TestFakeClockRotateRetryAfterMinute(t *testing.T) {
+	clock := h2sanlogtest.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	missing := filepath.Join(t.TempDir(), "later")
+	w, dir := newClockWriter(t, clock, 10, h2sanlog.WithRotationPolicy(failOnce{missing}),
+		h2sanlog.WithOnError(func(error) {}))
+	active := filepath.Join(dir, "app.2026-03-01.log")
+	rotated := filepath.Join(missing, "app.2026-03-01.log.full.1.log")
+	for _, s := range []string{"12345678\n", "abcdefgh\n"} {
+		w.Write([]byte(s))
+		w.Flush(time.Second)
+	}
+	// 降级事件也会写入当前文件
+	if got := readFile(t, active); !strings.HasPrefix(got, "12345678\nabcdefgh\n") || !strings.Contains(got, "event=rotate_failed") {
+		t.Fatalf("after failed rotation active = %q", got)
+	}
+	if err := os.Mkdir(missing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(30 * time.Second)
+	w.Write([]byte("retry?\n"))
+	w.Flush(time.Second)
+	if exists(rotated) {
+		t.Fatal("retried within a minute")
+	}
+	clock.Add(31 * time.Second)
+	w.Write([]byte("after\n"))
+	w.Flush(time.Second)
+	if !exists(rotated) {
+		t.Fatal("not retried after a minute")
+	}
+	if got := readFile(t, active); got != "after\n" {
+		t.Fatalf("active after retry = %q", got)
+	}
+}
+
+// 批量写的刷新间隔按注入的时钟计时
+func TestFakeClockBatchInterval(t *testing.T) {
+	clock := h2sanlogtest.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	w, dir := newClockWriter(t, clock, 0, h2sanlog.WithBatch(4096, time.Hour))
+	path := filepath.Join(dir, "app.2026-03-01.log")
+	w.Write([]byte("batched\n"))
+	time.Sleep(10 * time.Millisecond)
+	if got := readFile(t, path); got != "" {
+		t.Fatalf("written before interval: %q", got)
+	}
+	advanceUntil(t, clock, 10*time.Minute, "batch flush", func() bool { return readFile(t, path) == "batched\n" })
+}
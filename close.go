@@ -0,0 +1,123 @@
+package h2sanlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Sync 把队列中已有的日志写盘并 fsync，实现 zapcore.WriteSyncer，可在检查点强制落盘
+func (w *FileWriter) Sync() error {
+	return w.Flush(exitTimeout)
+}
+
+// Close 写完队列中的日志后关闭文件并停止后台goroutine，实现 io.Closer；关闭后 Write 返回 ErrClosed。
+// 同路径共享的 writer 只在最后一个引用 Close 时关闭
+func (w *FileWriter) Close() error {
+	if atomic.LoadInt32(&w.closed) == 1 {
+		return ErrClosed
+	}
+	if !releaseShared(w) {
+		return nil
+	}
+	if !atomic.CompareAndSwapInt32(&w.closed, 0, 1) {
+		return ErrClosed
+	}
+	unregister(w)
+	close(w.done)
+	deadline := time.Now().Add(exitTimeout)
+	for !w.queue.push(closeMarker) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	t := time.NewTimer(time.Until(deadline))
+	select {
+	case <-w.stopped:
+	case <-t.C:
+	}
+	t.Stop()
+	w.discardQueued()
+	if w.manifest != nil {
+		w.manifest.wg.Wait()
+	}
+	w.lock()
+	defer w.mu.Unlock()
+	w.syncFile()
+	w.closeSpill()
+	w.trimPrealloc()
+	w.removeSpare()
+	err := w.file.Close()
+	if w.lockFile != nil {
+		w.lockFile.Close()
+	}
+	return err
+}
+
+// discardQueued 后台goroutine退出后丢弃队列中剩余的日志，归还内存预算和缓冲，返回丢弃的条数；
+// Close 之前已通过检查的 Write 可能在关闭标记之后入队，这些日志不会再被写出
+func (w *FileWriter) discardQueued() int {
+	select {
+	case <-w.stopped:
+	default:
+		return 0
+	}
+	w.discardMu.Lock()
+	defer w.discardMu.Unlock()
+	n := 0
+	for {
+		b, ok := w.queue.tryPop()
+		if !ok {
+			break
+		}
+		if b == flushMarker || b == closeMarker {
+			continue
+		}
+		releaseMem(len(*b))
+		putBuf(b)
+		n++
+	}
+	atomic.AddUint64(&w.counters.dropped, uint64(n))
+	return n
+}
+
+// Sync 两个文件都写盘并 fsync
+func (s *SplitWriter) Sync() error {
+	err := s.main.Sync()
+	if e := s.errs.Sync(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// Close 关闭两个文件
+func (s *SplitWriter) Close() error {
+	err := s.main.Close()
+	if e := s.errs.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// Sync 对实现了 Sync() error 的 sink 调用 Sync
+func (m *MultiWriter) Sync() error {
+	var err error
+	for _, s := range m.sinks {
+		if sy, ok := s.w.(interface{ Sync() error }); ok {
+			if e := sy.Sync(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// Close 关闭实现了 io.Closer 的 sink
+func (m *MultiWriter) Close() error {
+	var err error
+	for _, s := range m.sinks {
+		if c, ok := s.w.(interface{ Close() error }); ok {
+			if e := c.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"strconv"
+)
+
+// CompressedEncoding JSONEncoder 压缩后的字段值里 encoding 的取值
+const CompressedEncoding = "gzip+base64"
+
+// largeValue 返回需要压缩的字段内容，只处理 string 和 []byte
+func largeValue(f Field, threshold int) ([]byte, bool) {
+	if threshold <= 0 {
+		return nil, false
+	}
+	if f.kind == kindString {
+		if len(f.str) > threshold {
+			return []byte(f.str), true
+		}
+		return nil, false
+	}
+	switch x := f.Value.(type) {
+	case string:
+		if len(x) > threshold {
+			return []byte(x), true
+		}
+	case []byte:
+		if len(x) > threshold {
+			return x, true
+		}
+	}
+	return nil, false
+}
+
+// writeCompressed 写入压缩后的字段值: {"encoding":"gzip+base64","size":原始字节数,"data":"..."}
+func writeCompressed(b *bytes.Buffer, data []byte) error {
+	var z bytes.Buffer
+	zw := gzip.NewWriter(&z)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	b.WriteString(`{"encoding":"` + CompressedEncoding + `","size":`)
+	b.WriteString(strconv.Itoa(len(data)))
+	b.WriteString(`,"data":"`)
+	b.WriteString(base64.StdEncoding.EncodeToString(z.Bytes()))
+	b.WriteString(`"}`)
+	return nil
+}
+
+// DecodeCompressed 还原 JSONEncoder 压缩的字段，data 为其中 "data" 的值
+func DecodeCompressed(data string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
@@ -0,0 +1,224 @@
+package h2sanlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 声明式日志配置
+type Config struct {
+	// Level 最低输出级别，为空时为 debug
+	Level string `json:"level" yaml:"level"`
+	// Name logger 名
+	Name string `json:"name" yaml:"name"`
+	// Caller 输出调用位置
+	Caller bool `json:"caller" yaml:"caller"`
+	// Output 默认输出的 sink 名，为空时输出到 stderr
+	Output string `json:"output" yaml:"output"`
+	// Sinks sink 名到 sink 配置的映射，供 Output 和 Routes 引用
+	Sinks map[string]SinkConfig `json:"sinks" yaml:"sinks"`
+	// Routes 路由规则，日志命中的每条规则都会写入对应的 sink，全部未命中写入默认输出
+	Routes []RouteConfig `json:"routes" yaml:"routes"`
+	// Schedule 按时间段调整级别和采样，如白天 debug、夜间批处理窗口只输出 warn
+	Schedule []ScheduleWindow `json:"schedule" yaml:"schedule"`
+	// Container 容器模式：on 开启、auto 在 InContainer 时开启，为空或 off 关闭。
+	// 开启后 file 类型的 sink 和默认输出改为以 JSON 行写 stdout，日志附带 ContainerFields
+	Container string `json:"container" yaml:"container"`
+	// Host 日志附带 HostFields，App Version Instance 为其中的 app、version、instance，为空时取默认值
+	Host     bool   `json:"host" yaml:"host"`
+	App      string `json:"app" yaml:"app"`
+	Version  string `json:"version" yaml:"version"`
+	Instance string `json:"instance" yaml:"instance"`
+}
+
+// containerMode 判断配置是否开启容器模式
+func (c *Config) containerMode() (bool, error) {
+	switch strings.ToLower(c.Container) {
+	case "", "off", "false":
+		return false, nil
+	case "on", "true":
+		return true, nil
+	case "auto":
+		return InContainer(), nil
+	}
+	return false, fmt.Errorf("h2sanlog: unknown container mode %q", c.Container)
+}
+
+// RouteConfig 一条路由规则，例如
+//
+//	match: level>=warn && fields.tenant=="acme"
+//	sink:  s3-acme
+type RouteConfig struct {
+	Match string `json:"match" yaml:"match"`
+	Sink  string `json:"sink" yaml:"sink"`
+}
+
+// SinkConfig 一个输出，例如
+//
+//	type: file
+//	path: logs/app
+//	max_size: 104857600
+//	max_num: 10
+//	encoder: json
+type SinkConfig struct {
+	// Type 为 file、stdout、stderr 或 RegisterSink 注册的类型，为空时有 Path 即为 file
+	Type string `json:"type" yaml:"type"`
+	// Path MaxSize MaxNum 同 NewFileWriter 的参数
+	Path    string `json:"path" yaml:"path"`
+	MaxSize int64  `json:"max_size" yaml:"max_size"`
+	MaxNum  int    `json:"max_num" yaml:"max_num"`
+	// MaxTotalSize 同 WithMaxTotalSize
+	MaxTotalSize int64 `json:"max_total_size" yaml:"max_total_size"`
+	// DailyDirs 同 WithDailyDirs
+	DailyDirs bool `json:"daily_dirs" yaml:"daily_dirs"`
+	// DateDirs 同 WithDateDirs
+	DateDirs bool `json:"date_dirs" yaml:"date_dirs"`
+	// UTC 同 WithUTC
+	UTC bool `json:"utc" yaml:"utc"`
+	// Pattern 同 WithFilePattern
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// Encoder 为 text、json 或 msgpack，为空时为 text
+	Encoder string `json:"encoder" yaml:"encoder"`
+	// Options RegisterSink 注册的类型自定义的参数，如 kafka 的 brokers、topic
+	Options map[string]interface{} `json:"options" yaml:"options"`
+}
+
+// LoadConfig 读取配置文件，扩展名为 .yaml/.yml 时按 YAML 解析，否则按 JSON 解析
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	default:
+		err = json.Unmarshal(data, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("h2sanlog: parse config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// NewFromConfigFile 读取配置文件并创建 Logger，见 LoadConfig 和 NewFromConfig
+func NewFromConfigFile(path string) (*Logger, error) {
+	c, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(c)
+}
+
+// NewFromConfig 按配置创建 Logger 及其 sink。创建的 FileWriter 和 RegisterSink 注册类型的 sink 都已注册，
+// 进程退出前调用 FlushAll 或 Exit 写盘，CloseAll 关闭
+func NewFromConfig(c *Config) (*Logger, error) {
+	sinks := make(map[string]io.Writer, len(c.Sinks))
+	var opened []Sink
+	fail := func(err error) (*Logger, error) {
+		for _, s := range opened {
+			s.Close()
+		}
+		return nil, err
+	}
+	container, err := c.containerMode()
+	if err != nil {
+		return nil, err
+	}
+	for name, sc := range c.Sinks {
+		if container && (strings.ToLower(sc.Type) == "file" || sc.Type == "" && sc.Path != "") {
+			sc = SinkConfig{Type: "stdout", Encoder: "json"}
+		}
+		w, s, err := sc.open()
+		if err != nil {
+			return fail(fmt.Errorf("h2sanlog: sink %q: %w", name, err))
+		}
+		opened = append(opened, s)
+		sinks[name] = w
+	}
+	var out io.Writer = os.Stderr
+	if container {
+		out = NewMultiWriter().Add(os.Stdout, JSONEncoder{})
+	}
+	if c.Output != "" {
+		w, ok := sinks[c.Output]
+		if !ok {
+			return fail(fmt.Errorf("h2sanlog: output: unknown sink %q", c.Output))
+		}
+		out = w
+	}
+	l := New(out, "", log.LstdFlags)
+	if c.Level != "" {
+		level, err := ParseLevel(c.Level)
+		if err != nil {
+			return fail(err)
+		}
+		l.SetLevel(level)
+	}
+	l.name = c.Name
+	if container {
+		l = l.WithFields(ContainerFields()...)
+	}
+	if c.Host || c.App != "" || c.Version != "" || c.Instance != "" {
+		l = l.WithHostFields(c.App, c.Version, c.Instance)
+	}
+	l.SetCaller(c.Caller, 0)
+	if len(c.Routes) > 0 {
+		r, err := NewRouter(c.Routes, sinks)
+		if err != nil {
+			return fail(err)
+		}
+		l.SetRouter(r)
+	}
+	if len(c.Schedule) > 0 {
+		s, err := NewSchedule(c.Schedule, nil)
+		if err != nil {
+			return fail(err)
+		}
+		l.SetSchedule(s)
+	}
+	for _, s := range opened {
+		trackSink(s)
+	}
+	return l, nil
+}
+
+// open 创建 sink 的 writer，同时返回编码之前的 Sink
+func (sc SinkConfig) open() (io.Writer, Sink, error) {
+	var enc Encoder
+	switch strings.ToLower(sc.Encoder) {
+	case "", "text":
+	case "json":
+		enc = JSONEncoder{}
+	case "msgpack":
+		enc = MsgpackEncoder{}
+	default:
+		return nil, nil, fmt.Errorf("unknown encoder %q", sc.Encoder)
+	}
+	typ := strings.ToLower(sc.Type)
+	if typ == "" && sc.Path != "" {
+		typ = "file"
+	}
+	f, ok := lookupSink(typ)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown sink type %q", sc.Type)
+	}
+	s, err := f(sc)
+	if err != nil {
+		return nil, nil, err
+	}
+	var w io.Writer = s
+	if enc != nil {
+		w = NewMultiWriter().Add(w, enc)
+	}
+	return w, s, nil
+}
@@ -0,0 +1,118 @@
+package h2sanlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+level: info
+name: api
+output: main
+sinks:
+  main:
+    path: %DIR%/app
+    pattern: app.log
+    encoder: json
+  audit:
+    type: file
+    path: %DIR%/audit
+    pattern: audit.log
+routes:
+  - match: fields.audit==true
+    sink: audit
+`
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	c := &Config{Level: "warn", Sinks: map[string]SinkConfig{"f": {Path: "logs/app", MaxSize: 1024, Encoder: "json"}},
+		Routes: []RouteConfig{{Match: "level>=error", Sink: "f"}}}
+	b, _ := json.Marshal(c)
+	jsonPath := filepath.Join(dir, "log.json")
+	yamlPath := filepath.Join(dir, "log.yml")
+	ioutil.WriteFile(jsonPath, b, 0644)
+	ioutil.WriteFile(yamlPath, []byte(strings.ReplaceAll(yamlConfig, "%DIR%", dir)), 0644)
+	got, err := LoadConfig(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Level != "warn" || got.Sinks["f"].MaxSize != 1024 || got.Routes[0].Sink != "f" {
+		t.Fatalf("json config = %+v", got)
+	}
+	got, err = LoadConfig(yamlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "api" || got.Sinks["main"].Pattern != "app.log" || got.Sinks["audit"].Type != "file" ||
+		got.Routes[0].Match != "fields.audit==true" {
+		t.Fatalf("yaml config = %+v", got)
+	}
+	ioutil.WriteFile(jsonPath, []byte("level: info"), 0644)
+	if _, err := LoadConfig(jsonPath); err == nil || !strings.Contains(err.Error(), "parse config") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestNewFromConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.yaml")
+	ioutil.WriteFile(path, []byte(strings.ReplaceAll(yamlConfig, "%DIR%", dir)), 0644)
+	l, err := NewFromConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseAll()
+	l.Log(LogLevelDebug, "filtered")
+	l.Log(LogLevelInfo, "hello", Int("n", 1))
+	l.Log(LogLevelInfo, "login", Bool("audit", true))
+	if err := FlushAll(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	main := readFile(t, filepath.Join(dir, "app.log"))
+	if strings.Contains(main, "filtered") || strings.Contains(main, "login") ||
+		!strings.Contains(main, `"logger":"api","msg":"hello","n":1`) {
+		t.Fatalf("main = %q", main)
+	}
+	if audit := readFile(t, filepath.Join(dir, "audit.log")); !strings.HasSuffix(audit, "[INFO] [api] audit=true login\n") {
+		t.Fatalf("audit = %q", audit)
+	}
+}
+
+func TestNewFromConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := SinkConfig{Path: filepath.Join(dir, "app")}
+	for _, c := range []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Level: "loud"}, "unknown level"},
+		{Config{Container: "maybe"}, "unknown container mode"},
+		{Config{Sinks: map[string]SinkConfig{"f": {Path: file.Path, Encoder: "xml"}}}, `sink "f": unknown encoder "xml"`},
+		{Config{Output: "nope", Sinks: map[string]SinkConfig{"f": file}}, `output: unknown sink "nope"`},
+		{Config{Sinks: map[string]SinkConfig{"f": file}, Routes: []RouteConfig{{Match: "level>=warn", Sink: "nope"}}}, "nope"},
+		{Config{Schedule: []ScheduleWindow{{Start: "9", End: "18:00"}}}, "bad time"},
+	} {
+		_, err := NewFromConfig(&c.cfg)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%+v: err = %v, want %q", c.cfg, err, c.want)
+		}
+	}
+	// 出错时已创建的 sink 都已关闭，不会被 FlushAll、CloseAll 跟踪
+	if n := len(trackedSinks(false)); n != 0 {
+		t.Fatalf("%d sinks tracked after failed configs", n)
+	}
+}
+
+// readFile 读取文件内容
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,65 @@
+package h2sanlog
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// serviceAccountNamespace Kubernetes 挂载的 service account 所在命名空间
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// InContainer 判断是否运行在 Kubernetes 或 docker 等容器中
+func InContainer() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return true
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return true
+	}
+	data, err := ioutil.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	s := string(data)
+	return strings.Contains(s, "docker") || strings.Contains(s, "kubepods") || strings.Contains(s, "containerd")
+}
+
+// ContainerFields 返回容器的资源字段 pod、namespace、node、container，取自 downward API 注入的环境变量
+// POD_NAME、POD_NAMESPACE、NODE_NAME、CONTAINER_NAME（也接受 K8S_ 前缀），pod 缺省为 HOSTNAME，
+// namespace 缺省读取 service account 的命名空间；取不到的字段不输出
+func ContainerFields() []Field {
+	var fields []Field
+	add := func(key string, values ...string) {
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				fields = append(fields, Field{Key: key, Value: v})
+				return
+			}
+		}
+	}
+	env := func(names ...string) []string {
+		values := make([]string, len(names))
+		for i, n := range names {
+			values[i] = os.Getenv(n)
+		}
+		return values
+	}
+	add("pod", env("POD_NAME", "K8S_POD_NAME", "HOSTNAME")...)
+	ns, _ := ioutil.ReadFile(serviceAccountNamespace)
+	add("namespace", append(env("POD_NAMESPACE", "K8S_NAMESPACE", "K8S_POD_NAMESPACE"), string(ns))...)
+	add("node", env("NODE_NAME", "K8S_NODE_NAME")...)
+	add("container", env("CONTAINER_NAME", "K8S_CONTAINER_NAME")...)
+	return fields
+}
+
+// NewContainerLogger 返回以 JSON 行输出到 stdout 并附带 ContainerFields 的 Logger，
+// 由容器运行时和日志采集端负责落盘和轮转
+func NewContainerLogger() *Logger {
+	l := New(NewMultiWriter().Add(os.Stdout, JSONEncoder{}), "", log.LstdFlags)
+	return l.WithFields(ContainerFields()...)
+}
@@ -0,0 +1,110 @@
+package h2sanlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// containerEnv 清除 downward API 变量后按 kv 设置
+func containerEnv(t *testing.T, kv map[string]string) {
+	for _, k := range []string{"POD_NAME", "K8S_POD_NAME", "HOSTNAME", "POD_NAMESPACE", "K8S_NAMESPACE",
+		"K8S_POD_NAMESPACE", "NODE_NAME", "K8S_NODE_NAME", "CONTAINER_NAME", "K8S_CONTAINER_NAME"} {
+		t.Setenv(k, kv[k])
+	}
+}
+
+func fieldMap(fields []Field) map[string]interface{} {
+	m := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		m[f.Key] = f.Interface()
+	}
+	return m
+}
+
+func TestContainerFields(t *testing.T) {
+	containerEnv(t, map[string]string{"POD_NAME": "web-7f9c", "HOSTNAME": "ignored", "K8S_NAMESPACE": "shop",
+		"K8S_NODE_NAME": " node-3 ", "CONTAINER_NAME": "app"})
+	got := fieldMap(ContainerFields())
+	want := map[string]interface{}{"pod": "web-7f9c", "namespace": "shop", "node": "node-3", "container": "app"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	// pod 缺省为 HOSTNAME，取不到的字段不输出
+	containerEnv(t, map[string]string{"HOSTNAME": "host-1"})
+	got = fieldMap(ContainerFields())
+	if got["pod"] != "host-1" {
+		t.Fatalf("pod = %v", got["pod"])
+	}
+	for _, k := range []string{"node", "container"} {
+		if _, ok := got[k]; ok {
+			t.Fatalf("%s present without env", k)
+		}
+	}
+}
+
+func TestInContainerKubernetes(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	if !InContainer() {
+		t.Fatal("KUBERNETES_SERVICE_HOST not detected")
+	}
+}
+
+// captureStdout 把 os.Stdout 换成临时文件，返回读取已写内容的函数
+func captureStdout(t *testing.T) func() string {
+	f, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = f
+	t.Cleanup(func() {
+		os.Stdout = old
+		f.Close()
+	})
+	return func() string {
+		b, _ := ioutil.ReadFile(f.Name())
+		return string(b)
+	}
+}
+
+func TestNewContainerLogger(t *testing.T) {
+	containerEnv(t, map[string]string{"POD_NAME": "web-1", "POD_NAMESPACE": "shop"})
+	out := captureStdout(t)
+	NewContainerLogger().Log(LogLevelInfo, "ready", Int("port", 80))
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(out()), &got); err != nil {
+		t.Fatalf("stdout %q: %v", out(), err)
+	}
+	for k, v := range map[string]interface{}{"msg": "ready", "port": 80.0, "pod": "web-1", "namespace": "shop"} {
+		if got[k] != v {
+			t.Fatalf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+// 容器模式下 file 类型的 sink 改为以 JSON 行写 stdout，不创建日志文件
+func TestConfigContainerMode(t *testing.T) {
+	containerEnv(t, map[string]string{"POD_NAME": "web-2"})
+	out := captureStdout(t)
+	dir := t.TempDir()
+	l, err := NewFromConfig(&Config{Container: "on", Output: "main", Sinks: map[string]SinkConfig{
+		"main": {Path: filepath.Join(dir, "app"), Encoder: "text"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseAll()
+	l.Log(LogLevelWarning, "to stdout")
+	if got := out(); !strings.Contains(got, `"msg":"to stdout","pod":"web-2"`) {
+		t.Fatalf("stdout = %q", got)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("files created in container mode: %d", len(files))
+	}
+}
@@ -0,0 +1,59 @@
+package h2sanlog
+
+import (
+	"context"
+	"sync"
+)
+
+// TraceIDKey SpanIDKey 链路追踪 ID 的字段名，OTLPExporter 把它们映射为 log record 的 traceId/spanId
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// ContextExtractor 从 context 中取出需要附带到日志的字段，如当前 span 的 trace_id、span_id，没有时返回 nil
+type ContextExtractor func(ctx context.Context) []Field
+
+var contextExtractors struct {
+	sync.RWMutex
+	fns []ContextExtractor
+}
+
+// RegisterContextExtractor 注册全局的 context 字段提取器，WithContext 和 LogContext 按注册顺序调用，
+// 如 oteladapter.Register 注册从 OpenTelemetry span 中提取 trace_id/span_id
+func RegisterContextExtractor(fn ContextExtractor) {
+	contextExtractors.Lock()
+	contextExtractors.fns = append(contextExtractors.fns, fn)
+	contextExtractors.Unlock()
+}
+
+// contextFields 依次调用所有提取器，ctx 为 nil 时返回 nil
+func contextFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+	contextExtractors.RLock()
+	defer contextExtractors.RUnlock()
+	var fields []Field
+	for _, fn := range contextExtractors.fns {
+		fields = append(fields, fn(ctx)...)
+	}
+	return fields
+}
+
+// WithContext 返回附带 ctx 中提取出的字段的子logger，没有字段时返回 l 本身
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	fields := contextFields(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.WithFields(fields...)
+}
+
+// LogContext 同 Log，附带 ctx 中提取出的字段
+func (l *Logger) LogContext(ctx context.Context, level uint8, msg string, fields ...Field) error {
+	if extra := contextFields(ctx); len(extra) > 0 {
+		fields = append(extra, fields...)
+	}
+	return l.output(level, msg, false, nil, fields)
+}
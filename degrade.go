@@ -0,0 +1,99 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// MetaLoggerName 包内降级事件的 logger 名，方便在日志中检索
+const MetaLoggerName = "h2sanlog"
+
+// WithMetaEncoder 设置降级事件写入日志文件时使用的编码，默认 TextEncoder{Flag: log.LstdFlags}，
+// 文件为 JSON 格式时应设置为 JSONEncoder
+func WithMetaEncoder(enc Encoder) FileOption {
+	return func(w *FileWriter) {
+		w.metaEnc = enc
+	}
+}
+
+// WithMetaMirror 降级事件写入日志文件的同时按同样的编码写到 out，日志文件本身写不进去时也能看到，out 为 nil 时不另写。
+// 默认设置了 WithOnError 时写到 stderr（默认的 onError 已输出到 stderr），作为 NewFailoverWriter 的主 sink 时写到备用 sink
+func WithMetaMirror(out io.Writer) FileOption {
+	return func(w *FileWriter) {
+		w.metaMirror.Store(metaOut{out})
+	}
+}
+
+// metaOut 降级事件另写的 writer，包一层使 atomic.Value 中的类型一致
+type metaOut struct{ w io.Writer }
+
+// mirror 返回降级事件另写的 writer，nil 表示不另写
+func (w *FileWriter) mirror() io.Writer {
+	if m, ok := w.metaMirror.Load().(metaOut); ok {
+		return m.w
+	}
+	if w.ownOnError {
+		return os.Stderr
+	}
+	return nil
+}
+
+// degrade 报告降级事件：先交给 WithOnError 回调，再以 logger=h2sanlog 的 WARNING 日志写入当前文件并另写一份到 mirror，
+// 让轮转失败、磁盘保护清理、丢日志等情况在日志本身里可见
+func (w *FileWriter) degrade(event string, err error) {
+	w.onError(err)
+	w.emitMeta(event, err.Error(), nil)
+}
+
+// emitMeta 把降级事件编码后直接入队，不受磁盘保护的停写限制
+func (w *FileWriter) emitMeta(event, msg string, fields []Field) {
+	enc := w.metaEnc
+	if enc == nil {
+		enc = TextEncoder{Flag: log.LstdFlags}
+	}
+	e := &Entry{Time: time.Now(), Level: LogLevelWarning, Name: MetaLoggerName, Message: msg,
+		Fields: append([]Field{{Key: "event", Value: event}}, fields...)}
+	data, err := enc.Encode(e)
+	if err != nil {
+		return
+	}
+	if out := w.mirror(); out != nil {
+		out.Write(data)
+	}
+	if atomic.LoadInt32(&w.closed) == 1 || !acquireMem(len(data)) {
+		return
+	}
+	buf := getBuf()
+	*buf = append(*buf, data...)
+	if !w.queue.push(buf) {
+		releaseMem(len(data))
+		putBuf(buf)
+		return
+	}
+	if atomic.LoadInt32(&w.closed) == 1 {
+		w.discardQueued()
+	}
+}
+
+// startDropping 队列满开始丢日志时记录起点，只在进入丢弃状态时报告一次
+func (w *FileWriter) startDropping() {
+	if atomic.CompareAndSwapInt32(&w.dropping, 0, 1) {
+		atomic.StoreUint64(&w.dropBase, atomic.LoadUint64(&w.counters.dropped))
+		w.onError(fmt.Errorf("queue full file:%s, start dropping: %w", w.fileName, ErrQueueFull))
+	}
+}
+
+// checkDropping 消费者写盘后调用，队列回落到一半以下时写入一条丢弃汇总
+func (w *FileWriter) checkDropping() {
+	if atomic.LoadInt32(&w.dropping) == 0 || w.queue.len() > w.queue.cap()/2 {
+		return
+	}
+	if atomic.CompareAndSwapInt32(&w.dropping, 1, 0) {
+		n := atomic.LoadUint64(&w.counters.dropped) - atomic.LoadUint64(&w.dropBase)
+		w.emitMeta("drop", "queue full, entries dropped", []Field{{Key: "dropped", Value: n}})
+	}
+}
@@ -0,0 +1,63 @@
+package h2sanlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// ErrDiskFull 日志盘剩余空间低于阈值，停止写日志
+var ErrDiskFull = errors.New("h2sanlog: disk space below threshold, drop")
+
+// WithDiskGuard 每次检查时监控日志盘剩余空间，低于 minFree 字节时：
+// purge 为 true 先从最旧的文件开始删除已轮转的日志直到空间恢复，
+// 仍不足（或 purge 为 false）时停止写入并通过 WithOnError 回调报告，空间恢复后自动继续写
+func WithDiskGuard(minFree uint64, purge bool) FileOption {
+	return func(w *FileWriter) {
+		w.minFree = minFree
+		w.purge = purge
+	}
+}
+
+// freeSpace 返回 path 所在文件系统的剩余空间，测试时替换
+var freeSpace = diskFree
+
+// guardDisk 检查剩余空间，调用方需持有锁
+func (w *FileWriter) guardDisk() {
+	if w.minFree == 0 {
+		return
+	}
+	dir := filepath.Dir(w.filePath)
+	free, err := freeSpace(dir)
+	if err != nil {
+		w.onError(fmt.Errorf("statfs path:%s fail:%w", dir, err))
+		return
+	}
+	if free >= w.minFree {
+		if atomic.CompareAndSwapInt32(&w.diskFull, 1, 0) {
+			w.degrade("disk_recovered", fmt.Errorf("disk space recovered path:%s free:%d, resume writing", dir, free))
+		}
+		return
+	}
+	if w.purge {
+		for _, f := range w.logFiles() {
+			if f.path == w.filePath {
+				continue
+			}
+			if err := os.Remove(f.path); err != nil {
+				w.onError(fmt.Errorf("purge file path:%s fail:%w", f.path, err))
+				continue
+			}
+			w.removed(f.path)
+			w.degrade("disk_purge", fmt.Errorf("disk space low path:%s free:%d, purged %s", dir, free, f.path))
+			if free, err = freeSpace(dir); err != nil || free >= w.minFree {
+				return
+			}
+		}
+	}
+	if atomic.CompareAndSwapInt32(&w.diskFull, 0, 1) {
+		w.degrade("disk_stop", fmt.Errorf("disk space low path:%s free:%d < %d, stop writing", dir, free, w.minFree))
+	}
+}
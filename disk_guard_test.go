@@ -0,0 +1,94 @@
+package h2sanlog
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeFree 替换 freeSpace，返回 *free 的当前值
+func fakeFree(t *testing.T, free *uint64) {
+	old := freeSpace
+	freeSpace = func(string) (uint64, error) { return atomic.LoadUint64(free), nil }
+	t.Cleanup(func() { freeSpace = old })
+}
+
+// checkDisk 立即执行一次磁盘检查，不等每分钟的定时检查
+func checkDisk(w *FileWriter) {
+	w.lock()
+	w.guardDisk()
+	w.mu.Unlock()
+}
+
+func TestDiskGuardStopAndResume(t *testing.T) {
+	free := uint64(1000)
+	fakeFree(t, &free)
+	var errs errorLog
+	w := newTestWriter(t, 0, 0, WithDiskGuard(100, false), WithOnError(errs.fn))
+	writeLines(w, "before\n")
+	atomic.StoreUint64(&free, 50)
+	checkDisk(w)
+	if _, err := w.Write([]byte("dropped\n")); !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("err = %v, want ErrDiskFull", err)
+	}
+	if st := w.Stats(); st.Dropped != 1 {
+		t.Fatalf("Dropped = %d", st.Dropped)
+	}
+	atomic.StoreUint64(&free, 200)
+	checkDisk(w)
+	writeLines(w, "after\n")
+	// 停写和恢复的事件不受停写限制，写入日志文件
+	got := activeContent(t, w)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 4 || lines[0] != "before" || !strings.Contains(lines[1], "event=disk_stop") ||
+		!strings.Contains(lines[2], "event=disk_recovered") || lines[3] != "after" {
+		t.Fatalf("got %q", got)
+	}
+	if e := errs.get(); len(e) != 2 || !strings.Contains(e[0].Error(), "free:50 < 100") {
+		t.Fatalf("errors = %v", e)
+	}
+}
+
+// purge 时从最旧的文件开始删除已轮转的日志，空间恢复后停止删除，不停写
+func TestDiskGuardPurge(t *testing.T) {
+	free := uint64(1000)
+	fakeFree(t, &free)
+	var removed []string
+	w := newTestWriter(t, 0, 0, WithFilePattern("app.log"), WithDiskGuard(100, true), WithOnError(func(error) {}),
+		WithOnRemove(func(path string) {
+			removed = append(removed, filepath.Base(path))
+			atomic.AddUint64(&free, 30)
+		}))
+	// 手动轮转，降级事件写入当前文件时不会再触发轮转
+	for _, s := range []string{"line-001\n", "line-002\n", "line-003\n"} {
+		writeLines(w, s)
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	atomic.StoreUint64(&free, 50)
+	checkDisk(w)
+	if want := []string{"app.log.full.1.log", "app.log.full.2.log"}; !stringsEqual(removed, want) {
+		t.Fatalf("removed = %v, want %v", removed, want)
+	}
+	if _, err := w.Write([]byte("ok\n")); err != nil {
+		t.Fatalf("write after purge: %v", err)
+	}
+	names, _ := dirFiles(t, filepath.Dir(w.fileName))
+	if want := []string{"app.log", "app.log.full.3.log"}; !stringsEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	// 删完所有已轮转的日志仍不够时停写，当前文件不删
+	atomic.StoreUint64(&free, 0)
+	checkDisk(w)
+	if _, err := w.Write([]byte("full\n")); !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("err = %v, want ErrDiskFull", err)
+	}
+	w.Flush(time.Second)
+	if names, _ := dirFiles(t, filepath.Dir(w.fileName)); !stringsEqual(names, []string{"app.log"}) {
+		t.Fatalf("files = %v", names)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package h2sanlog
+
+import "errors"
+
+// diskFree 当前平台不支持查询剩余空间
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("disk free space not supported on this platform")
+}
@@ -0,0 +1,55 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// benchLine 100 字节左右的一行日志
+var benchLine = []byte("2026/03/01 12:00:00 [INFO] request done method=GET path=/api/v1/items status=200 cost=1.2ms\n")
+
+// benchWriter 队列满时让出 CPU 重试，不丢日志，ns/op 为一条日志从 Write 到写盘的平均耗时
+func benchWriter(b *testing.B, opts ...FileOption) {
+	b.Helper()
+	opts = append([]FileOption{WithRingBuffer(1 << 14), WithOnError(func(error) {})}, opts...)
+	w, err := NewFileWriter(filepath.Join(b.TempDir(), "bench"), 0, 0, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer w.Close()
+	b.SetBytes(int64(len(benchLine)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for {
+				if _, err := w.Write(benchLine); err != ErrQueueFull {
+					break
+				}
+				runtime.Gosched()
+			}
+		}
+	})
+	if err := w.Flush(time.Minute); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkDrainBatch(b *testing.B) {
+	for _, n := range []int{1, 8, 64, 128, 256, 512, 1024} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) { benchWriter(b, WithDrainBatch(n)) })
+	}
+}
+
+func BenchmarkBatch(b *testing.B) {
+	for _, size := range []int{4 << 10, 64 << 10, 256 << 10} {
+		for _, interval := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 200 * time.Millisecond} {
+			b.Run(fmt.Sprintf("size=%d/interval=%s", size, interval), func(b *testing.B) {
+				benchWriter(b, WithBatch(size, interval))
+			})
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encoder 把 Entry 编码成以换行结尾的一条日志
+type Encoder interface {
+	Encode(e *Entry) ([]byte, error)
+}
+
+// EntryWriter 接收结构化日志的 writer，Logger 的输出实现该接口时直接交给它编码
+type EntryWriter interface {
+	WriteEntry(e *Entry) error
+}
+
+// TextEncoder 文本格式，与 Logger 默认输出一致: <prefix><时间> [INFO] [name] k=v msg，
+// Flag 同标准库 log 的 flag，Lshortfile/Llongfile 需要 logger 开启 SetCaller
+type TextEncoder struct {
+	Prefix string
+	Flag   int
+	// Time 不为空时代替 Flag 中的日期和时间，编码后加一个空格写在行首（Prefix 之后）
+	Time TimeEncoder
+	// Multiline 换行的处理方式，同 Logger.SetMultiline
+	Multiline Multiline
+}
+
+func (enc TextEncoder) Encode(e *Entry) ([]byte, error) {
+	var b bytes.Buffer
+	if enc.Flag&log.Lmsgprefix == 0 {
+		b.WriteString(enc.Prefix)
+	}
+	flag := enc.Flag
+	if enc.Time != nil {
+		t := e.Time
+		if flag&log.LUTC != 0 {
+			t = t.UTC()
+		}
+		var tmp [64]byte
+		b.Write(enc.Time(tmp[:0], t))
+		b.WriteByte(' ')
+		flag &^= log.Ldate | log.Ltime | log.Lmicroseconds
+	}
+	appendHeader(&b, flag, e)
+	if enc.Flag&log.Lmsgprefix != 0 {
+		b.WriteString(enc.Prefix)
+	}
+	b.WriteString(enc.Multiline.apply(formatText(e)))
+	if b.Len() == 0 || b.Bytes()[b.Len()-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// appendHeader 按标准库 log 的 flag 写入时间和文件行号
+func appendHeader(b *bytes.Buffer, flag int, e *Entry) {
+	t := e.Time
+	if flag&log.LUTC != 0 {
+		t = t.UTC()
+	}
+	if flag&log.Ldate != 0 {
+		b.WriteString(t.Format("2006/01/02 "))
+	}
+	if flag&(log.Ltime|log.Lmicroseconds) != 0 {
+		if flag&log.Lmicroseconds != 0 {
+			b.WriteString(t.Format("15:04:05.000000 "))
+		} else {
+			b.WriteString(t.Format("15:04:05 "))
+		}
+	}
+	if flag&(log.Lshortfile|log.Llongfile) != 0 && e.Caller != "" {
+		file := e.Caller
+		if i := strings.IndexByte(file, ' '); i >= 0 {
+			file = file[:i]
+		}
+		b.WriteString(file)
+		b.WriteString(": ")
+	}
+}
+
+// JSONEncoder JSON 格式，每条日志一行，字段平铺在顶层
+type JSONEncoder struct {
+	// TimeLayout 时间格式，为空时使用 time.RFC3339Nano
+	TimeLayout string
+	// Time 不为空时代替 TimeLayout 编码时间，如 EpochMillisTime 输出数字时间戳
+	Time TimeEncoder
+	// CompressOver 大于 0 时，超过该字节数的 string/[]byte 字段值 gzip 后 base64 编码，
+	// 输出为 {"encoding":"gzip+base64","size":N,"data":"..."}，用 DecodeCompressed 还原
+	CompressOver int
+}
+
+func (enc JSONEncoder) Encode(e *Entry) ([]byte, error) {
+	layout := enc.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	if enc.Time != nil {
+		appendJSONTime(&b, enc.Time, e.Time)
+	} else {
+		writeJSONString(&b, e.Time.Format(layout))
+	}
+	b.WriteString(`,"level":`)
+	writeJSONString(&b, levelNames[e.Level])
+	if e.Name != "" {
+		b.WriteString(`,"logger":`)
+		writeJSONString(&b, e.Name)
+	}
+	if e.Caller != "" {
+		b.WriteString(`,"caller":`)
+		writeJSONString(&b, e.Caller)
+	}
+	b.WriteString(`,"msg":`)
+	writeJSONString(&b, e.Message)
+	for _, f := range e.Fields {
+		b.WriteByte(',')
+		writeJSONString(&b, f.Key)
+		b.WriteByte(':')
+		if data, ok := largeValue(f, enc.CompressOver); ok {
+			if err := writeCompressed(&b, data); err != nil {
+				return nil, fmt.Errorf("h2sanlog: compress field %q: %w", f.Key, err)
+			}
+			continue
+		}
+		if err := writeJSONField(&b, f); err != nil {
+			return nil, fmt.Errorf("h2sanlog: encode field %q: %w", f.Key, err)
+		}
+	}
+	if len(e.Stack) > 0 {
+		b.WriteString(`,"stack":`)
+		writeJSONString(&b, string(e.Stack))
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	v, _ := json.Marshal(s)
+	b.Write(v)
+}
+
+// writeJSONField 写入字段值，类型化存放的值直接输出，不装箱
+func writeJSONField(b *bytes.Buffer, f Field) error {
+	switch f.kind {
+	case kindAny:
+		return writeJSONValue(b, f.Value)
+	case kindString:
+		writeJSONString(b, f.str)
+		return nil
+	case kindFloat64:
+		if x := math.Float64frombits(uint64(f.num)); math.IsNaN(x) || math.IsInf(x, 0) {
+			return writeJSONValue(b, x)
+		}
+	case kindDuration:
+		writeJSONString(b, time.Duration(f.num).String())
+		return nil
+	}
+	var scratch [24]byte
+	out, _ := appendField(scratch[:0], f)
+	b.Write(out)
+	return nil
+}
+
+// writeJSONValue 写入字段值，error 和 fmt.Stringer 按字符串输出
+func writeJSONValue(b *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case string:
+		writeJSONString(b, x)
+		return nil
+	case int, int64, int32, uint, uint64, bool:
+		var scratch [24]byte
+		out, _ := appendValue(scratch[:0], x)
+		b.Write(out)
+		return nil
+	case float64:
+		if !math.IsNaN(x) && !math.IsInf(x, 0) {
+			var scratch [24]byte
+			b.Write(strconv.AppendFloat(scratch[:0], x, 'g', -1, 64))
+			return nil
+		}
+	case time.Time:
+		var scratch [40]byte
+		writeJSONString(b, string(x.AppendFormat(scratch[:0], time.RFC3339Nano)))
+		return nil
+	case error:
+		writeJSONString(b, x.Error())
+		return nil
+	case fmt.Stringer:
+		writeJSONString(b, x.String())
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b.Write(data)
+	return nil
+}
@@ -0,0 +1,90 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func testKey(size int) KeyProvider {
+	return func() ([]byte, error) { return bytes.Repeat([]byte{7}, size), nil }
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		var out bytes.Buffer
+		e, err := NewEncryptWriter(&out, testKey(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "line one\nline two\n\nlast\n"
+		for _, l := range strings.SplitAfter(want, "\n") {
+			if n, err := e.Write([]byte(l)); err != nil || n != len(l) {
+				t.Fatalf("key %d: Write = %d, %v", size, n, err)
+			}
+		}
+		if strings.Contains(out.String(), "line") {
+			t.Fatalf("key %d: plaintext in output", size)
+		}
+		r, err := NewDecryptReader(&out, testKey(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("key %d: %v", size, err)
+		}
+		if string(got) != want {
+			t.Fatalf("key %d: got %q want %q", size, got, want)
+		}
+	}
+}
+
+func TestEncryptNonceUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		// 同一密钥的多个 writer 模拟多进程或重启
+		var out bytes.Buffer
+		e, err := NewEncryptWriter(&out, testKey(32))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 100; j++ {
+			e.Write([]byte("x"))
+		}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			raw, err := base64.StdEncoding.DecodeString(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nonce := string(raw[:12])
+			if seen[nonce] {
+				t.Fatalf("nonce reused")
+			}
+			seen[nonce] = true
+		}
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	var out bytes.Buffer
+	e, _ := NewEncryptWriter(&out, testKey(16))
+	e.Write([]byte("secret\n"))
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(out.String()))
+	raw[len(raw)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(raw) + "\n"
+
+	for name, in := range map[string]string{"tampered": tampered, "garbage": "!!!\n"} {
+		r, _ := NewDecryptReader(strings.NewReader(in), testKey(16))
+		if _, err := ioutil.ReadAll(r); !errors.Is(err, ErrDecrypt) {
+			t.Fatalf("%s: err = %v, want ErrDecrypt", name, err)
+		}
+	}
+	r, _ := NewDecryptReader(&out, testKey(32))
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong key: err = %v, want ErrDecrypt", err)
+	}
+}
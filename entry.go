@@ -0,0 +1,91 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Entry 一条日志在编码前的结构化内容。
+// 提交写入后 Entry 视为只读：hook 的 AfterWrite 和观察者都不能修改它，
+// 需要修改请先 Clone
+type Entry struct {
+	Time    time.Time
+	Level   uint8
+	Name    string
+	Message string
+	Fields  []Field
+	// Caller 开启 SetCaller 时为 文件:行号 函数名
+	Caller string
+	// Stack 达到 SetStacktraceLevel 级别时为goroutine调用栈
+	Stack []byte
+}
+
+// Field 查找字段值，不存在返回 nil, false；同名字段以后添加的为准
+func (e *Entry) Field(key string) (interface{}, bool) {
+	for i := len(e.Fields) - 1; i >= 0; i-- {
+		if e.Fields[i].Key == key {
+			return e.Fields[i].Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// Clone 深拷贝 entry，Fields、[]byte 字段值和 Stack 都重新分配
+func (e *Entry) Clone() Entry {
+	c := *e
+	c.Fields = copyFields(e.Fields)
+	if e.Stack != nil {
+		c.Stack = append([]byte(nil), e.Stack...)
+	}
+	return c
+}
+
+// copyFields 复制字段切片，[]byte 值一并复制，避免调用方复用缓冲区后改写已提交的日志
+func copyFields(fields []Field) []Field {
+	if fields == nil {
+		return nil
+	}
+	c := make([]Field, len(fields))
+	for i, f := range fields {
+		c[i] = f
+		if b, ok := f.Value.([]byte); ok {
+			c[i].Value = append([]byte(nil), b...)
+		}
+	}
+	return c
+}
+
+// debugChecks 调试模式下检查 entry 和 logger 字段在提交后是否被修改
+var debugChecks int32
+
+// SetDebugChecks 开启调试检查：logger 通过 With 绑定的字段值被外部修改、
+// hook 在 AfterWrite 中修改 entry 时向 stderr 报告，开销较大，只建议在测试和开发环境打开
+func SetDebugChecks(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&debugChecks, v)
+}
+
+func debugChecking() bool {
+	return atomic.LoadInt32(&debugChecks) == 1
+}
+
+// fingerprint 计算字段内容摘要，用于调试模式下发现被修改的 entry
+func fingerprint(msg string, fields []Field) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(msg))
+	for _, f := range fields {
+		fmt.Fprintf(h, "\x00%s=%#v", f.Key, f.Interface())
+	}
+	return h.Sum64()
+}
+
+// reportMutation 调试模式下报告 entry 被修改
+func reportMutation(format string, v ...interface{}) {
+	fmt.Fprintf(os.Stderr, "h2sanlog: "+format+"\n", v...)
+}
@@ -0,0 +1,33 @@
+package h2sanlog
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrEntryTooLarge 单条日志超过 WithMaxEntryBytes 或 WithAtomicAppend 的限制被拒绝
+var ErrEntryTooLarge = errors.New("h2sanlog: entry too large, drop")
+
+// WithMaxEntryBytes 限制单次写入的字节数，避免一次误打的几 MB 数据占满队列内存、打乱按大小轮转。
+// truncate 为 true 时保留前 n 字节并追加 "... [truncated N bytes]" 标记，否则整条丢弃并返回 ErrEntryTooLarge；n<=0 不限制
+func WithMaxEntryBytes(n int, truncate bool) FileOption {
+	return func(w *FileWriter) {
+		w.maxEntry = n
+		w.truncateEntry = truncate
+	}
+}
+
+// entryLen 返回 n 字节的写入实际入队的字节数
+func (w *FileWriter) entryLen(n int) int {
+	if w.maxEntry > 0 && n > w.maxEntry {
+		return w.maxEntry
+	}
+	return n
+}
+
+// appendTruncated 追加截断标记，cut 为被截掉的字节数
+func appendTruncated(b []byte, cut int) []byte {
+	b = append(b, "... [truncated "...)
+	b = strconv.AppendInt(b, int64(cut), 10)
+	return append(b, " bytes]\n"...)
+}
@@ -0,0 +1,176 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// envSink 环境变量生成的 sink 名
+const envSink = "env"
+
+// ConfigFromEnv 按环境变量生成配置，运维无需改代码或配置文件即可在容器中调整日志：
+//
+//	H2SANLOG_CONFIG     配置文件路径，以下变量覆盖文件中的设置
+//	H2SANLOG_LEVEL      最低级别，如 info
+//	H2SANLOG_NAME       logger 名
+//	H2SANLOG_CALLER     输出调用位置，true/false
+//	H2SANLOG_FORMAT     text、json 或 msgpack
+//	H2SANLOG_FILE       日志文件路径，为空时输出到 stderr
+//	H2SANLOG_MAX_SIZE   单个文件最大字节数，可带 K/M/G 后缀
+//	H2SANLOG_MAX_NUM    保留的 .full 文件数
+//	H2SANLOG_MAX_TOTAL  同 WithMaxTotalSize，可带 K/M/G 后缀
+//	H2SANLOG_DAILY_DIRS 同 WithDailyDirs，true/false
+//	H2SANLOG_DATE_DIRS  同 WithDateDirs，true/false
+//	H2SANLOG_UTC        同 WithUTC，true/false
+//	H2SANLOG_CONTAINER  同 Config.Container，on/auto/off
+//	H2SANLOG_HOST       同 Config.Host，true/false
+//	H2SANLOG_APP        同 Config.App
+//	H2SANLOG_VERSION    同 Config.Version
+//	H2SANLOG_INSTANCE   同 Config.Instance
+//
+// 按模块覆盖级别的 H2SANLOG_MODULES（格式同 SetModuleLevels）是全局设置，由 DefaultLogger 应用
+func ConfigFromEnv() (*Config, error) {
+	c := &Config{}
+	if path := os.Getenv("H2SANLOG_CONFIG"); path != "" {
+		var err error
+		if c, err = LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := os.LookupEnv("H2SANLOG_LEVEL"); ok {
+		c.Level = v
+	}
+	if v, ok := os.LookupEnv("H2SANLOG_NAME"); ok {
+		c.Name = v
+	}
+	if v, ok := os.LookupEnv("H2SANLOG_CONTAINER"); ok {
+		c.Container = v
+	}
+	if v, ok := os.LookupEnv("H2SANLOG_APP"); ok {
+		c.App = v
+	}
+	if v, ok := os.LookupEnv("H2SANLOG_VERSION"); ok {
+		c.Version = v
+	}
+	if v, ok := os.LookupEnv("H2SANLOG_INSTANCE"); ok {
+		c.Instance = v
+	}
+	var err error
+	if c.Caller, err = envBool("H2SANLOG_CALLER", c.Caller); err != nil {
+		return nil, err
+	}
+	if c.Host, err = envBool("H2SANLOG_HOST", c.Host); err != nil {
+		return nil, err
+	}
+	format := os.Getenv("H2SANLOG_FORMAT")
+	file := os.Getenv("H2SANLOG_FILE")
+	if file == "" {
+		if format != "" {
+			c.setOutput(SinkConfig{Type: "stderr", Encoder: format})
+		}
+		return c, nil
+	}
+	sc := SinkConfig{Path: file, Encoder: format}
+	if v := os.Getenv("H2SANLOG_MAX_SIZE"); v != "" {
+		if sc.MaxSize, err = parseSize(v); err != nil {
+			return nil, fmt.Errorf("h2sanlog: H2SANLOG_MAX_SIZE: %w", err)
+		}
+	}
+	if v := os.Getenv("H2SANLOG_MAX_NUM"); v != "" {
+		if sc.MaxNum, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("h2sanlog: H2SANLOG_MAX_NUM: %w", err)
+		}
+	}
+	if v := os.Getenv("H2SANLOG_MAX_TOTAL"); v != "" {
+		if sc.MaxTotalSize, err = parseSize(v); err != nil {
+			return nil, fmt.Errorf("h2sanlog: H2SANLOG_MAX_TOTAL: %w", err)
+		}
+	}
+	if sc.DailyDirs, err = envBool("H2SANLOG_DAILY_DIRS", false); err != nil {
+		return nil, err
+	}
+	if sc.DateDirs, err = envBool("H2SANLOG_DATE_DIRS", false); err != nil {
+		return nil, err
+	}
+	if sc.UTC, err = envBool("H2SANLOG_UTC", false); err != nil {
+		return nil, err
+	}
+	c.setOutput(sc)
+	return c, nil
+}
+
+// setOutput 把 sc 作为默认输出
+func (c *Config) setOutput(sc SinkConfig) {
+	if c.Sinks == nil {
+		c.Sinks = make(map[string]SinkConfig)
+	}
+	c.Sinks[envSink] = sc
+	c.Output = envSink
+}
+
+func envBool(name string, def bool) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("h2sanlog: %s: %w", name, err)
+	}
+	return b, nil
+}
+
+// parseSize 解析字节数，支持 K/M/G 后缀（1024 进制，可带 B/iB）
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * mult, nil
+}
+
+var defaultLogger struct {
+	once sync.Once
+	l    *Logger
+}
+
+// DefaultLogger 进程级默认 Logger，首次调用时按 ConfigFromEnv 创建。
+// 环境变量有误时把错误输出到 stderr，退回输出到 stderr 的文本 Logger
+func DefaultLogger() *Logger {
+	defaultLogger.once.Do(func() {
+		c, err := ConfigFromEnv()
+		if err == nil {
+			defaultLogger.l, err = NewFromConfig(c)
+		}
+		if spec := os.Getenv("H2SANLOG_MODULES"); spec != "" {
+			if e := SetModuleLevels(spec); e != nil {
+				stderrError(e)
+			}
+		}
+		if err != nil {
+			stderrError(err)
+			defaultLogger.l = New(os.Stderr, "", log.LstdFlags)
+		}
+	})
+	return defaultLogger.l
+}
@@ -0,0 +1,119 @@
+package h2sanlog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{"512": 512, "4k": 4 << 10, "10M": 10 << 20, "1GiB": 1 << 30, " 2 MB ": 2 << 20} {
+		if got, err := parseSize(in); err != nil || got != want {
+			t.Fatalf("parseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "M", "1T", "-"} {
+		if _, err := parseSize(in); err == nil {
+			t.Fatalf("parseSize(%q) succeeded", in)
+		}
+	}
+}
+
+func TestConfigFromEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app")
+	for k, v := range map[string]string{
+		"H2SANLOG_LEVEL": "warn", "H2SANLOG_NAME": "api", "H2SANLOG_CALLER": "true", "H2SANLOG_FORMAT": "json",
+		"H2SANLOG_FILE": path, "H2SANLOG_MAX_SIZE": "10M", "H2SANLOG_MAX_NUM": "5", "H2SANLOG_MAX_TOTAL": "1G",
+		"H2SANLOG_DAILY_DIRS": "1", "H2SANLOG_UTC": "true", "H2SANLOG_APP": "shop", "H2SANLOG_CONTAINER": "off",
+	} {
+		t.Setenv(k, v)
+	}
+	c, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "warn" || c.Name != "api" || !c.Caller || c.App != "shop" || c.Container != "off" || c.Output != envSink {
+		t.Fatalf("config = %+v", c)
+	}
+	want := SinkConfig{Path: path, Encoder: "json", MaxSize: 10 << 20, MaxNum: 5, MaxTotalSize: 1 << 30, DailyDirs: true, UTC: true}
+	if sc := c.Sinks[envSink]; sc.Path != want.Path || sc.Encoder != want.Encoder || sc.MaxSize != want.MaxSize ||
+		sc.MaxNum != want.MaxNum || sc.MaxTotalSize != want.MaxTotalSize || sc.DailyDirs != want.DailyDirs ||
+		sc.DateDirs || sc.UTC != want.UTC {
+		t.Fatalf("sink = %+v, want %+v", sc, want)
+	}
+}
+
+// 环境变量覆盖配置文件中的设置，只设置 H2SANLOG_FORMAT 时输出到 stderr
+func TestConfigFromEnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	ioutil.WriteFile(path, []byte(`{"level":"debug","name":"file","caller":true,"host":false}`), 0644)
+	t.Setenv("H2SANLOG_CONFIG", path)
+	t.Setenv("H2SANLOG_LEVEL", "error")
+	t.Setenv("H2SANLOG_CALLER", "false")
+	t.Setenv("H2SANLOG_HOST", "true")
+	t.Setenv("H2SANLOG_FORMAT", "json")
+	c, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "error" || c.Name != "file" || c.Caller || !c.Host {
+		t.Fatalf("config = %+v", c)
+	}
+	if sc := c.Sinks[c.Output]; sc.Type != "stderr" || sc.Encoder != "json" {
+		t.Fatalf("output = %q %+v", c.Output, sc)
+	}
+}
+
+func TestConfigFromEnvErrors(t *testing.T) {
+	for k, v := range map[string]string{
+		"H2SANLOG_CALLER": "maybe", "H2SANLOG_HOST": "yes!", "H2SANLOG_MAX_SIZE": "big",
+		"H2SANLOG_MAX_NUM": "x", "H2SANLOG_MAX_TOTAL": "1T", "H2SANLOG_UTC": "utc", "H2SANLOG_CONFIG": "/nonexistent/log.yaml",
+	} {
+		t.Run(k, func(t *testing.T) {
+			t.Setenv("H2SANLOG_FILE", filepath.Join(t.TempDir(), "app"))
+			t.Setenv(k, v)
+			if _, err := ConfigFromEnv(); err == nil {
+				t.Fatalf("%s=%s accepted", k, v)
+			} else if k != "H2SANLOG_CONFIG" && !strings.Contains(err.Error(), k) {
+				t.Fatalf("err = %v, want it to name %s", err, k)
+			}
+		})
+	}
+}
+
+func TestDefaultLogger(t *testing.T) {
+	resetModuleLevels(t)
+	defer func() { defaultLogger.once, defaultLogger.l = sync.Once{}, nil }()
+	dir := t.TempDir()
+	t.Setenv("H2SANLOG_FILE", filepath.Join(dir, "app"))
+	t.Setenv("H2SANLOG_LEVEL", "info")
+	t.Setenv("H2SANLOG_MODULES", "db=error")
+	defaultLogger.once, defaultLogger.l = sync.Once{}, nil
+	l := DefaultLogger()
+	defer CloseAll()
+	if DefaultLogger() != l {
+		t.Fatal("DefaultLogger not reused")
+	}
+	l.Log(LogLevelDebug, "filtered")
+	l.Log(LogLevelInfo, "hello")
+	l.Named("db").Log(LogLevelWarning, "db warn")
+	FlushAll(time.Second)
+	files, _ := filepath.Glob(filepath.Join(dir, "app.*.log"))
+	if len(files) != 1 {
+		t.Fatalf("files = %v", files)
+	}
+	got := readFile(t, files[0])
+	if !strings.Contains(got, "hello") || strings.Contains(got, "filtered") || strings.Contains(got, "db warn") {
+		t.Fatalf("got %q", got)
+	}
+
+	// 环境变量有误时退回到 stderr
+	t.Setenv("H2SANLOG_LEVEL", "loud")
+	defaultLogger.once, defaultLogger.l = sync.Once{}, nil
+	if l := DefaultLogger(); l == nil || l.level != LogLevelDebug {
+		t.Fatalf("fallback logger = %+v", l)
+	}
+}
@@ -0,0 +1,41 @@
+package h2sanlog
+
+import "time"
+
+// WithOnRotate 设置轮转回调：oldPath 为刚关闭的日志文件现在的路径（按大小轮转时是重命名后的文件），
+// newPath 为之后写入的文件，可用于触发上传、通知采集端。回调在持有 writer 锁时同步调用，不能阻塞，
+// 也不能再调用该 writer 的 Rotate/Flush/Close
+func WithOnRotate(fn func(oldPath, newPath string)) FileOption {
+	return func(w *FileWriter) {
+		w.onRotate = fn
+	}
+}
+
+// WithOnRemove 设置删除回调，RetentionPolicy 过期清理、总预算或磁盘保护删除旧日志文件后以被删除的路径调用，
+// 调用约束同 WithOnRotate
+func WithOnRemove(fn func(path string)) FileOption {
+	return func(w *FileWriter) {
+		w.onRemove = fn
+	}
+}
+
+// rotated 通知轮转，调用方需持有锁
+func (w *FileWriter) rotated(oldPath, newPath string) {
+	start, end := w.firstWrite, w.lastWrite
+	w.firstWrite, w.lastWrite = time.Time{}, time.Time{}
+	w.recordManifest(oldPath, start, end)
+	w.catalogAdd(oldPath, start, end)
+	if w.onRotate != nil {
+		w.onRotate(oldPath, newPath)
+	}
+}
+
+// removed 通知删除，调用方需持有锁
+func (w *FileWriter) removed(path string) {
+	w.forgetRetained(path)
+	w.pruneDirs(path)
+	w.catalogRemove(path)
+	if w.onRemove != nil {
+		w.onRemove(path)
+	}
+}
@@ -0,0 +1,141 @@
+package h2sanlog
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrFlushTimeout 等待队列写盘超时
+var ErrFlushTimeout = errors.New("h2sanlog: flush timeout")
+
+// flushMarker 写入队列的刷盘标记，消费者处理到它时说明之前入队的日志都已写盘
+var flushMarker = new([]byte)
+
+// closeMarker 写入队列的关闭标记，消费者写完之前的日志后退出
+var closeMarker = new([]byte)
+
+// exitTimeout Exit 和 Fatal 等待写盘的最长时间
+var exitTimeout = 3 * time.Second
+
+// SetExitTimeout 设置 Exit 和 Fatal 级别日志等待所有 writer 写盘的最长时间，默认3秒
+func SetExitTimeout(d time.Duration) {
+	exitTimeout = d
+}
+
+// registry 所有已创建的 FileWriter，用于退出前统一刷盘
+var registry = struct {
+	sync.Mutex
+	writers map[*FileWriter]struct{}
+}{writers: make(map[*FileWriter]struct{})}
+
+func register(w *FileWriter) {
+	registry.Lock()
+	registry.writers[w] = struct{}{}
+	registry.Unlock()
+}
+
+func unregister(w *FileWriter) {
+	registry.Lock()
+	delete(registry.writers, w)
+	registry.Unlock()
+}
+
+// registered 返回所有已创建 FileWriter 的快照
+func registered() []*FileWriter {
+	registry.Lock()
+	defer registry.Unlock()
+	writers := make([]*FileWriter, 0, len(registry.writers))
+	for w := range registry.writers {
+		writers = append(writers, w)
+	}
+	return writers
+}
+
+// FlushAll 把所有 FileWriter 队列中的日志写盘并 fsync，再刷新 NewFromConfig 创建的自定义 Sink，
+// 所有 writer 共用 timeout，返回第一个错误
+func FlushAll(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var err error
+	for _, w := range registered() {
+		if e := w.Flush(time.Until(deadline)); e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, s := range trackedSinks(false) {
+		if e := s.Flush(time.Until(deadline)); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// exitFunc Fatal 和 Exit 写盘后调用的退出函数
+var exitFunc = os.Exit
+
+// SetExitFunc 设置 Fatal 和 Exit 写盘后调用的退出函数，默认 os.Exit；
+// 测试中可替换为记录退出码的函数，需要 Fatal 只写日志不退出时传入空函数。nil 恢复 os.Exit
+func SetExitFunc(fn func(code int)) {
+	if fn == nil {
+		fn = os.Exit
+	}
+	exitFunc = fn
+}
+
+// Exit 等待所有 writer 写盘后退出进程，直接调用 os.Exit 会丢弃队列中的日志
+func Exit(code int) {
+	FlushAll(exitTimeout)
+	exitFunc(code)
+}
+
+// Flush 把队列中已有的日志写盘并 fsync，超过 timeout 返回 ErrFlushTimeout
+func (w *FileWriter) Flush(timeout time.Duration) error {
+	if atomic.LoadInt32(&w.closed) == 1 {
+		return ErrClosed
+	}
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	// 丢弃上次超时后迟到的完成信号
+	select {
+	case <-w.flushDone:
+	default:
+	}
+	deadline := time.Now().Add(timeout)
+	for !w.queue.push(flushMarker) {
+		if time.Now().After(deadline) {
+			return ErrFlushTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+	select {
+	case <-w.flushDone:
+		return nil
+	case <-t.C:
+		return ErrFlushTimeout
+	}
+}
+
+// Flush 两个文件都写盘并 fsync，两个文件共用 timeout
+func (s *SplitWriter) Flush(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	err := s.main.Flush(timeout)
+	if e := s.errs.Flush(time.Until(deadline)); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// flushed 消费者处理到刷盘标记，fsync 后通知 Flush
+func (w *FileWriter) flushed() {
+	w.lock()
+	w.syncFile()
+	w.mu.Unlock()
+	select {
+	case w.flushDone <- struct{}{}:
+	default:
+	}
+}
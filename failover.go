@@ -0,0 +1,146 @@
+package h2sanlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// FailoverWriter 主 sink 连续写失败时改写到备用 sink（如 os.Stderr 或另一个路径的 FileWriter），
+// 切换后每隔 retry 试写一次主 sink，成功即切回
+type FailoverWriter struct {
+	primary   io.Writer
+	secondary io.Writer
+	threshold int
+	retry     time.Duration
+	onSwitch  func(failover bool, err error)
+
+	mu        sync.Mutex
+	failures  int
+	failed    bool
+	nextProbe time.Time
+	// probed 切换后最近一次试写主 sink 已入队，等待确认是否写盘成功
+	probed bool
+}
+
+// FailoverOption FailoverWriter 的可选配置
+type FailoverOption func(*FailoverWriter)
+
+// WithFailoverThreshold 连续失败 n 次后切换到备用 sink，默认 3
+func WithFailoverThreshold(n int) FailoverOption {
+	return func(f *FailoverWriter) {
+		if n > 0 {
+			f.threshold = n
+		}
+	}
+}
+
+// WithFailoverRetry 切换后试写主 sink 的间隔，默认 10s
+func WithFailoverRetry(d time.Duration) FailoverOption {
+	return func(f *FailoverWriter) {
+		if d > 0 {
+			f.retry = d
+		}
+	}
+}
+
+// WithFailoverNotify 切换回调，failover 为 true 表示切到备用 sink，err 为最后一次主 sink 的错误
+func WithFailoverNotify(fn func(failover bool, err error)) FailoverOption {
+	return func(f *FailoverWriter) {
+		f.onSwitch = fn
+	}
+}
+
+// NewFailoverWriter 新建 FailoverWriter，secondary 为 nil 时不切换，只统计失败；
+// primary 为 FileWriter 时其降级事件也写到 secondary（见 WithMetaMirror）
+func NewFailoverWriter(primary, secondary io.Writer, opts ...FailoverOption) *FailoverWriter {
+	f := &FailoverWriter{primary: primary, secondary: secondary, threshold: 3, retry: 10 * time.Second,
+		onSwitch: func(failover bool, err error) {
+			if failover {
+				stderrError(fmt.Errorf("primary sink fail:%w, failover to secondary", err))
+			}
+		}}
+	for _, opt := range opts {
+		opt(f)
+	}
+	// 主 sink 的降级事件同时写到备用 sink，已通过 WithMetaMirror 设置的不覆盖
+	if fw, ok := primary.(*FileWriter); ok && secondary != nil {
+		fw.metaMirror.CompareAndSwap(nil, metaOut{secondary})
+	}
+	return f
+}
+
+// Write 写主 sink，失败的这条日志转写备用 sink，不会因为切换丢失。
+// 主 sink 为 FileWriter、SplitWriter 这类异步 sink 时，Write 只是入队，写盘和 fsync 失败在后台发生，
+// 因此入队成功后还要检查主 sink 最近的写盘错误，有错误时按失败计数并把这条日志同时写入备用 sink
+// （主 sink 恢复后可能重复一条，但不会丢）；队列满是瞬时错误，只把这条日志转写备用 sink，不计入失败次数
+func (f *FailoverWriter) Write(p []byte) (int, error) {
+	return f.write(p, plainWrite)
+}
+
+// WriteSync 实现 SyncWriter，同步写入主 sink 或备用 sink，切换规则同 Write
+func (f *FailoverWriter) WriteSync(p []byte) (int, error) {
+	return f.write(p, writeSync)
+}
+
+func (f *FailoverWriter) write(p []byte, write func(io.Writer, []byte) (int, error)) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failed && time.Now().Before(f.nextProbe) {
+		if !f.probed || f.sinkError() != nil {
+			return write(f.secondary, p)
+		}
+		// 上次试写已成功写盘，提前切回
+	}
+	n, werr := write(f.primary, p)
+	if errors.Is(werr, ErrQueueFull) && !f.failed && f.secondary != nil {
+		return write(f.secondary, p)
+	}
+	err := werr
+	if err == nil {
+		err = f.sinkError()
+	}
+	if err == nil {
+		f.failures = 0
+		if f.failed {
+			f.failed = false
+			f.onSwitch(false, nil)
+		}
+		return n, nil
+	}
+	f.failures++
+	if f.secondary == nil {
+		return n, werr
+	}
+	if f.failed {
+		f.nextProbe = time.Now().Add(f.retry)
+		f.probed = werr == nil
+	} else if f.failures >= f.threshold {
+		f.failed, f.probed = true, false
+		f.nextProbe = time.Now().Add(f.retry)
+		f.onSwitch(true, err)
+	}
+	return write(f.secondary, p)
+}
+
+// sinkReporter 异步 sink 报告后台写盘错误，见 FileWriter.sinkError
+type sinkReporter interface {
+	sinkError() error
+}
+
+// sinkError 主 sink 为异步 sink 时返回其最近的写盘错误
+func (f *FailoverWriter) sinkError() error {
+	if r, ok := f.primary.(sinkReporter); ok {
+		return r.sinkError()
+	}
+	return nil
+}
+
+// Failed 返回当前是否已切到备用 sink
+func (f *FailoverWriter) Failed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failed
+}
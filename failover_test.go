@@ -0,0 +1,196 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// errWriter Write 返回 err，err 为 nil 时写入 buf
+type errWriter struct {
+	mu  sync.Mutex
+	err error
+	buf bytes.Buffer
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return 0, e.err
+	}
+	return e.buf.Write(p)
+}
+
+func (e *errWriter) setErr(err error) {
+	e.mu.Lock()
+	e.err = err
+	e.mu.Unlock()
+}
+
+// switches 记录切换回调
+type switches struct {
+	mu     sync.Mutex
+	events []bool
+}
+
+func (s *switches) fn(failover bool, err error) {
+	s.mu.Lock()
+	s.events = append(s.events, failover)
+	s.mu.Unlock()
+}
+
+func (s *switches) get() []bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]bool(nil), s.events...)
+}
+
+func TestFailoverThresholdAndRecover(t *testing.T) {
+	primary := &errWriter{err: errors.New("broken")}
+	var secondary bytes.Buffer
+	var sw switches
+	f := NewFailoverWriter(primary, &secondary, WithFailoverThreshold(2),
+		WithFailoverRetry(10*time.Millisecond), WithFailoverNotify(sw.fn))
+	f.Write([]byte("1\n"))
+	if f.Failed() {
+		t.Fatal("failed before threshold")
+	}
+	f.Write([]byte("2\n"))
+	if !f.Failed() {
+		t.Fatal("not failed after threshold")
+	}
+	f.Write([]byte("3\n"))
+	if secondary.String() != "1\n2\n3\n" {
+		t.Fatalf("secondary = %q", secondary.String())
+	}
+	primary.setErr(nil)
+	time.Sleep(20 * time.Millisecond)
+	f.Write([]byte("4\n"))
+	if f.Failed() {
+		t.Fatal("not recovered after probe")
+	}
+	if primary.buf.String() != "4\n" {
+		t.Fatalf("primary = %q", primary.buf.String())
+	}
+	if got := sw.get(); len(got) != 2 || !got[0] || got[1] {
+		t.Fatalf("switch events = %v", got)
+	}
+}
+
+func TestFailoverQueueFullNotCounted(t *testing.T) {
+	primary := &errWriter{err: ErrQueueFull}
+	var secondary bytes.Buffer
+	f := NewFailoverWriter(primary, &secondary, WithFailoverThreshold(1))
+	for i := 0; i < 5; i++ {
+		f.Write([]byte("x\n"))
+	}
+	if f.Failed() {
+		t.Fatal("queue full caused failover")
+	}
+	if secondary.String() != strings.Repeat("x\n", 5) {
+		t.Fatalf("secondary = %q", secondary.String())
+	}
+}
+
+// breakFile 关闭当前文件句柄，之后后台写盘失败
+func breakFile(w *FileWriter) {
+	w.mu.Lock()
+	w.file.Close()
+	w.mu.Unlock()
+}
+
+// repairFile 重新打开当前文件
+func repairFile(t *testing.T, w *FileWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f, err := os.OpenFile(w.filePath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setActive(f)
+}
+
+func TestFailoverFileWriterBackgroundError(t *testing.T) {
+	primary := newTestWriter(t, 0, 0, WithOnError(func(error) {}))
+	var secondary errWriter
+	var sw switches
+	f := NewFailoverWriter(primary, &secondary, WithFailoverThreshold(2),
+		WithFailoverRetry(10*time.Millisecond), WithFailoverNotify(sw.fn))
+
+	f.Write([]byte("ok\n"))
+	primary.Flush(time.Second)
+	breakFile(primary)
+	// Write 只入队总是成功，失败在后台写盘时发生
+	f.Write([]byte("lost\n"))
+	waitFor(t, "background write error", func() bool { return primary.sinkError() != nil })
+	f.Write([]byte("a\n"))
+	f.Write([]byte("b\n"))
+	if !f.Failed() {
+		t.Fatal("background write errors did not trigger failover")
+	}
+	f.Write([]byte("c\n"))
+	if got := secondary.buf.String(); got != "a\nb\nc\n" {
+		t.Fatalf("secondary = %q", got)
+	}
+
+	repairFile(t, primary)
+	waitFor(t, "recover", func() bool {
+		time.Sleep(5 * time.Millisecond)
+		f.Write([]byte("probe\n"))
+		return !f.Failed()
+	})
+	f.Write([]byte("after\n"))
+	primary.Flush(time.Second)
+	if got := activeContent(t, primary); !strings.HasPrefix(got, "ok\n") || !strings.HasSuffix(got, "after\n") {
+		t.Fatalf("primary = %q", got)
+	}
+	if got := sw.get(); len(got) != 2 || !got[0] || got[1] {
+		t.Fatalf("switch events = %v", got)
+	}
+}
+
+// 主 sink 为 FileWriter 时降级事件也写到备用 sink，WithMetaMirror 设置的不被覆盖
+func TestFailoverMirrorsMetaEvents(t *testing.T) {
+	free := uint64(1000)
+	fakeFree(t, &free)
+	var secondary, own lockedBuffer
+	w := newTestWriter(t, 0, 0, WithDiskGuard(100, false), WithOnError(func(error) {}))
+	NewFailoverWriter(w, &secondary)
+	mirrored := newTestWriter(t, 0, 0, WithDiskGuard(100, false), WithOnError(func(error) {}), WithMetaMirror(&own))
+	NewFailoverWriter(mirrored, &secondary)
+	atomic.StoreUint64(&free, 50)
+	checkDisk(w)
+	checkDisk(mirrored)
+	for name, lines := range map[string][]string{"secondary": secondary.lines(), "WithMetaMirror": own.lines()} {
+		if len(lines) != 1 || !strings.Contains(lines[0], "[h2sanlog] event=disk_stop") {
+			t.Fatalf("%s got %q", name, lines)
+		}
+	}
+}
+
+// 降级事件另写一份到 mirror，out 为 nil 时不另写，只有自定义 onError 时默认写 stderr
+func TestMetaMirror(t *testing.T) {
+	free := uint64(50)
+	fakeFree(t, &free)
+	var out lockedBuffer
+	w := newTestWriter(t, 0, 0, WithDiskGuard(100, false), WithOnError(func(error) {}), WithMetaMirror(&out))
+	checkDisk(w)
+	if lines := out.lines(); len(lines) != 1 || !strings.Contains(lines[0], "event=disk_stop") {
+		t.Fatalf("mirror got %q", lines)
+	}
+	if w := newTestWriter(t, 0, 0, WithOnError(func(error) {}), WithMetaMirror(nil)); w.mirror() != nil {
+		t.Fatal("nil mirror replaced")
+	}
+	if w := newTestWriter(t, 0, 0, WithOnError(func(error) {})); w.mirror() != os.Stderr {
+		t.Fatal("custom onError not mirrored to stderr")
+	}
+	if w := newTestWriter(t, 0, 0); w.mirror() != nil {
+		t.Fatal("default onError mirrored twice to stderr")
+	}
+}
@@ -0,0 +1,185 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// fieldKind 字段值的存放方式，kindAny 在 Value 中，其余在 num 或 str 中
+type fieldKind uint8
+
+const (
+	kindAny fieldKind = iota
+	kindString
+	kindInt
+	kindInt64
+	kindUint64
+	kindFloat64
+	kindBool
+	kindDuration
+)
+
+// String 字符串字段；以下构造函数用于 Log/WithFields，编码时按具体类型直接输出，不走反射
+func String(key, value string) Field { return Field{Key: key, kind: kindString, str: value} }
+
+// Int 整数字段
+func Int(key string, value int) Field { return Field{Key: key, kind: kindInt, num: int64(value)} }
+
+// Int64 64 位整数字段
+func Int64(key string, value int64) Field { return Field{Key: key, kind: kindInt64, num: value} }
+
+// Uint64 无符号整数字段
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, kind: kindUint64, num: int64(value)}
+}
+
+// Float64 浮点数字段
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: kindFloat64, num: int64(math.Float64bits(value))}
+}
+
+// Bool 布尔字段
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, kind: kindBool}
+	if value {
+		f.num = 1
+	}
+	return f
+}
+
+// Duration 时长字段，输出为 1.5s 这样的文本
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: kindDuration, num: int64(value)}
+}
+
+// Time 时间字段，输出为 RFC3339Nano
+func Time(key string, value time.Time) Field { return Field{Key: key, Value: value} }
+
+// Err 以 "error" 为键的错误字段，err 为 nil 时值为 nil
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error"}
+	}
+	return Field{Key: "error", Value: err}
+}
+
+// Any 任意类型字段，非基本类型编码时会用到反射
+func Any(key string, value interface{}) Field { return Field{Key: key, Value: value} }
+
+// Interface 返回字段的值，类型与构造时传入的一致
+func (f Field) Interface() interface{} {
+	switch f.kind {
+	case kindString:
+		return f.str
+	case kindInt:
+		return int(f.num)
+	case kindInt64:
+		return f.num
+	case kindUint64:
+		return uint64(f.num)
+	case kindFloat64:
+		return math.Float64frombits(uint64(f.num))
+	case kindBool:
+		return f.num == 1
+	case kindDuration:
+		return time.Duration(f.num)
+	}
+	return f.Value
+}
+
+// String 以 key=value 输出，便于调试打印
+func (f Field) String() string {
+	if b, ok := appendField(append([]byte(f.Key), '='), f); ok {
+		return string(b)
+	}
+	return fmt.Sprintf("%s=%v", f.Key, f.Value)
+}
+
+// WithFields 返回附带 fields 的子logger，同 With
+func (l *Logger) WithFields(fields ...Field) *Logger {
+	c := l.clone()
+	c.fields = copyFields(append(c.fields, fields...))
+	if debugChecking() {
+		c.fieldsSum = fingerprint("", c.fields)
+	}
+	return c
+}
+
+// Log 以 level 输出 msg 和 fields，fields 只在本条日志中使用，不需要 With 派生子logger；
+// level 大于 LogLevelFatal 时不输出，返回 ErrInvalidLevel
+func (l *Logger) Log(level uint8, msg string, fields ...Field) error {
+	return l.output(level, msg, false, nil, fields)
+}
+
+// TraceFields 同 Log(LogLevelTrace, msg, fields...)，以下几个同理，配合 String、Int 等构造函数使用时字段值不装箱
+func (l *Logger) TraceFields(msg string, fields ...Field) error {
+	return l.output(LogLevelTrace, msg, false, nil, fields)
+}
+
+func (l *Logger) DebugFields(msg string, fields ...Field) error {
+	return l.output(LogLevelDebug, msg, false, nil, fields)
+}
+
+func (l *Logger) InfoFields(msg string, fields ...Field) error {
+	return l.output(LogLevelInfo, msg, false, nil, fields)
+}
+
+func (l *Logger) WarningFields(msg string, fields ...Field) error {
+	return l.output(LogLevelWarning, msg, false, nil, fields)
+}
+
+func (l *Logger) ErrorFields(msg string, fields ...Field) error {
+	return l.output(LogLevelError, msg, false, nil, fields)
+}
+
+// appendField 同 appendValue，类型化存放的值直接追加，不装箱
+func appendField(b []byte, f Field) ([]byte, bool) {
+	switch f.kind {
+	case kindString:
+		return append(b, f.str...), true
+	case kindInt, kindInt64:
+		return strconv.AppendInt(b, f.num, 10), true
+	case kindUint64:
+		return strconv.AppendUint(b, uint64(f.num), 10), true
+	case kindFloat64:
+		return strconv.AppendFloat(b, math.Float64frombits(uint64(f.num)), 'g', -1, 64), true
+	case kindBool:
+		return strconv.AppendBool(b, f.num == 1), true
+	case kindDuration:
+		return append(b, time.Duration(f.num).String()...), true
+	}
+	return appendValue(b, f.Value)
+}
+
+// appendValue 基本类型直接追加文本，返回 false 表示需要调用方按 %v 格式化
+func appendValue(b []byte, v interface{}) ([]byte, bool) {
+	switch x := v.(type) {
+	case string:
+		return append(b, x...), true
+	case int:
+		return strconv.AppendInt(b, int64(x), 10), true
+	case int64:
+		return strconv.AppendInt(b, x, 10), true
+	case int32:
+		return strconv.AppendInt(b, int64(x), 10), true
+	case uint64:
+		return strconv.AppendUint(b, x, 10), true
+	case uint:
+		return strconv.AppendUint(b, uint64(x), 10), true
+	case float64:
+		return strconv.AppendFloat(b, x, 'g', -1, 64), true
+	case bool:
+		return strconv.AppendBool(b, x), true
+	case time.Duration:
+		return append(b, x.String()...), true
+	case time.Time:
+		return x.AppendFormat(b, time.RFC3339Nano), true
+	case error:
+		return append(b, x.Error()...), true
+	case nil:
+		return append(b, "<nil>"...), true
+	}
+	return b, false
+}
@@ -0,0 +1,78 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 类型化存放的字段与 Any 构造的同值字段编码结果一致
+func TestTypedFieldsEncode(t *testing.T) {
+	typed := []Field{String("s", "v"), Int("i", -7), Int64("i64", 1<<40), Uint64("u", math.MaxUint64),
+		Float64("f", 1.5), Bool("b", true), Bool("nb", false), Duration("d", 1500*time.Millisecond)}
+	boxed := make([]Field, len(typed))
+	for i, f := range typed {
+		boxed[i] = Any(f.Key, f.Interface())
+		if f.Value != nil {
+			t.Fatalf("%s boxed into Value", f.Key)
+		}
+	}
+	entry := func(fields []Field) *Entry {
+		return &Entry{Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Level: LogLevelInfo, Message: "m", Fields: fields}
+	}
+	for _, enc := range []Encoder{TextEncoder{}, JSONEncoder{}, MsgpackEncoder{}} {
+		want, err := enc.Encode(entry(boxed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := enc.Encode(entry(typed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%T: got %q, want %q", enc, got, want)
+		}
+	}
+	if got := entry(typed).Fields[6].String(); got != "nb=false" {
+		t.Fatalf("String() = %q", got)
+	}
+	if v, ok := entry(typed).Field("i"); !ok || v != -7 {
+		t.Fatalf("Field(i) = %v, %v", v, ok)
+	}
+}
+
+func TestTypedFieldsNoAlloc(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	n := testing.AllocsPerRun(100, func() {
+		for _, f := range []Field{String("path", "/api/v1/users"), Int("status", 503), Int64("bytes", 123456789),
+			Float64("ratio", 0.75), Duration("took", time.Second)} {
+			buf, _ = appendField(buf[:0], f)
+		}
+	})
+	if n != 0 {
+		t.Fatalf("allocs = %v", n)
+	}
+}
+
+func TestLeveledFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetCaller(true, 0)
+	l.SetLevel(LogLevelInfo)
+	l.DebugFields("filtered", Int("n", 0))
+	l.InfoFields("info", Int("n", 1))
+	l.WarningFields("warning", String("k", "v"))
+	l.ErrorFields("error", Bool("ok", false))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{"[INFO]", "n=1 info", "[WARNING]", "k=v warning", "[ERROR]", "ok=false error"}
+	if len(lines) != 3 {
+		t.Fatalf("got %q", buf.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[2*i]) || !strings.HasSuffix(line, want[2*i+1]) || !strings.Contains(line, "field_test.go:") {
+			t.Fatalf("line %d = %q", i, line)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"os"
+)
+
+// WithFileLock 多个进程（如 prefork 的 worker）写同一个 fileName 时开启：
+// 使用 <fileName>.lock 上的建议锁，写盘时持共享锁并确认仍在写当前文件，轮转时持排他锁，
+// 避免多个进程重复轮转或把日志写进已被其他进程轮转走的文件
+func WithFileLock() FileOption {
+	return func(w *FileWriter) {
+		w.useFileLock = true
+	}
+}
+
+// openLockFile 打开锁文件，调用方需在 fileName 目录已存在后调用
+func (w *FileWriter) openLockFile() error {
+	if !w.useFileLock {
+		return nil
+	}
+	f, err := openLogFile(w.fileName + ".lock")
+	if err != nil {
+		return err
+	}
+	w.lockFile = f
+	return nil
+}
+
+// closeLockFile 关闭锁文件，用于创建失败时释放已打开的描述符
+func (w *FileWriter) closeLockFile() {
+	if w.lockFile != nil {
+		w.lockFile.Close()
+		w.lockFile = nil
+	}
+}
+
+// sharedLock 写盘前持共享锁，并在其他进程已轮转时切换到新的当前文件，调用方需持有 w.mu
+func (w *FileWriter) sharedLock() {
+	if w.lockFile == nil {
+		return
+	}
+	if err := flock(w.lockFile, false); err != nil {
+		w.onError(fmt.Errorf("flock path:%s fail:%w", w.lockFile.Name(), err))
+		return
+	}
+	w.followActive()
+}
+
+// exclusiveLock 轮转前持排他锁，调用方需持有 w.mu
+func (w *FileWriter) exclusiveLock() {
+	if w.lockFile == nil {
+		return
+	}
+	if err := flock(w.lockFile, true); err != nil {
+		w.onError(fmt.Errorf("flock path:%s fail:%w", w.lockFile.Name(), err))
+	}
+}
+
+// unlockFile 释放文件锁
+func (w *FileWriter) unlockFile() {
+	if w.lockFile == nil {
+		return
+	}
+	if err := funlock(w.lockFile); err != nil {
+		w.onError(fmt.Errorf("funlock path:%s fail:%w", w.lockFile.Name(), err))
+	}
+}
+
+// followActive 当前路径已不是自己打开的文件（被其他进程轮转）时重新打开，调用方需持有锁
+func (w *FileWriter) followActive() bool {
+	fi, err := w.file.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(w.filePath)
+	if err == nil && os.SameFile(fi, pi) {
+		return false
+	}
+	w.file.Close()
+	w.reopen()
+	return true
+}
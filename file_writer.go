@@ -1,6 +1,7 @@
 package h2sanlog
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
@@ -8,13 +9,46 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const logFileNameFormat = "%s.%4d-%02d-%02d.log"
+// dailyDirFormat 按天分目录时的目录名
+const dailyDirFormat = "%4d-%02d-%02d"
+
+// dirLayout 日志文件的目录布局
+type dirLayout uint8
+
+const (
+	// flatLayout logs/app.2024-05-01.log
+	flatLayout dirLayout = iota
+	// dailyLayout logs/2024-05-01/app.log
+	dailyLayout
+	// dateLayout logs/2024/05/01/app.log
+	dateLayout
+)
+
+// depth 日志文件相对 fileName 所在目录的日期目录层数
+func (l dirLayout) depth() int {
+	switch l {
+	case dailyLayout:
+		return 1
+	case dateLayout:
+		return 3
+	}
+	return 0
+}
+
+// ErrQueueFull 写入channel已满，日志被丢弃
+var ErrQueueFull = errors.New("chan full, drop")
+
+// ErrClosed writer 已关闭
+var ErrClosed = errors.New("h2sanlog: writer closed")
+
+// ErrSharedConflict 同一路径已有未关闭的 writer，且 maxSize 或 maxNum 不同
+var ErrSharedConflict = errors.New("h2sanlog: file already open with different settings")
 
 // FileWriter 日志实现Writer
 type FileWriter struct {
@@ -25,146 +59,730 @@ type FileWriter struct {
 	file     *os.File
 	writer   io.Writer
 	mu       sync.Mutex
-	ch       chan []byte
+	queue    queue
+
+	layout dirLayout
+	// patternSpec pattern 见 WithFilePattern
+	patternSpec string
+	pattern     *namePattern
+	rotation    RotationPolicy
+	retention   RetentionPolicy
+	onError     func(error)
+
+	batchSize     int
+	batchInterval time.Duration
+	// drainBatch 见 WithDrainBatch，drainBuf drainEnds 为消费者复用的合并缓冲和每条日志的结束位置
+	drainBatch int
+	drainBuf   []byte
+	drainEnds  []int
+
+	replayLines int
+	replayOut   io.Writer
+
+	counters counters
+
+	syncPolicy SyncPolicy
+	// flushOnError 见 WithFlushOnError
+	flushOnError bool
+
+	partialRepair PartialRepair
+
+	// writeTime stampBuf 见 WithWriteTime
+	writeTime TimeEncoder
+	stampBuf  []byte
+
+	// neverDrop neverDropWait 见 WithNeverDrop
+	neverDrop     uint8
+	neverDropWait time.Duration
+	// unsynced 上次 fsync 后写入的日志条数
+	unsynced int
+
+	flushMu   sync.Mutex
+	flushDone chan struct{}
+	// discardMu 后台goroutine退出后多个调用方互斥地清空队列
+	discardMu sync.Mutex
+
+	minFree uint64
+	purge   bool
+
+	budgetFiles int
+	budgetBytes int64
+	// quota 开启 WithMaxTotalSize，retained 为除当前文件外的日志文件总大小，retainedFiles 为这些文件，从旧到新
+	quota         bool
+	retained      int64
+	retainedFiles []retainedFile
+	diskFull      int32
+
+	useFileLock bool
+	lockFile    *os.File
+
+	closed  int32
+	done    chan struct{}
+	stopped chan struct{}
+
+	// key refs 同路径共享时的去重key和引用计数，由 shared 的锁保护
+	key  string
+	refs int
+
+	// size nextPeriod 当前文件已写入的字节数和下一次按时间切换文件的时间，写入前据此判断是否轮转；
+	// retryAt 按大小轮转失败后下一次重试的时间
+	size       int64
+	nextPeriod time.Time
+	retryAt    time.Time
+
+	watcher *dirWatcher
+
+	// prealloc spare WithPreallocate 的预分配大小和当前备用文件，spareBusy 为正在后台准备备用文件
+	prealloc  int64
+	spare     string
+	spareBusy int32
+
+	metaEnc  Encoder
+	dropping int32
+	dropBase uint64
+	// metaMirror 保存 metaOut，见 WithMetaMirror；ownOnError 为设置了 WithOnError
+	metaMirror atomic.Value
+	ownOnError bool
+
+	// highWater lowWater onWatermark 见 WithQueueWatermarks，highMark lowMark 为换算后的长度，aboveHigh 为已越过高水位
+	highWater, lowWater float64
+	onWatermark         func(QueueEvent)
+	highMark, lowMark   int
+	aboveHigh           int32
+
+	setOwner      bool
+	preserveOwner bool
+	uid, gid      int
+	// ownerUID ownerGID WithPreserveOwner 时最近一次记录的属主
+	hasOwner           bool
+	ownerUID, ownerGID int
+
+	clock    Clock
+	location *time.Location
+
+	onRotate func(oldPath, newPath string)
+	onRemove func(path string)
+
+	spill *spiller
+
+	maxEntry      int
+	truncateEntry bool
+
+	// bufSize bufIdle buf 见 WithBufferedWrites
+	bufSize int
+	bufIdle time.Duration
+	buf     *bufio.Writer
+
+	// atomicAppend atomicSplit 见 WithAtomicAppend
+	atomicAppend bool
+	atomicSplit  bool
+
+	catalog *catalog
+
+	health health
+
+	manifest *manifest
+	// firstWrite lastWrite 当前文件首末次写盘时间，轮转时记入清单
+	firstWrite, lastWrite time.Time
 }
 
-// NewFileWriter 新建一个日志writer，并启动三个goroutine来 rotate, check, flush
-func NewFileWriter(fileName string, maxSize int64, maxNum int) (io.Writer, error) {
-	parentPath := filepath.Dir(fileName)
-	_, err := os.Stat(parentPath)
-	if err != nil {
-		err = os.MkdirAll(parentPath, 0777)
-		if err != nil {
-			return nil, err
+// FileOption FileWriter 的可选配置
+type FileOption func(*FileWriter)
+
+// WithDailyDirs 按天分目录存放日志，fileName 为 logs/app 时写入 logs/2024-05-01/app.log，
+// 而不是 logs/app.2024-05-01.log
+func WithDailyDirs() FileOption {
+	return func(w *FileWriter) {
+		w.layout = dailyLayout
+	}
+}
+
+// WithDateDirs 按年/月/日分层目录存放日志，fileName 为 logs/app 时写入 logs/2024/05/01/app.log，
+// 长期运行的主机上单个目录不会积累过多文件；清理旧文件后删除空的日期目录
+func WithDateDirs() FileOption {
+	return func(w *FileWriter) {
+		w.layout = dateLayout
+	}
+}
+
+// WithOnError 设置内部错误（轮转、重建、删除日志文件失败等）的回调，默认输出到 stderr
+func WithOnError(fn func(error)) FileOption {
+	return func(w *FileWriter) {
+		if fn != nil {
+			w.onError = fn
+			w.ownOnError = true
+		}
+	}
+}
+
+// defaultBatchInterval 开启批量写但未指定间隔时的默认刷新间隔
+const defaultBatchInterval = 200 * time.Millisecond
+
+// WithBatch 开启批量写盘：缓冲累计到 size 字节或距上次写盘超过 interval 时一次性写入，
+// 减少每条日志一次系统调用的开销；size<=0 时不开启，interval<=0 时使用 200ms。
+// 见 BenchmarkBatch：size 到 64KB 后吞吐基本不再提高，interval 只影响低流量时的落盘延迟
+func WithBatch(size int, interval time.Duration) FileOption {
+	return func(w *FileWriter) {
+		if size < 0 {
+			size = 0
+		}
+		w.batchSize = size
+		w.batchInterval = interval
+		if interval <= 0 {
+			w.batchInterval = defaultBatchInterval
 		}
 	}
-	y, m, d := time.Now().Date()
-	path := fmt.Sprintf(logFileNameFormat, fileName, y, m, d)
-	file, e := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
-	if e != nil {
-		return nil, e
+}
+
+// NewFileWriter 新建一个日志writer，并启动两个goroutine来 check, flush，SyncEvery 和 WithBufferedWrites 的空闲刷新各另起一个。
+// 同一个 fileName 已有未关闭的 writer 时直接返回它并增加引用计数，每次 NewFileWriter 对应一次 Close，
+// 最后一次 Close 才真正关闭文件；maxSize、maxNum 与已有 writer 不同时返回 ErrSharedConflict，
+// opts 以第一次创建为准，再次传入的 opts 不生效，通过已有 writer 的 onError 报告
+func NewFileWriter(fileName string, maxSize int64, maxNum int, opts ...FileOption) (*FileWriter, error) {
+	key := sharedKey(fileName)
+	shared.Lock()
+	if w := shared.writers[key]; w != nil {
+		if w.maxSize != maxSize || w.maxNum != maxNum {
+			shared.Unlock()
+			return nil, fmt.Errorf("%w: %s opened with maxSize:%d maxNum:%d, got maxSize:%d maxNum:%d",
+				ErrSharedConflict, fileName, w.maxSize, w.maxNum, maxSize, maxNum)
+		}
+		acquireShared(key)
+		shared.Unlock()
+		if len(opts) > 0 {
+			// 解锁后回调，onError 中再次 NewFileWriter 不会死锁
+			w.onError(fmt.Errorf("h2sanlog: %s already open, %d opts ignored", fileName, len(opts)))
+		}
+		return w, nil
+	}
+	writer, pending, err := newFileWriter(fileName, maxSize, maxNum, opts...)
+	if err == nil {
+		writer.key = key
+		writer.refs = 1
+		shared.writers[key] = writer
+	}
+	shared.Unlock()
+	// 创建期间的回调解锁后再调用，回调中 NewFileWriter、Close 不会死锁
+	for _, fn := range pending {
+		fn()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// newFileWriter 创建并启动 writer，调用方持有 shared 锁；期间的 onError、onRotate、onRemove 和 replay 输出
+// 不直接调用，随 pending 返回
+func newFileWriter(fileName string, maxSize int64, maxNum int, opts ...FileOption) (writer *FileWriter, pending []func(), err error) {
+	writer = &FileWriter{fileName: fileName, maxSize: maxSize, maxNum: maxNum,
+		rotation: fullRotation{}, retention: fullRetention{maxNum: maxNum}, onError: stderrError, clock: systemClock{},
+		drainBatch: defaultDrainBatch, flushDone: make(chan struct{}, 1), done: make(chan struct{}), stopped: make(chan struct{})}
+	for _, opt := range opts {
+		opt(writer)
+	}
+	if err := writer.compilePattern(); err != nil {
+		return nil, nil, err
+	}
+	writer.recordErrors()
+	onError, onRotate, onRemove := writer.onError, writer.onRotate, writer.onRemove
+	writer.onError = func(err error) { pending = append(pending, func() { onError(err) }) }
+	if onRotate != nil {
+		writer.onRotate = func(oldPath, newPath string) { pending = append(pending, func() { onRotate(oldPath, newPath) }) }
+	}
+	if onRemove != nil {
+		writer.onRemove = func(path string) { pending = append(pending, func() { onRemove(path) }) }
+	}
+	if writer.queue == nil {
+		writer.queue = newChanQueue(defaultQueueSize)
+	}
+	writer.initWatermarks()
+	if b := writer.replay(); b != nil {
+		out := writer.replayOut
+		pending = append(pending, func() { out.Write(b) })
+	}
+	if err := writer.openLockFile(); err != nil {
+		return nil, pending, err
 	}
-	writer := &FileWriter{fileName: fileName, filePath: path, file: file, writer: file, ch: make(chan []byte, 256), maxSize: maxSize, maxNum: maxNum}
-	go writer.rotate()
+	path := writer.pathAt(writer.now())
+	file, err := writer.openFile(path)
+	if err != nil {
+		writer.closeLockFile()
+		return nil, pending, err
+	}
+	writer.filePath = path
+	writer.setActive(file)
+	writer.repairPartial()
+	writer.prepareSpare()
+	writer.guardDisk()
+	writer.enforceBudget(0)
+	if writer.catalog != nil {
+		writer.loadCatalog()
+	}
+	// 重启后打开的文件已超过 maxSize 时立即轮转，不等到第一次写入
+	writer.sharedLock()
+	writer.maybeRotate(0)
+	writer.unlockFile()
+	if writer.spill != nil {
+		if err := writer.openSpill(); err != nil {
+			writer.file.Close()
+			writer.closeLockFile()
+			return nil, pending, err
+		}
+	}
+	writer.startWatch()
+	// 后台 goroutine 启动前恢复回调，之后的回调照常同步调用
+	writer.onError, writer.onRotate, writer.onRemove = onError, onRotate, onRemove
 	go writer.flush()
 	go writer.check()
-	return writer, nil
+	if writer.syncPolicy.mode == syncInterval && writer.syncPolicy.d > 0 {
+		go writer.syncLoop()
+	}
+	if writer.buffered() && writer.bufIdle > 0 {
+		go writer.bufferLoop()
+	}
+	register(writer)
+	return writer, pending, nil
 }
 
-// Write 异步channel写日志
+// Write 异步队列写日志，p 复制到池化缓冲后入队，调用方可立即复用 p
 func (w *FileWriter) Write(p []byte) (int, error) {
-	buf := make([]byte, len(p))
-	copy(buf, p)
-	select {
-	case w.ch <- buf:
-		//log写入成功
-		//log写入channel字节数
-		return len(buf), nil
-	default:
-		//chan满，写入失败
-		return 0, errors.New("chan full, drop")
+	buf := getBuf()
+	*buf = append(*buf, p[:w.entryLen(len(p))]...)
+	return w.enqueue(buf, len(p))
+}
+
+// WriteString 同 Write，直接复制字符串，省去 []byte(s) 的转换
+func (w *FileWriter) WriteString(s string) (int, error) {
+	buf := getBuf()
+	*buf = append(*buf, s[:w.entryLen(len(s))]...)
+	return w.enqueue(buf, len(s))
+}
+
+// ReadFrom 把 r 读到 EOF 的全部内容作为一次写入入队，读取直接进池化缓冲，省去中间拷贝；
+// 设置了 WithMaxEntryBytes 时超出的部分只计数不缓冲，转储很大的请求体也不会占用过多内存
+func (w *FileWriter) ReadFrom(r io.Reader) (int64, error) {
+	buf := getBuf()
+	b := *buf
+	var rest int64
+	for {
+		if w.maxEntry > 0 && len(b) >= w.maxEntry {
+			var err error
+			if rest, err = io.Copy(ioutil.Discard, r); err != nil {
+				*buf = b
+				putBuf(buf)
+				return 0, err
+			}
+			break
+		}
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			*buf = b
+			putBuf(buf)
+			return 0, err
+		}
 	}
+	total := len(b) + int(rest)
+	*buf = b[:w.entryLen(total)]
+	n, err := w.enqueue(buf, total)
+	return int64(n), err
 }
 
-// check 每分钟检查一下日志文件是否存在，运维误删log文件但是进程一直在打日志，fd会一直存在，需要关闭。超过maxSize自动rotate
+// enqueue 检查大小和预算后入队，total 为截断前的字节数，成功时返回 total
+func (w *FileWriter) enqueue(buf *[]byte, total int) (int, error) {
+	if total > len(*buf) {
+		if !w.truncateEntry {
+			putBuf(buf)
+			atomic.AddUint64(&w.counters.dropped, 1)
+			return 0, ErrEntryTooLarge
+		}
+		*buf = appendTruncated(*buf, total-len(*buf))
+	}
+	if w.atomicReject(len(*buf)) {
+		putBuf(buf)
+		atomic.AddUint64(&w.counters.dropped, 1)
+		return 0, ErrEntryTooLarge
+	}
+	if err := w.admit(len(*buf)); err != nil {
+		putBuf(buf)
+		return 0, err
+	}
+	if _, err := w.send(buf); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// admit 检查磁盘和内存预算，通过后占用 n 字节预算
+func (w *FileWriter) admit(n int) error {
+	if atomic.LoadInt32(&w.closed) == 1 {
+		return ErrClosed
+	}
+	if atomic.LoadInt32(&w.diskFull) == 1 {
+		atomic.AddUint64(&w.counters.dropped, 1)
+		return ErrDiskFull
+	}
+	if !acquireMem(n) {
+		atomic.AddUint64(&w.counters.dropped, 1)
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// send 缓冲入队，队列满时归还预算和缓冲
+func (w *FileWriter) send(buf *[]byte) (int, error) {
+	if ok, n, err := w.trySpill(buf, false); ok {
+		return n, err
+	}
+	n := len(*buf)
+	start := w.sendStart()
+	ok := w.queue.push(buf)
+	if !ok && w.mustKeep(*buf) {
+		ok = w.pushWait(buf)
+	}
+	w.sendDone(start)
+	if !ok {
+		if ok, n, err := w.trySpill(buf, true); ok {
+			return n, err
+		}
+		//队列满，写入失败
+		releaseMem(n)
+		putBuf(buf)
+		atomic.AddUint64(&w.counters.dropped, 1)
+		w.startDropping()
+		return 0, ErrQueueFull
+	}
+	if atomic.LoadInt32(&w.closed) == 1 && w.discardQueued() > 0 {
+		// 在 Close 推入关闭标记之后入队，不会被写出
+		return 0, ErrClosed
+	}
+	w.checkHighWater()
+	//log写入队列字节数
+	return n, nil
+}
+
+// stderrError 默认的内部错误处理，输出到 stderr
+func stderrError(err error) {
+	fmt.Fprintf(os.Stderr, "h2sanlog: %s\n", err)
+}
+
+// pathAt 返回 t 所在时段的日志文件路径
+func (w *FileWriter) pathAt(t time.Time) string {
+	y, m, d := t.Date()
+	dir := filepath.Dir(w.fileName)
+	switch w.layout {
+	case dailyLayout:
+		dir = filepath.Join(dir, fmt.Sprintf(dailyDirFormat, y, m, d))
+	case dateLayout:
+		dir = filepath.Join(dir, fmt.Sprintf("%04d", y), fmt.Sprintf("%02d", m), fmt.Sprintf("%02d", d))
+	}
+	return filepath.Join(dir, w.pattern.format(t))
+}
+
+// openLogFile 以追加方式打开日志文件，所在目录不存在时自动创建
+func openLogFile(path string) (*os.File, error) {
+	parentPath := filepath.Dir(path)
+	_, err := os.Stat(parentPath)
+	if err != nil {
+		err = os.MkdirAll(parentPath, 0777)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return openAppend(path)
+}
+
+// check 监听日志文件是否被删除，运维误删log文件但是进程一直在打日志，fd会一直存在，需要关闭后重建；
+// 不支持监听的平台每秒轮询一次。每分钟顺带检查空闲时的按时间切换、其他进程写入导致的超限和磁盘空间
 func (w *FileWriter) check() {
+	ticker := w.clock.NewTicker(time.Minute)
+	defer ticker.Stop()
+	var events <-chan struct{}
+	var poll <-chan time.Time
+	if w.watcher != nil {
+		defer w.watcher.close()
+		events = w.watcher.events
+	} else {
+		p := w.clock.NewTicker(pollInterval)
+		defer p.Stop()
+		poll = p.C()
+	}
 	for {
-		time.Sleep(time.Minute)
-
-		w.mu.Lock()
-		fileInfo, err := os.Stat(w.filePath)
-		if os.IsNotExist(err) {
-			//日志已被误删除，重新创建新日志文件
-			file, e := os.OpenFile(w.filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
-			if e == nil {
-				w.file.Close()
-				w.file = file
-				w.writer = file
-			}
+		tick := false
+		select {
+		case <-ticker.C():
+			tick = true
+		case <-events:
+		case <-poll:
+		case <-w.done:
+			return
+		}
+		w.lock()
+		// done 与事件同时就绪时 select 可能先选中事件，已关闭时不再重建文件或轮转，
+		// 否则 Close 之后删除目录（如清理测试目录）会被当作误删而重新创建文件
+		if atomic.LoadInt32(&w.closed) == 1 {
 			w.mu.Unlock()
+			return
+		}
+		if !w.recreate() && tick {
+			w.sharedLock()
+			w.maybeRotate(0)
+			w.unlockFile()
+			w.guardDisk()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// rotateFull 日志文件超过最大size，按 RotationPolicy 重命名后打开新文件并按 RetentionPolicy 清理，调用方需持有锁。
+// 释放锁前校验新文件可写、重命名后的文件完整，校验失败回滚到原文件继续写，避免半途失败后日志无处可写
+func (w *FileWriter) rotateFull(size int64) error {
+	w.captureOwner()
+	w.syncBeforeClose()
+	w.trimPrealloc()
+	w.file.Close()
+	//rename log file
+	name := w.rotation.RotateName(w.filePath, w.listDir())
+	err := renameFile(w.filePath, name)
+	if err != nil {
+		//Rename重命名日志文件失败，继续写原文件
+		err = fmt.Errorf("rename file path:%s fail:%w", w.filePath, err)
+		w.degrade("rotate_failed", err)
+		w.reopen()
+		return err
+	}
+	w.takeSpare()
+	file, err := w.openFile(w.filePath)
+	if err == nil {
+		err = verifyActive(file, w.filePath)
+	}
+	if err == nil {
+		err = verifyRotated(name, size)
+	}
+	if err != nil {
+		//校验失败，回滚重命名
+		err = fmt.Errorf("rotate file path:%s verify fail:%w, rollback", w.filePath, err)
+		w.degrade("rotate_rollback", err)
+		if file != nil {
+			file.Close()
+			os.Remove(w.filePath)
+		}
+		if e := renameFile(name, w.filePath); e != nil {
+			w.degrade("rollback_failed", fmt.Errorf("rollback file path:%s fail:%w", name, e))
+		}
+		w.reopen()
+		return err
+	}
+	w.setActive(file)
+	w.prepareSpare()
+	w.rotated(name, w.filePath)
+	//remove expired log file
+	for _, name := range w.retention.Expired(w.filePath, w.listDir()) {
+		err := os.Remove(name)
+		if err != nil && !os.IsNotExist(err) {
+			//Remove删除老日志文件失败
+			w.onError(fmt.Errorf("remove file path:%s fail:%w", name, err))
 			continue
 		}
-		if w.maxSize > 0 && fileInfo.Size() > w.maxSize {
-			//日志文件超过最大size
-			name := w.filePath + ".full."
-			files, _ := ioutil.ReadDir(path.Dir(w.filePath))
-			var minNum = 1000000
-			var maxNum = 0
-			var totalNum = 0
-			for _, f := range files {
-				if strings.Contains(f.Name(), name) {
-					totalNum++
-					s := strings.Split(f.Name(), ".") // going.2018-05-22.log.full.1.log
-					if len(s) > 5 {
-						n, _ := strconv.Atoi(s[4])
-						if n > maxNum {
-							maxNum = n
-						}
-						if n < minNum {
-							minNum = n
-						}
-					}
-				}
-			}
-			w.file.Close()
-			//rename log file
-			name = fmt.Sprintf("%s.full.%d.log", w.filePath, maxNum+1) //织云日志清理规则 默认需要以 .log 结尾
-			err := os.Rename(w.filePath, name)
-			if err != nil {
-				//Rename重命名日志文件失败
-				fmt.Printf("rename file path:%s fail:%s\n", w.filePath, err)
+		if err == nil {
+			w.removed(name)
+		}
+	}
+	w.enforceBudget(0)
+	return nil
+}
+
+// listDir 返回当前日志文件所在目录下的所有文件路径
+func (w *FileWriter) listDir() []string {
+	dir := path.Dir(w.filePath)
+	files, _ := ioutil.ReadDir(dir)
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() {
+			paths = append(paths, filepath.Join(dir, f.Name()))
+		}
+	}
+	return paths
+}
+
+// reopen 重新打开当前日志文件，调用方需持有锁
+func (w *FileWriter) reopen() {
+	file, err := w.openFile(w.filePath)
+	if err != nil {
+		//创建日志文件失败
+		w.degrade("reopen_failed", fmt.Errorf("open file path:%s fail:%w", w.filePath, err))
+		return
+	}
+	w.setActive(file)
+}
+
+// verifyActive 校验新打开的文件就是 path 指向的文件
+func verifyActive(file *os.File, path string) error {
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	pi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(fi, pi) {
+		return fmt.Errorf("%s replaced during rotation", path)
+	}
+	return nil
+}
+
+// verifyRotated 校验重命名后的文件存在且不小于重命名前的大小；不检查末尾换行，
+// 原样写入的内容、msgpack 等二进制编码和截断的日志都可能不以换行结尾，不能因此回滚
+func verifyRotated(name string, size int64) error {
+	f, err := openShared(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < size {
+		return fmt.Errorf("%s truncated: %d < %d", name, fi.Size(), size)
+	}
+	return nil
+}
+
+// flush 刷新日志到磁盘中，未开启批量写时把队列中已有的日志合并写一次（见 WithDrainBatch），收到关闭标记后写完剩余日志退出
+func (w *FileWriter) flush() {
+	defer close(w.stopped)
+	var tick <-chan time.Time
+	if w.batchSize > 0 {
+		ticker := w.clock.NewTicker(w.batchInterval)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+	buf := make([]byte, 0, w.batchSize)
+	var ends []int
+	// next 合并写入时取到的标记，下一轮处理
+	var next *[]byte
+	for {
+		log, ok := next, next != nil
+		if next == nil {
+			log, ok = w.queue.pop(tick)
+		}
+		next = nil
+		switch {
+		case log == flushMarker || log == closeMarker:
+			if len(buf) > 0 {
+				w.writeOut(buf, ends)
+				buf, ends = buf[:0], ends[:0]
 			}
-			file, err := os.OpenFile(w.filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
-			if err != nil {
-				//创建日志文件失败
-				fmt.Printf("open file path:%s fail:%s\n", w.filePath, err)
+			w.drainSpill()
+			if log == closeMarker {
+				return
 			}
-			if err == nil {
-				w.file = file
-				w.writer = file
+			w.flushed()
+			continue
+		case ok && w.batchSize <= 0:
+			next = w.writeDrained(log)
+			continue
+		case ok:
+			urgent := w.urgent(*log)
+			buf = append(buf, *log...)
+			ends = append(ends, len(buf))
+			putBuf(log)
+			if len(buf) < w.batchSize && !urgent {
+				continue
 			}
-			if totalNum >= w.maxNum {
-				//大日志文件个数超过20个
-				//remove oldest log file
-				name = fmt.Sprintf("%s.full.%d.log", w.filePath, minNum)
-				err := os.Remove(name)
-				if err != nil {
-					//Remove删除老日志文件失败
-					fmt.Printf("remove file path:%s fail:%s\n", name, err)
-				}
+			w.writeOut(buf, ends)
+			buf, ends = buf[:0], ends[:0]
+			if urgent {
+				w.syncNow()
 			}
+			continue
+		case len(buf) == 0:
+			continue
 		}
-		w.mu.Unlock()
+		w.writeOut(buf, ends)
+		buf, ends = buf[:0], ends[:0]
 	}
 }
 
-// rotate 按天更新日志文件名
-func (w *FileWriter) rotate() {
-	for {
-		now := time.Now()
-		y, m, d := now.Add(24 * time.Hour).Date()
-		nextDay := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
-		tm := time.NewTimer(time.Duration(nextDay.UnixNano() - now.UnixNano() - 100))
-		<-tm.C
-		w.mu.Lock()
-		path := fmt.Sprintf(logFileNameFormat, w.fileName, y, m, d)
-		file, e := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
-		if e == nil {
-			w.file.Close()
-			w.file = file
-			w.writer = file
-			w.filePath = path
+// writeOut 把 p 写入当前日志文件并归还内存预算，ends 为 p 中每条日志的结束位置
+func (w *FileWriter) writeOut(p []byte, ends []int) {
+	w.writeFile(p, len(ends), ends)
+	releaseMem(len(p))
+	w.checkDropping()
+	w.checkLowWater()
+	w.checkSpill()
+}
+
+// writeFile 持锁写入当前日志文件，n 为 p 中的日志条数；ends 不为 nil 时为每条日志的结束位置，
+// 多条日志合并写入时据此在日志边界拆分，按大小轮转的粒度与逐条写入相同，文件不会因合并写入超过 maxSize
+func (w *FileWriter) writeFile(p []byte, n int, ends []int) {
+	w.lock()
+	w.sharedLock()
+	var err error
+	for off := 0; off < len(p) && err == nil; {
+		end := w.segmentEnd(p, off, ends)
+		err = w.writeChunk(p[off:end])
+		off = end
+	}
+	if err != nil {
+		w.resetBuffer()
+		err = fmt.Errorf("write file path:%s fail:%w", w.filePath, err)
+	} else {
+		w.afterWrite(n)
+		w.lastWrite = w.now()
+		if w.firstWrite.IsZero() {
+			w.firstWrite = w.lastWrite
 		}
-		w.mu.Unlock()
+	}
+	w.unlockFile()
+	w.mu.Unlock()
+	if err != nil {
+		w.writeFailed(err)
+		w.onError(err)
+	} else {
+		atomic.AddUint64(&w.counters.written, uint64(n))
+		w.wrote()
 	}
 }
 
-// flush 刷新日志到磁盘中
-func (w *FileWriter) flush() {
-	for {
-		log := <-w.ch
-		w.mu.Lock()
-		w.writer.Write(log)
-		w.mu.Unlock()
+// writeChunk 轮转后写入一段日志，调用方需持有锁
+func (w *FileWriter) writeChunk(p []byte) error {
+	p = w.stamp(p)
+	w.maybeRotate(len(p))
+	wn, err := w.writeActive(p)
+	w.size += int64(wn)
+	return err
+}
+
+// segmentEnd 返回从 p[off:] 开始能写入当前文件剩余空间的最后一条日志的结束位置，至少包含一条日志；
+// 开启 WithWriteTime 时每行加上的时间也计入大小
+func (w *FileWriter) segmentEnd(p []byte, off int, ends []int) int {
+	if len(ends) == 0 || w.maxSize <= 0 {
+		return len(p)
+	}
+	room := w.maxSize - w.size
+	i := sort.SearchInts(ends, off+1)
+	if i == len(ends) {
+		return len(p)
+	}
+	width := int64(w.stampWidth())
+	end := ends[i]
+	size := int64(end-off) + width*int64(lineCount(p[off:end]))
+	for _, e := range ends[i+1:] {
+		size += int64(e-end) + width*int64(lineCount(p[end:e]))
+		if size > room {
+			break
+		}
+		end = e
 	}
+	return end
 }
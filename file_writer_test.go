@@ -0,0 +1,280 @@
+package h2sanlog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestWriter 在临时目录中新建 FileWriter，测试结束时关闭
+func newTestWriter(t *testing.T, maxSize int64, maxNum int, opts ...FileOption) *FileWriter {
+	t.Helper()
+	w, err := NewFileWriter(filepath.Join(t.TempDir(), "app"), maxSize, maxNum, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+// activeContent 读取当前日志文件的内容
+func activeContent(t *testing.T, w *FileWriter) string {
+	t.Helper()
+	w.mu.Lock()
+	path := w.filePath
+	w.mu.Unlock()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// waitFor 等待 cond 成立，超时失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteFlush(t *testing.T) {
+	w := newTestWriter(t, 0, 0)
+	for _, s := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeContent(t, w); got != "a\nb\nc\n" {
+		t.Fatalf("got %q", got)
+	}
+	if st := w.Stats(); st.Written != 3 {
+		t.Fatalf("Written = %d, want 3", st.Written)
+	}
+}
+
+func TestBatchBySize(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithBatch(10, time.Hour))
+	w.Write([]byte("1234\n"))
+	time.Sleep(20 * time.Millisecond)
+	if got := activeContent(t, w); got != "" {
+		t.Fatalf("written before batch full: %q", got)
+	}
+	w.Write([]byte("5678\n"))
+	waitFor(t, "batch write", func() bool { return activeContent(t, w) == "1234\n5678\n" })
+}
+
+func TestBatchByInterval(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithBatch(1<<20, 10*time.Millisecond))
+	w.Write([]byte("tick\n"))
+	waitFor(t, "interval write", func() bool { return activeContent(t, w) == "tick\n" })
+}
+
+func TestBatchFlushAndClose(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithBatch(1<<20, time.Hour))
+	w.Write([]byte("a\n"))
+	if err := w.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeContent(t, w); got != "a\n" {
+		t.Fatalf("after Flush got %q", got)
+	}
+	w.Write([]byte("b\n"))
+	path := w.filePath
+	w.Close()
+	b, _ := ioutil.ReadFile(path)
+	if string(b) != "a\nb\n" {
+		t.Fatalf("after Close got %q", b)
+	}
+}
+
+func TestBatchNegativeSize(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithBatch(-1, time.Second))
+	w.Write([]byte("x\n"))
+	if err := w.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeContent(t, w); got != "x\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestDrainBatchOrder(t *testing.T) {
+	for _, n := range []int{1, 64} {
+		w := newTestWriter(t, 0, 0, WithDrainBatch(n), WithRingBuffer(4096))
+		var want strings.Builder
+		for i := 0; i < 2000; i++ {
+			line := strings.Repeat("x", i%50) + "\n"
+			want.WriteString(line)
+			if _, err := w.Write([]byte(line)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if got := activeContent(t, w); got != want.String() {
+			t.Fatalf("drain %d: content mismatch", n)
+		}
+	}
+}
+
+func TestDrainBatchKeepsMaxSize(t *testing.T) {
+	w := newTestWriter(t, 100, 0, WithRingBuffer(1024), WithOnError(func(error) {}))
+	for i := 0; i < 200; i++ {
+		w.Write([]byte("0123456789abcdefghi\n"))
+	}
+	if err := w.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, f := range w.listDir() {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 100 {
+			t.Fatalf("%s has %d bytes, over maxSize", filepath.Base(f), len(b))
+		}
+		total += strings.Count(string(b), "0123456789abcdefghi\n")
+	}
+	if total != 200 {
+		t.Fatalf("lines = %d, want 200", total)
+	}
+}
+
+func TestSharedWriterConflict(t *testing.T) {
+	var errs errorLog
+	name := filepath.Join(t.TempDir(), "app")
+	w, err := NewFileWriter(name, 100, 3, WithOnError(errs.fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	same, err := NewFileWriter(name, 100, 3)
+	if err != nil || same != w {
+		t.Fatalf("same settings: %p, %v", same, err)
+	}
+	same.Close()
+	if len(errs.get()) != 0 {
+		t.Fatalf("reported without opts: %v", errs.get())
+	}
+	for _, args := range [][2]int{{200, 3}, {100, 5}} {
+		if _, err := NewFileWriter(name, int64(args[0]), args[1]); !errors.Is(err, ErrSharedConflict) {
+			t.Fatalf("%v: err = %v", args, err)
+		}
+	}
+	again, err := NewFileWriter(name, 100, 3, WithBatch(1024, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Close()
+	if got := errs.get(); len(got) != 1 || !strings.Contains(got[0].Error(), "opts ignored") {
+		t.Fatalf("ignored opts not reported: %v", got)
+	}
+	if w.batchSize != 0 {
+		t.Fatal("second caller's opts applied")
+	}
+}
+
+// writerFunc 把函数作为 io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// 创建期间的回调在释放 shared 锁后调用，回调中再 NewFileWriter、Close 不会死锁
+func TestNewFileWriterCallbacksUnlocked(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.log.full.1.log", "app.log.full.2.log", "app.log"} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0644)
+	}
+	reopen := func() {
+		o, err := NewFileWriter(filepath.Join(dir, "other"), 0, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		o.Close()
+	}
+	var removed, replayed int
+	done := make(chan *FileWriter)
+	go func() {
+		w, err := NewFileWriter(filepath.Join(dir, "app"), 0, 0, WithFilePattern("app.log"), WithRetentionBudget(2, 0),
+			WithOnRemove(func(string) { removed++; reopen() }),
+			WithReplay(1, writerFunc(func(p []byte) (int, error) { replayed++; reopen(); return len(p), nil })))
+		if err != nil {
+			t.Error(err)
+		}
+		done <- w
+	}()
+	select {
+	case w := <-done:
+		if w != nil {
+			w.Close()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("NewFileWriter deadlocked in a callback")
+	}
+	if removed != 1 || replayed == 0 {
+		t.Fatalf("removed = %d, replayed = %d", removed, replayed)
+	}
+}
+
+// 打开日志文件失败时关闭已打开的锁文件
+func TestNewFileWriterClosesLockFile(t *testing.T) {
+	fds := func() int {
+		files, err := ioutil.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skip("no /proc/self/fd")
+		}
+		return len(files)
+	}
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "app.log"), 0755)
+	before := fds()
+	for i := 0; i < 3; i++ {
+		if _, err := NewFileWriter(filepath.Join(dir, "app"), 0, 0, WithFilePattern("app.log"), WithFileLock()); err == nil {
+			t.Fatal("opened a directory as the log file")
+		}
+	}
+	if after := fds(); after != before {
+		t.Fatalf("fds = %d, want %d", after, before)
+	}
+}
+
+// Close 之前通过检查、关闭标记之后才入队的日志被丢弃，归还内存预算
+func TestLateSendAfterClose(t *testing.T) {
+	w, err := NewFileWriter(filepath.Join(t.TempDir(), "app"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	before := atomic.LoadInt64(&memUsed)
+	buf := getBuf()
+	*buf = append(*buf, "late\n"...)
+	acquireMem(len(*buf))
+	if _, err := w.send(buf); !errors.Is(err, ErrClosed) {
+		t.Fatalf("err = %v, want ErrClosed", err)
+	}
+	if used := atomic.LoadInt64(&memUsed); used != before {
+		t.Fatalf("memUsed = %d, want %d", used, before)
+	}
+	if n := w.queue.len(); n != 0 {
+		t.Fatalf("queue len = %d", n)
+	}
+	if st := w.Stats(); st.Dropped != 1 {
+		t.Fatalf("Dropped = %d", st.Dropped)
+	}
+}
@@ -0,0 +1,82 @@
+package h2sanlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// logFile writer 创建的一个日志文件
+type logFile struct {
+	path string
+	info os.FileInfo
+}
+
+// logFiles 返回本writer创建的所有日志文件（当天文件、历史文件和 .full 文件），按修改时间从旧到新排序
+func (w *FileWriter) logFiles() []logFile {
+	dir := filepath.Dir(w.fileName)
+	var list []logFile
+	consider := func(path string, fi os.FileInfo) {
+		if !fi.IsDir() && w.ownsName(fi.Name()) {
+			list = append(list, logFile{path, fi})
+		}
+	}
+	if w.layout == dateLayout {
+		// 年/月/日三层数字目录
+		for _, y := range numericDirs(dir) {
+			for _, m := range numericDirs(y) {
+				for _, d := range numericDirs(m) {
+					files, _ := ioutil.ReadDir(d)
+					for _, f := range files {
+						consider(filepath.Join(d, f.Name()), f)
+					}
+				}
+			}
+		}
+	}
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		if w.layout == dateLayout {
+			break
+		}
+		if w.layout == dailyLayout {
+			if !f.IsDir() {
+				continue
+			}
+			sub, _ := ioutil.ReadDir(filepath.Join(dir, f.Name()))
+			for _, s := range sub {
+				consider(filepath.Join(dir, f.Name(), s.Name()), s)
+			}
+			continue
+		}
+		consider(filepath.Join(dir, f.Name()), f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].info.ModTime().Before(list[j].info.ModTime()) })
+	return list
+}
+
+// numericDirs 返回 dir 下名字全为数字的子目录
+func numericDirs(dir string) []string {
+	files, _ := ioutil.ReadDir(dir)
+	var dirs []string
+	for _, f := range files {
+		if f.IsDir() && strings.Trim(f.Name(), "0123456789") == "" {
+			dirs = append(dirs, filepath.Join(dir, f.Name()))
+		}
+	}
+	return dirs
+}
+
+// pruneDirs 删除文件后清理空的日期目录，最多到 fileName 所在目录为止，调用方需持有锁
+func (w *FileWriter) pruneDirs(path string) {
+	active := filepath.Dir(filepath.Clean(w.filePath))
+	dir := filepath.Dir(filepath.Clean(path))
+	for i := 0; i < w.layout.depth() && dir != active; i++ {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package h2sanlog
+
+import "os"
+
+// closeBeforeReopen 打开的句柄不占用文件名，重建时可以先打开新文件再关闭旧文件
+const closeBeforeReopen = false
+
+// openAppend 以追加方式打开日志文件，不存在时创建
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+}
+
+// openShared 只读打开文件
+func openShared(path string) (*os.File, error) {
+	return os.Open(path)
+}
+
+// renameFile 重命名文件
+func renameFile(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+// isDeleted 文件已被删除
+func isDeleted(err error) bool {
+	return os.IsNotExist(err)
+}
@@ -0,0 +1,68 @@
+//go:build windows
+
+package h2sanlog
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Windows 上 os.OpenFile 不带 FILE_SHARE_DELETE，其他进程打开日志文件时无法重命名和删除，
+// 日志文件统一用 CreateFile 打开并允许共享删除
+
+const (
+	errorSharingViolation = syscall.Errno(32)
+	errorLockViolation    = syscall.Errno(33)
+	errorDeletePending    = syscall.Errno(303)
+)
+
+// closeBeforeReopen 打开的句柄会占住文件名，重建前需先关闭旧句柄
+const closeBeforeReopen = true
+
+const shareAll = syscall.FILE_SHARE_READ | syscall.FILE_SHARE_WRITE | syscall.FILE_SHARE_DELETE
+
+// openAppend 以追加方式打开日志文件，不存在时创建
+func openAppend(path string) (*os.File, error) {
+	return createFile(path, syscall.GENERIC_READ|syscall.FILE_APPEND_DATA, syscall.OPEN_ALWAYS)
+}
+
+// openShared 只读打开文件，不妨碍写入方轮转和删除
+func openShared(path string) (*os.File, error) {
+	return createFile(path, syscall.GENERIC_READ, syscall.OPEN_EXISTING)
+}
+
+func createFile(path string, access, mode uint32) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := syscall.CreateFile(p, access, shareAll, nil, mode, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
+
+// renameFile 重命名文件，杀毒软件、备份工具等短暂占用文件导致共享冲突时重试
+func renameFile(oldPath, newPath string) error {
+	var err error
+	for i := 1; i <= 5; i++ {
+		if err = os.Rename(oldPath, newPath); err == nil || !sharingViolation(err) {
+			return err
+		}
+		time.Sleep(time.Duration(i) * 10 * time.Millisecond)
+	}
+	return err
+}
+
+func sharingViolation(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation) ||
+		errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+}
+
+// isDeleted 文件已被删除；旧版本 Windows 删除仍被打开的文件时名字会保留到句柄关闭
+func isDeleted(err error) bool {
+	return os.IsNotExist(err) || errors.Is(err, errorDeletePending)
+}
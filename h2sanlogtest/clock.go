@@ -0,0 +1,118 @@
+package h2sanlogtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/h2san/h2sanlog"
+)
+
+// FakeClock 只在调用 Add/Set 时前进的时钟，到期的定时器在推进时触发，
+// 通过 h2sanlog.WithClock 注入 FileWriter 后可以直接跳到零点验证按天轮转
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+// NewFakeClock 新建从 now 开始的假时钟
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now 返回当前假时间
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Add 时间前进 d，并触发期间到期的定时器
+func (c *FakeClock) Add(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set 把时间设为 t，t 早于当前时间时不触发任何定时器
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	if t.Before(c.now) {
+		c.now = t
+		c.mu.Unlock()
+		return
+	}
+	c.now = t
+	var keep []*fakeTimer
+	for _, w := range c.waiters {
+		for !w.at.After(t) {
+			select {
+			case w.c <- w.at:
+			default:
+			}
+			if w.period <= 0 {
+				break
+			}
+			w.at = w.at.Add(w.period)
+		}
+		if w.at.After(t) {
+			keep = append(keep, w)
+		}
+	}
+	c.waiters = keep
+	c.mu.Unlock()
+}
+
+// Waiters 返回尚未触发或停止的定时器数量，可用于等待被测 goroutine 进入等待状态
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// NewTimer 实现 h2sanlog.Clock
+func (c *FakeClock) NewTimer(d time.Duration) h2sanlog.Timer {
+	return c.add(d, 0)
+}
+
+// NewTicker 实现 h2sanlog.Clock
+func (c *FakeClock) NewTicker(d time.Duration) h2sanlog.Ticker {
+	return fakeTicker{c.add(d, d)}
+}
+
+func (c *FakeClock) add(d, period time.Duration) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), at: c.now.Add(d), period: period}
+	c.waiters = append(c.waiters, t)
+	return t
+}
+
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, w := range c.waiters {
+		if w == t {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer FakeClock 的定时器，period>0 时为周期定时器
+type fakeTimer struct {
+	clock  *FakeClock
+	c      chan time.Time
+	at     time.Time
+	period time.Duration
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool { return t.clock.remove(t) }
+
+// fakeTicker 适配 h2sanlog.Ticker 无返回值的 Stop
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.c }
+
+func (t fakeTicker) Stop() { t.t.Stop() }
@@ -0,0 +1,58 @@
+package h2sanlogtest
+
+import (
+	"testing"
+	"time"
+)
+
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	tm := c.NewTimer(time.Minute)
+	c.Add(59 * time.Second)
+	if fired(tm.C()) {
+		t.Fatal("fired early")
+	}
+	c.Add(time.Second)
+	if !fired(tm.C()) {
+		t.Fatal("not fired at deadline")
+	}
+	if c.Waiters() != 0 || tm.Stop() {
+		t.Fatal("fired timer still pending")
+	}
+	if !c.Now().Equal(start.Add(time.Minute)) {
+		t.Fatalf("Now = %v", c.Now())
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	c := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	tk := c.NewTicker(time.Second)
+	c.Add(time.Second)
+	if !fired(tk.C()) {
+		t.Fatal("tick 1 missing")
+	}
+	// 一次跨过多个周期只保留一次触发，同 time.Ticker
+	c.Add(3 * time.Second)
+	if !fired(tk.C()) || fired(tk.C()) {
+		t.Fatal("want exactly one pending tick")
+	}
+	c.Set(c.Now().Add(-time.Hour))
+	if fired(tk.C()) {
+		t.Fatal("fired when time moved backwards")
+	}
+	tk.Stop()
+	c.Add(2 * time.Hour)
+	if fired(tk.C()) || c.Waiters() != 0 {
+		t.Fatal("fired after Stop")
+	}
+}
@@ -0,0 +1,104 @@
+// h2sanlogtest 测试辅助：记录日志用于断言的 ObservableWriter，以及可手动推进的 FakeClock
+package h2sanlogtest
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/h2san/h2sanlog"
+)
+
+// ObservableWriter 把日志保存在内存中供测试断言，作为 Logger 的输出时按 Entry 记录，
+// 作为普通 io.Writer 时按行记录
+type ObservableWriter struct {
+	mu      sync.Mutex
+	entries []h2sanlog.Entry
+	lines   []string
+	partial []byte
+}
+
+// NewObservableWriter 新建空的 ObservableWriter
+func NewObservableWriter() *ObservableWriter {
+	return &ObservableWriter{}
+}
+
+// WriteEntry 实现 h2sanlog.EntryWriter，保存 e 的副本
+func (o *ObservableWriter) WriteEntry(e *h2sanlog.Entry) error {
+	c := e.Clone()
+	line, err := h2sanlog.TextEncoder{}.Encode(&c)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	o.entries = append(o.entries, c)
+	o.lines = append(o.lines, strings.TrimSuffix(string(line), "\n"))
+	o.mu.Unlock()
+	return nil
+}
+
+// Write 实现 io.Writer，按换行切分后保存，不完整的最后一行等到下次写入
+func (o *ObservableWriter) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.lines = append(o.lines, string(o.partial[:i]))
+		o.partial = o.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Entries 返回已记录的结构化日志
+func (o *ObservableWriter) Entries() []h2sanlog.Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]h2sanlog.Entry(nil), o.entries...)
+}
+
+// Lines 返回已记录的日志行，不含换行符
+func (o *ObservableWriter) Lines() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.lines...)
+}
+
+// Len 返回已记录的日志行数
+func (o *ObservableWriter) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.lines)
+}
+
+// FilterLevel 返回指定级别的结构化日志
+func (o *ObservableWriter) FilterLevel(level uint8) []h2sanlog.Entry {
+	var out []h2sanlog.Entry
+	for _, e := range o.Entries() {
+		if e.Level == level {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FilterMessage 返回消息包含 substr 的结构化日志
+func (o *ObservableWriter) FilterMessage(substr string) []h2sanlog.Entry {
+	var out []h2sanlog.Entry
+	for _, e := range o.Entries() {
+		if strings.Contains(e.Message, substr) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Reset 清空已记录的日志
+func (o *ObservableWriter) Reset() {
+	o.mu.Lock()
+	o.entries, o.lines, o.partial = nil, nil, nil
+	o.mu.Unlock()
+}
@@ -0,0 +1,46 @@
+package h2sanlogtest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/h2san/h2sanlog"
+)
+
+func TestObservableWriterEntries(t *testing.T) {
+	o := NewObservableWriter()
+	l := h2sanlog.New(o, "", 0)
+	l.Info("started")
+	l.Log(h2sanlog.LogLevelError, "request failed", h2sanlog.String("path", "/a"))
+	l.Info("request done")
+
+	if n := o.Len(); n != 3 {
+		t.Fatalf("Len = %d", n)
+	}
+	errs := o.FilterLevel(h2sanlog.LogLevelError)
+	if len(errs) != 1 || errs[0].Message != "request failed" || errs[0].Fields[0].Interface() != "/a" {
+		t.Fatalf("FilterLevel = %+v", errs)
+	}
+	if got := o.FilterMessage("request"); len(got) != 2 {
+		t.Fatalf("FilterMessage = %+v", got)
+	}
+	o.Reset()
+	if o.Len() != 0 || len(o.Entries()) != 0 {
+		t.Fatal("not empty after Reset")
+	}
+}
+
+func TestObservableWriterLines(t *testing.T) {
+	o := NewObservableWriter()
+	io.WriteString(o, "a\nb")
+	if got := o.Lines(); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("Lines = %q", got)
+	}
+	io.WriteString(o, "c\n")
+	if got := o.Lines(); len(got) != 2 || got[1] != "bc" {
+		t.Fatalf("Lines = %q", got)
+	}
+	if len(o.Entries()) != 0 {
+		t.Fatal("plain writes recorded as entries")
+	}
+}
@@ -0,0 +1,124 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// saturationLimit 队列占用超过该比例视为不健康
+const saturationLimit = 0.9
+
+// Health 日志写入链路的健康状态
+type Health struct {
+	// Healthy 未关闭、磁盘未满、未在丢日志、队列未接近写满，且最近一次写盘晚于最近一次错误
+	Healthy bool
+	// LastError LastErrorTime 最近一次内部错误及其时间，没有错误时为零值
+	LastError     error
+	LastErrorTime time.Time
+	// LastWrite 最近一次成功写盘的时间
+	LastWrite time.Time
+	// Saturation 队列占用比例，0~1
+	Saturation float64
+}
+
+// health 错误和写盘时间记录
+type health struct {
+	mu        sync.Mutex
+	lastErr   error
+	lastErrAt time.Time
+	lastWrite int64
+	// writeErr writeErrAt 最近一次写盘或 fsync 失败，不含队列满等瞬时错误
+	writeErr   error
+	writeErrAt int64
+}
+
+// recordErrors 包装 onError，先记录错误再回调
+func (w *FileWriter) recordErrors() {
+	fn := w.onError
+	w.onError = func(err error) {
+		w.health.mu.Lock()
+		w.health.lastErr = err
+		w.health.lastErrAt = time.Now()
+		w.health.mu.Unlock()
+		fn(err)
+	}
+}
+
+// writeFailed 记录一次写盘或 fsync 失败
+func (w *FileWriter) writeFailed(err error) {
+	w.health.mu.Lock()
+	w.health.writeErr = err
+	w.health.writeErrAt = time.Now().UnixNano()
+	w.health.mu.Unlock()
+}
+
+// sinkError 返回最近一次写盘或 fsync 错误，之后已有成功写盘时返回 nil；
+// 异步写入的错误不会从 Write 返回，FailoverWriter 据此判断文件是否写坏
+func (w *FileWriter) sinkError() error {
+	w.health.mu.Lock()
+	err, at := w.health.writeErr, w.health.writeErrAt
+	w.health.mu.Unlock()
+	if err == nil || atomic.LoadInt64(&w.health.lastWrite) > at {
+		return nil
+	}
+	return err
+}
+
+// wrote 记录一次成功写盘
+func (w *FileWriter) wrote() {
+	atomic.StoreInt64(&w.health.lastWrite, time.Now().UnixNano())
+}
+
+// Health 返回当前健康状态
+func (w *FileWriter) Health() Health {
+	w.health.mu.Lock()
+	h := Health{LastError: w.health.lastErr, LastErrorTime: w.health.lastErrAt}
+	w.health.mu.Unlock()
+	if n := atomic.LoadInt64(&w.health.lastWrite); n > 0 {
+		h.LastWrite = time.Unix(0, n)
+	}
+	if c := w.queue.cap(); c > 0 {
+		h.Saturation = float64(w.queue.len()) / float64(c)
+	}
+	h.Healthy = atomic.LoadInt32(&w.closed) == 0 && atomic.LoadInt32(&w.diskFull) == 0 &&
+		atomic.LoadInt32(&w.dropping) == 0 && h.Saturation < saturationLimit &&
+		!h.LastErrorTime.After(h.LastWrite)
+	return h
+}
+
+// Healthy 返回当前是否健康，见 Health
+func (w *FileWriter) Healthy() bool {
+	return w.Health().Healthy
+}
+
+// LastError 返回最近一次内部错误，没有错误时返回 nil
+func (w *FileWriter) LastError() error {
+	w.health.mu.Lock()
+	defer w.health.mu.Unlock()
+	return w.health.lastErr
+}
+
+// HealthHandler 就绪探针接口，所有 FileWriter 都健康时返回 200，否则返回 503 和不健康的文件及原因
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ok := true
+		for _, w := range registered() {
+			h := w.Health()
+			if h.Healthy {
+				continue
+			}
+			if ok {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				ok = false
+			}
+			fmt.Fprintf(rw, "%s: saturation=%.2f last_write=%s last_error=%v\n",
+				w.fileName, h.Saturation, h.LastWrite.Format(time.RFC3339), h.LastError)
+		}
+		if ok {
+			fmt.Fprintln(rw, "ok")
+		}
+	})
+}
@@ -0,0 +1,18 @@
+package h2sanlog
+
+// Hook 写日志前后的扩展点，用于补充字段、过滤、转发和告警
+type Hook interface {
+	// BeforeWrite 编码前调用，可修改 e，返回 false 丢弃该条日志；设置了打码器时 e 已打码。
+	// e.Fields 与 logger 共享，修改时需 append 或重新分配，不能原地改写元素。
+	// e 来自对象池，hook 返回后不能继续持有，需要保留请 Clone
+	BeforeWrite(e *Entry) bool
+	// AfterWrite 写入后调用，err 为写入错误
+	AfterWrite(e *Entry, err error)
+}
+
+// HookFunc 只关心写入前的 hook
+type HookFunc func(e *Entry) bool
+
+func (f HookFunc) BeforeWrite(e *Entry) bool { return f(e) }
+
+func (f HookFunc) AfterWrite(e *Entry, err error) {}
@@ -0,0 +1,57 @@
+package h2sanlog
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+)
+
+// processInfo 启动后只取一次的主机名、pid 和构建信息
+var processInfo struct {
+	once    sync.Once
+	host    string
+	pid     int
+	app     string
+	version string
+}
+
+func loadProcessInfo() {
+	processInfo.once.Do(func() {
+		processInfo.host, _ = os.Hostname()
+		processInfo.pid = os.Getpid()
+		processInfo.app = filepath.Base(os.Args[0])
+		if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "(devel)" {
+			processInfo.version = bi.Main.Version
+		}
+	})
+}
+
+// HostFields 返回标识进程身份的字段 host、pid、app、version、instance，主机名、pid 和默认值在首次调用时取一次。
+// app 为空时为可执行文件名，version 为空时为构建信息中的主模块版本，instance 为空时不输出，
+// 用于区分同一台机器上的多个实例；取不到的字段不输出
+func HostFields(app, version, instance string) []Field {
+	loadProcessInfo()
+	if app == "" {
+		app = processInfo.app
+	}
+	if version == "" {
+		version = processInfo.version
+	}
+	fields := make([]Field, 0, 5)
+	if processInfo.host != "" {
+		fields = append(fields, Field{Key: "host", Value: processInfo.host})
+	}
+	fields = append(fields, Field{Key: "pid", Value: processInfo.pid})
+	for _, f := range []Field{{Key: "app", Value: app}, {Key: "version", Value: version}, {Key: "instance", Value: instance}} {
+		if f.Value != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// WithHostFields 返回每条日志都附带 HostFields 的子logger，汇总整个集群的日志时无需每次调用都带上来源
+func (l *Logger) WithHostFields(app, version, instance string) *Logger {
+	return l.WithFields(HostFields(app, version, instance)...)
+}
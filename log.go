@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -20,8 +22,29 @@ const (
 // 默认log级别
 var defaultLogLevel uint8 = LogLevelDebug
 
-type Logger struct {
-	*log.Logger
+// ParseLevel 解析级别名（不区分大小写，warn 同 warning）或数字
+func ParseLevel(s string) (uint8, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "null", "none", "off":
+		return LogLevelNull, nil
+	case "trace":
+		return LogLevelTrace, nil
+	case "debug":
+		return LogLevelDebug, nil
+	case "info":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarning, nil
+	case "error":
+		return LogLevelError, nil
+	case "fatal":
+		return LogLevelFatal, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < LogLevelNull || n > LogLevelFatal {
+		return 0, fmt.Errorf("h2sanlog: unknown level %q", s)
+	}
+	return uint8(n), nil
 }
 
 func SetFlags(flag int) {
@@ -76,4 +99,6 @@ func Fatal(format string, v ...interface{}) {
 		return
 	}
 	log.Output(2, string("[FATAL] ")+fmt.Sprintf(format, v...))
+	FlushAll(exitTimeout)
+	exitFunc(1)
 }
@@ -0,0 +1,477 @@
+package h2sanlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// levelNames 日志级别对应的输出标签
+var levelNames = [...]string{
+	LogLevelNull:    "",
+	LogLevelTrace:   "TRACE",
+	LogLevelDebug:   "DEBUG",
+	LogLevelInfo:    "INFO",
+	LogLevelWarning: "WARNING",
+	LogLevelError:   "ERROR",
+	LogLevelFatal:   "FATAL",
+}
+
+// ErrInvalidLevel 日志级别超出 LogLevelNull~LogLevelFatal，不论是否严格模式都返回
+var ErrInvalidLevel = errors.New("h2sanlog: invalid log level")
+
+// Field 日志键值对。String、Int、Int64、Uint64、Float64、Bool、Duration 构造的字段把值存在类型化的字段中，
+// 不装箱为 interface{}，此时 Value 为 nil；其余字段的值在 Value 中。读取值统一使用 Interface
+type Field struct {
+	Key   string
+	Value interface{}
+	kind  fieldKind
+	num   int64
+	str   string
+}
+
+// Logger 日志对象，可通过 With/Named 派生带固定字段和名字的子logger
+type Logger struct {
+	*log.Logger
+	level  uint8
+	name   string
+	fields []Field
+	strict bool
+	// sync Synced 返回的子logger，同步写入且不采样、不限流
+	sync   bool
+	caller bool
+	skip   int
+	// stackLevel 大于等于该级别的日志附带当前goroutine调用栈，LogLevelNull 表示不附带
+	stackLevel uint8
+	router     *Router
+	// sinks 与 router 规则一一对应，沿用当前 logger 的 prefix 和 flag
+	sinks []*log.Logger
+	// samplers 按级别设置的采样器，nil 表示不采样
+	samplers [LogLevelFatal + 1]*Sampler
+	limiter  *RateLimiter
+	redactor *Redactor
+	hooks    []Hook
+	// observers 父子logger共享
+	observers *observerSet
+	// fieldsSum 调试模式下 With 时字段的摘要
+	fieldsSum uint64
+	schedule  *Schedule
+	multiline Multiline
+	filters   []func(Entry) bool
+	repeat    *RepeatSuppressor
+	burst     *BurstMonitor
+}
+
+// New 新建一个Logger，flag 同标准库 log 的 flag
+func New(w io.Writer, prefix string, flag int) *Logger {
+	return &Logger{Logger: log.New(w, prefix, flag), level: LogLevelDebug, observers: &observerSet{}}
+}
+
+// SetLevel 设置logger的最低输出级别，只影响当前logger
+func (l *Logger) SetLevel(level uint8) {
+	l.level = level
+}
+
+// SetStrict 开启严格模式后，日志方法会返回写入过程中的错误（如队列满、写文件失败），
+// 默认模式下错误被忽略、始终返回 nil
+func (l *Logger) SetStrict(strict bool) {
+	l.strict = strict
+}
+
+// SetCaller 开启后每条日志带上调用方的 文件:行号 函数名，
+// skip 为额外跳过的调用栈层数，用于在业务自己封装的日志函数中定位到真实调用方
+func (l *Logger) SetCaller(enable bool, skip int) {
+	l.caller = enable
+	l.skip = skip
+}
+
+// SetStacktraceLevel 设置附带调用栈的最低级别，如 LogLevelError；LogLevelNull 关闭
+func (l *Logger) SetStacktraceLevel(level uint8) {
+	l.stackLevel = level
+}
+
+// SetRouter 设置路由，命中规则的日志写入规则对应的 sink，全部未命中写入默认输出；nil 取消路由
+func (l *Logger) SetRouter(r *Router) {
+	l.router = r
+	l.sinks = nil
+	if r == nil {
+		return
+	}
+	for _, rt := range r.routes {
+		w := rt.w
+		if l.sync {
+			w = syncOf(w)
+		}
+		l.sinks = append(l.sinks, log.New(w, l.Prefix(), l.Flags()))
+	}
+}
+
+// SetSampler 为某个级别设置采样器，nil 取消采样；子logger与父logger共用同一个采样器计数，无效的级别忽略
+func (l *Logger) SetSampler(level uint8, s *Sampler) {
+	if level > LogLevelFatal {
+		return
+	}
+	l.samplers[level] = s
+}
+
+// SetRateLimiter 设置限流器，被限流的日志丢弃，同一key再次放行时先输出一条 "suppressed N messages" 汇总
+func (l *Logger) SetRateLimiter(r *RateLimiter) {
+	l.limiter = r
+}
+
+// SetRedactor 设置打码器，每条日志在 hook 之前和编码前对敏感字段和内容打码
+func (l *Logger) SetRedactor(r *Redactor) {
+	l.redactor = r
+}
+
+// RegisterHook 注册 hook，按注册顺序执行；子logger继承派生时已注册的 hook。
+// 设置了打码器时 hook 收到的是打码后的日志
+func (l *Logger) RegisterHook(h Hook) {
+	l.hooks = append(l.hooks[:len(l.hooks):len(l.hooks)], h)
+}
+
+// AddFilter 添加过滤条件，fn 返回 true 的日志直接丢弃（如健康检查请求、已知无害的错误），
+// 在采样、限流和 hook 之前执行；子logger继承派生时已添加的条件
+func (l *Logger) AddFilter(fn func(Entry) bool) {
+	l.filters = append(l.filters[:len(l.filters):len(l.filters)], fn)
+}
+
+// SetSchedule 设置按时间段生效的级别和采样策略，命中时间段时代替 SetLevel 的级别，nil 取消
+func (l *Logger) SetSchedule(s *Schedule) {
+	l.schedule = s
+}
+
+// With 返回附带键值对的子logger，kv 按 key, value 交替传入，key 缺少 value 时记为 nil
+func (l *Logger) With(kv ...interface{}) *Logger {
+	c := l.clone()
+	for i := 0; i < len(kv); i += 2 {
+		f := Field{Key: fmt.Sprint(kv[i])}
+		if i+1 < len(kv) {
+			f.Value = kv[i+1]
+		}
+		c.fields = append(c.fields, f)
+	}
+	c.fields = copyFields(c.fields)
+	if debugChecking() {
+		c.fieldsSum = fingerprint("", c.fields)
+	}
+	return c
+}
+
+// Named 返回带名字的子logger，多次调用名字以 "." 连接
+func (l *Logger) Named(name string) *Logger {
+	c := l.clone()
+	if c.name == "" {
+		c.name = name
+	} else if name != "" {
+		c.name = c.name + "." + name
+	}
+	return c
+}
+
+// clone 复制logger，fields 重新分配避免父子logger共享底层数组
+func (l *Logger) clone() *Logger {
+	c := *l
+	c.fields = make([]Field, len(l.fields), len(l.fields)+2)
+	copy(c.fields, l.fields)
+	return &c
+}
+
+func (l *Logger) Trace(v ...interface{}) error {
+	return l.output(LogLevelTrace, "", false, v, nil)
+}
+
+func (l *Logger) Debug(v ...interface{}) error {
+	return l.output(LogLevelDebug, "", false, v, nil)
+}
+
+func (l *Logger) Info(v ...interface{}) error {
+	return l.output(LogLevelInfo, "", false, v, nil)
+}
+
+func (l *Logger) Warning(v ...interface{}) error {
+	return l.output(LogLevelWarning, "", false, v, nil)
+}
+
+func (l *Logger) Error(v ...interface{}) error {
+	return l.output(LogLevelError, "", false, v, nil)
+}
+
+// Fatal 写入后等待所有 FileWriter 写盘并 fsync，再以退出码 1 调用 SetExitFunc 设置的退出函数（默认 os.Exit）
+func (l *Logger) Fatal(v ...interface{}) error {
+	err := l.output(LogLevelFatal, "", false, v, nil)
+	FlushAll(exitTimeout)
+	exitFunc(1)
+	return err
+}
+
+// Panic 以 Fatal 级别写入，等待所有 FileWriter 写盘并 fsync 后以消息 panic
+func (l *Logger) Panic(v ...interface{}) {
+	l.output(LogLevelFatal, "", false, v, nil)
+	FlushAll(exitTimeout)
+	panic(message("", false, v))
+}
+
+// Tracef 按 format 格式化消息，级别未开启时不格式化
+func (l *Logger) Tracef(format string, v ...interface{}) error {
+	return l.output(LogLevelTrace, format, true, v, nil)
+}
+
+// Debugf 同 Tracef
+func (l *Logger) Debugf(format string, v ...interface{}) error {
+	return l.output(LogLevelDebug, format, true, v, nil)
+}
+
+// Infof 同 Tracef
+func (l *Logger) Infof(format string, v ...interface{}) error {
+	return l.output(LogLevelInfo, format, true, v, nil)
+}
+
+// Warnf 同 Tracef
+func (l *Logger) Warnf(format string, v ...interface{}) error {
+	return l.output(LogLevelWarning, format, true, v, nil)
+}
+
+// Errorf 同 Tracef
+func (l *Logger) Errorf(format string, v ...interface{}) error {
+	return l.output(LogLevelError, format, true, v, nil)
+}
+
+// Fatalf 同 Fatal，按 format 格式化消息
+func (l *Logger) Fatalf(format string, v ...interface{}) error {
+	err := l.output(LogLevelFatal, format, true, v, nil)
+	FlushAll(exitTimeout)
+	exitFunc(1)
+	return err
+}
+
+// Panicf 同 Panic，按 format 格式化消息
+func (l *Logger) Panicf(format string, v ...interface{}) {
+	l.output(LogLevelFatal, format, true, v, nil)
+	FlushAll(exitTimeout)
+	panic(fmt.Sprintf(format, v...))
+}
+
+// output 生成 Entry 并写入默认输出或路由命中的 sink，f 为 true 时按 format 格式化 v，否则同 fmt.Sprint；
+// v 为空时 format 即消息，extra 为只属于本条日志的字段
+func (l *Logger) output(level uint8, format string, f bool, v []interface{}, extra []Field) error {
+	if level > LogLevelFatal {
+		return ErrInvalidLevel
+	}
+	var now time.Time
+	var win *window
+	if l.schedule != nil {
+		now = time.Now()
+		win = l.schedule.active(now)
+	}
+	if ml, ok := moduleLevel(l.name); ok {
+		if ml > level {
+			return nil
+		}
+	} else if win != nil && win.hasLevel {
+		if win.level > level {
+			return nil
+		}
+	} else if l.level > level {
+		return nil
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if l.fieldsSum != 0 && debugChecking() && fingerprint("", l.fields) != l.fieldsSum {
+		reportMutation("fields of logger %q mutated after With: %v", l.name, l.fields)
+	}
+	e := getEntry()
+	defer putEntry(e)
+	*e = Entry{Time: now, Level: level, Name: l.name, Message: message(format, f, v), Fields: entryFields(l.fields, extra)}
+	for _, f := range l.filters {
+		if f(*e) {
+			return nil
+		}
+	}
+	if l.burst != nil {
+		l.burst.observe(e)
+	}
+	if s := l.samplers[level]; s != nil && !l.sync && !s.Sample(e) {
+		return nil
+	}
+	if win != nil && win.sampler != nil && !l.sync && !win.sampler.Sample(e) {
+		return nil
+	}
+	if l.caller {
+		e.Caller = caller(3 + l.skip)
+	}
+	if l.stackLevel != LogLevelNull && level >= l.stackLevel {
+		e.Stack = stack()
+	}
+	if l.limiter != nil && !l.sync {
+		ok, n := l.limiter.Allow(e)
+		if !ok {
+			return nil
+		}
+		if n > 0 {
+			sum := *e
+			sum.Message = fmt.Sprintf("suppressed %d messages: %s", n, e.Message)
+			sum.Fields = append(e.Fields[:len(e.Fields):len(e.Fields)], Field{Key: "suppressed", Value: n})
+			sum.Stack = nil
+			l.write(&sum, 3)
+		}
+	}
+	if l.repeat != nil && !l.sync {
+		dup, summary, from := l.repeat.check(l, e)
+		if dup {
+			return nil
+		}
+		if summary != nil {
+			from.write(summary, 3)
+		}
+	}
+	err := l.write(e, 3)
+	if !l.strict {
+		return nil
+	}
+	return err
+}
+
+// write 编码并写入默认输出或路由命中的 sink，输出实现 EntryWriter 时由其自行编码，depth 为 write 的调用方到业务代码的栈层数
+func (l *Logger) write(e *Entry, depth int) error {
+	// hook 拿到的是打码后的日志，hook 补充的字段和消息在 hook 之后再打码一次
+	if l.redactor != nil {
+		l.redactor.Redact(e)
+	}
+	for _, h := range l.hooks {
+		if !h.BeforeWrite(e) {
+			return nil
+		}
+	}
+	if l.redactor != nil && len(l.hooks) > 0 {
+		l.redactor.Redact(e)
+	}
+	var err error
+	if l.router == nil || !l.router.each(e, func(i int) {
+		var werr error
+		rw := l.router.routes[i].w
+		if l.sync {
+			rw = syncOf(rw)
+		}
+		if ew, ok := rw.(EntryWriter); ok {
+			werr = ew.WriteEntry(e)
+		} else {
+			werr = l.sinks[i].Output(depth+3, l.multiline.apply(formatText(e)))
+		}
+		if werr != nil && err == nil {
+			err = werr
+		}
+	}) {
+		if ew, ok := l.Writer().(EntryWriter); ok {
+			err = ew.WriteEntry(e)
+		} else {
+			err = l.Logger.Output(depth+1, l.multiline.apply(formatText(e)))
+		}
+	}
+	var sum uint64
+	debug := len(l.hooks) > 0 && debugChecking()
+	if debug {
+		sum = fingerprint(e.Message, e.Fields)
+	}
+	for _, h := range l.hooks {
+		h.AfterWrite(e, err)
+	}
+	if debug && fingerprint(e.Message, e.Fields) != sum {
+		reportMutation("entry %q mutated in Hook.AfterWrite", e.Message)
+	}
+	if l.observers != nil {
+		l.observers.notify(e)
+	}
+	return err
+}
+
+// message 生成消息文本
+func message(format string, f bool, v []interface{}) string {
+	if f {
+		return fmt.Sprintf(format, v...)
+	}
+	if len(v) == 0 {
+		return format
+	}
+	return fmt.Sprint(v...)
+}
+
+// entryFields 合并 logger 的字段和本条日志的字段，只有一方时不分配
+func entryFields(fields, extra []Field) []Field {
+	if len(extra) == 0 {
+		return fields[:len(fields):len(fields)]
+	}
+	if len(fields) == 0 {
+		return extra[:len(extra):len(extra)]
+	}
+	return append(fields[:len(fields):len(fields)], extra...)
+}
+
+// formatText 文本格式: [INFO] [name] file:line func k=v msg，时间等前缀由标准库 log 的 flag 控制
+func formatText(e *Entry) string {
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(levelNames[e.Level])
+	b.WriteString("] ")
+	if e.Name != "" {
+		b.WriteString("[")
+		b.WriteString(e.Name)
+		b.WriteString("] ")
+	}
+	if e.Caller != "" {
+		b.WriteString(e.Caller)
+		b.WriteString(" ")
+	}
+	var buf []byte
+	for _, f := range e.Fields {
+		b.WriteString(f.Key)
+		b.WriteString("=")
+		var ok bool
+		if buf, ok = appendField(buf[:0], f); ok {
+			b.Write(buf)
+		} else {
+			fmt.Fprintf(&b, "%v", f.Value)
+		}
+		b.WriteString(" ")
+	}
+	b.WriteString(e.Message)
+	if len(e.Stack) > 0 {
+		b.WriteString("\n")
+		b.Write(e.Stack)
+	}
+	return b.String()
+}
+
+// caller 返回调用栈 skip 层处的 文件:行号 函数名，函数名去掉包路径前缀
+func caller(skip int) string {
+	pc, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "???:0"
+	}
+	fn := "???"
+	if f := runtime.FuncForPC(pc); f != nil {
+		fn = f.Name()
+		if i := strings.LastIndex(fn, "/"); i >= 0 {
+			fn = fn[i+1:]
+		}
+	}
+	return fmt.Sprintf("%s:%d %s", filepath.Base(file), line, fn)
+}
+
+// stack 返回当前goroutine的调用栈
+func stack() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
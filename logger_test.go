@@ -0,0 +1,60 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvalidLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetSampler(LogLevelFatal+1, nil)
+	m := NewBurstMonitor(LogLevelError, 10, time.Second, func(BurstAlert) {})
+	l.SetBurstMonitor(m)
+	for _, level := range []uint8{LogLevelFatal + 1, 255} {
+		if err := l.Log(level, "x"); !errors.Is(err, ErrInvalidLevel) {
+			t.Fatalf("Log(%d) err = %v", level, err)
+		}
+		if err := l.LogContext(context.Background(), level, "x"); !errors.Is(err, ErrInvalidLevel) {
+			t.Fatalf("LogContext(%d) err = %v", level, err)
+		}
+		l.AsStdLogger(level).Print("x")
+		if n := m.Count(level); n != 0 {
+			t.Fatalf("Count(%d) = %d", level, n)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("invalid level written: %q", buf.String())
+	}
+	if err := l.Log(LogLevelFatal, "ok"); err != nil || !bytes.Contains(buf.Bytes(), []byte("[FATAL] ok")) {
+		t.Fatalf("err = %v, got %q", err, buf.String())
+	}
+}
+
+func TestHooksSeeRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	r := NewRedactor("***").AddKey("password", "token")
+	if err := r.AddPattern(`\d{11}`); err != nil {
+		t.Fatal(err)
+	}
+	l.SetRedactor(r)
+	var seen string
+	l.RegisterHook(HookFunc(func(e *Entry) bool {
+		seen = fmt.Sprint(e.Message, e.Fields)
+		e.Fields = append(e.Fields[:len(e.Fields):len(e.Fields)], String("token", "secret-token"))
+		return true
+	}))
+	l.Log(LogLevelInfo, "call 13800138000", String("password", "hunter2"))
+	if strings.Contains(seen, "13800138000") || strings.Contains(seen, "hunter2") {
+		t.Fatalf("hook saw unredacted entry: %s", seen)
+	}
+	if out := buf.String(); strings.Contains(out, "secret-token") || strings.Contains(out, "hunter2") {
+		t.Fatalf("output not redacted: %q", out)
+	}
+}
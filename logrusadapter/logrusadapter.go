@@ -0,0 +1,94 @@
+// logrusadapter 让 logrus 的日志经过 h2sanlog 的 Encoder 编码并写入 FileWriter，方便已使用 logrus 的服务迁移
+package logrusadapter
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+
+	"github.com/h2san/h2sanlog"
+	"github.com/sirupsen/logrus"
+)
+
+// Formatter 实现 logrus.Formatter，用 h2sanlog 的 Encoder 编码，配合 logger.SetOutput(fileWriter) 使用
+type Formatter struct {
+	// Encoder 为 nil 时使用 h2sanlog.TextEncoder{Flag: log.LstdFlags}
+	Encoder h2sanlog.Encoder
+	// Name 写入 Entry.Name，对应 h2sanlog 的 logger 名
+	Name string
+}
+
+func (f *Formatter) Format(e *logrus.Entry) ([]byte, error) {
+	enc := f.Encoder
+	if enc == nil {
+		enc = h2sanlog.TextEncoder{Flag: log.LstdFlags}
+	}
+	entry := Convert(e)
+	entry.Name = f.Name
+	return enc.Encode(&entry)
+}
+
+// Hook 实现 logrus.Hook，把每条日志编码后写入 w，通常配合 logger.SetOutput(io.Discard) 使用
+type Hook struct {
+	w      io.Writer
+	enc    h2sanlog.Encoder
+	levels []logrus.Level
+}
+
+// NewHook 新建 hook，enc 为 nil 时使用文本格式，levels 为空时接收所有级别
+func NewHook(w io.Writer, enc h2sanlog.Encoder, levels ...logrus.Level) *Hook {
+	if len(levels) == 0 {
+		levels = logrus.AllLevels
+	}
+	return &Hook{w: w, enc: enc, levels: levels}
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *Hook) Fire(e *logrus.Entry) error {
+	f := Formatter{Encoder: h.enc}
+	data, err := f.Format(e)
+	if err != nil {
+		return err
+	}
+	_, err = h.w.Write(data)
+	return err
+}
+
+// Convert 把 logrus.Entry 转换为 h2sanlog.Entry，字段按 key 排序
+func Convert(e *logrus.Entry) h2sanlog.Entry {
+	entry := h2sanlog.Entry{Time: e.Time, Level: Level(e.Level), Message: e.Message}
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		entry.Fields = append(entry.Fields, h2sanlog.Field{Key: k, Value: e.Data[k]})
+	}
+	if e.HasCaller() {
+		entry.Caller = fmt.Sprintf("%s:%d %s", filepath.Base(e.Caller.File), e.Caller.Line, e.Caller.Function)
+	}
+	return entry
+}
+
+// Level 把 logrus 的级别转换为 h2sanlog 的级别，Panic 对应 Fatal
+func Level(level logrus.Level) uint8 {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return h2sanlog.LogLevelFatal
+	case logrus.ErrorLevel:
+		return h2sanlog.LogLevelError
+	case logrus.WarnLevel:
+		return h2sanlog.LogLevelWarning
+	case logrus.InfoLevel:
+		return h2sanlog.LogLevelInfo
+	case logrus.DebugLevel:
+		return h2sanlog.LogLevelDebug
+	}
+	return h2sanlog.LogLevelTrace
+}
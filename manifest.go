@@ -0,0 +1,120 @@
+package h2sanlog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ManifestRecord 清单中一个已轮转文件的记录
+type ManifestRecord struct {
+	// Name 相对清单所在目录的路径
+	Name   string    `json:"name"`
+	Size   int64     `json:"size"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	SHA256 string    `json:"sha256"`
+}
+
+// manifest 追加写清单，后台计算校验和时用 mu 保证每条记录完整
+type manifest struct {
+	mu   sync.Mutex
+	wg   sync.WaitGroup
+	path string
+}
+
+// WithManifest 每次轮转后计算刚关闭文件的 SHA-256，以 JSON 行追加到清单文件（文件名、大小、首末条日志的写入时间、校验和），
+// 供归档端用 VerifyManifest 确认日志未被截断或篡改；path 为空时为 <fileName>.manifest。
+// 校验和在后台goroutine中计算，不阻塞写日志，Close 时等待全部写入清单
+func WithManifest(path string) FileOption {
+	return func(w *FileWriter) {
+		if path == "" {
+			path = w.fileName + ".manifest"
+		}
+		w.manifest = &manifest{path: path}
+	}
+}
+
+// recordManifest 轮转后记录 path 的清单，调用方需持有锁
+func (w *FileWriter) recordManifest(path string, start, end time.Time) {
+	if w.manifest == nil {
+		return
+	}
+	w.manifest.wg.Add(1)
+	go func() {
+		defer w.manifest.wg.Done()
+		if err := w.manifest.append(path, start, end); err != nil {
+			w.onError(fmt.Errorf("manifest file path:%s fail:%w", path, err))
+		}
+	}()
+}
+
+func (m *manifest) append(path string, start, end time.Time) error {
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	name, err := filepath.Rel(filepath.Dir(m.path), path)
+	if err != nil {
+		name = path
+	}
+	line, err := json.Marshal(ManifestRecord{Name: filepath.ToSlash(name), Size: size, Start: start, End: end, SHA256: sum})
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := openLogFile(m.path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// fileSHA256 返回文件内容的 SHA-256 和大小
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// VerifyManifest 按清单逐个校验文件的大小和 SHA-256，返回不一致或缺失的记录；已被保留策略删除的文件也会返回
+func VerifyManifest(path string) ([]ManifestRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var bad []ManifestRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r ManifestRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return bad, fmt.Errorf("h2sanlog: parse manifest %s: %w", path, err)
+		}
+		sum, size, err := fileSHA256(filepath.Join(filepath.Dir(path), filepath.FromSlash(r.Name)))
+		if err != nil || sum != r.SHA256 || size != r.Size {
+			bad = append(bad, r)
+		}
+	}
+	return bad, sc.Err()
+}
@@ -0,0 +1,68 @@
+package h2sanlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestVerify(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewFileWriter(filepath.Join(dir, "app"), 10, 0, WithManifest(""), WithFilePattern("app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n"} {
+		w.Write([]byte(s))
+		w.Flush(time.Second)
+	}
+	files := rotatedFiles(t, w)
+	// Close 等待后台计算的校验和写入清单
+	w.Close()
+
+	path := filepath.Join(dir, "app.manifest")
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []ManifestRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r ManifestRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, r)
+	}
+	f.Close()
+	if len(recs) != 2 || len(files) != 2 {
+		t.Fatalf("records %+v, files %v", recs, files)
+	}
+	names := map[string]bool{}
+	for _, r := range recs {
+		names[r.Name] = true
+		if r.Size != 9 || len(r.SHA256) != 64 || r.Start.IsZero() {
+			t.Fatalf("record %+v", r)
+		}
+	}
+	if !names["app.log.full.1.log"] || !names["app.log.full.2.log"] {
+		t.Fatalf("names = %v", names)
+	}
+	if bad, err := VerifyManifest(path); err != nil || len(bad) != 0 {
+		t.Fatalf("intact files: bad %v, err %v", bad, err)
+	}
+
+	// 改写一个文件、删除另一个，两条记录都不通过
+	if err := ioutil.WriteFile(files[0], []byte("aaaaaaaX\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(files[1])
+	bad, err := VerifyManifest(path)
+	if err != nil || len(bad) != 2 {
+		t.Fatalf("tampered files: bad %v, err %v", bad, err)
+	}
+}
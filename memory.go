@@ -0,0 +1,43 @@
+package h2sanlog
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrMemoryLimit 日志占用内存超过预算，日志被丢弃
+var ErrMemoryLimit = errors.New("memory limit exceeded, drop")
+
+// 包内所有队列/缓冲的内存记账，memLimit 为 0 表示不限制
+var (
+	memLimit   int64
+	memUsed    int64
+	memDropped int64
+)
+
+// SetMemoryLimit 设置包内所有日志队列和缓冲可占用的总字节数，超过后新日志直接丢弃，n<=0 不限制
+func SetMemoryLimit(n int64) {
+	atomic.StoreInt64(&memLimit, n)
+}
+
+// MemoryUsage 返回当前队列/缓冲中占用的字节数，以及因超过预算被丢弃的日志条数
+func MemoryUsage() (used int64, dropped int64) {
+	return atomic.LoadInt64(&memUsed), atomic.LoadInt64(&memDropped)
+}
+
+// acquireMem 申请 n 字节预算，超过预算返回false并计入丢弃
+func acquireMem(n int) bool {
+	limit := atomic.LoadInt64(&memLimit)
+	used := atomic.AddInt64(&memUsed, int64(n))
+	if limit > 0 && used > limit {
+		atomic.AddInt64(&memUsed, -int64(n))
+		atomic.AddInt64(&memDropped, 1)
+		return false
+	}
+	return true
+}
+
+// releaseMem 归还 n 字节预算
+func releaseMem(n int) {
+	atomic.AddInt64(&memUsed, -int64(n))
+}
@@ -0,0 +1,102 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// moduleLevels 按 logger 名覆盖的级别，*map[string]uint8，nil 表示没有覆盖
+var moduleLevels atomic.Value
+
+// SetModuleLevels 按 logger 名覆盖最低级别，如 "db=debug,http=warn"，运行中可随时调用，对所有 logger 立即生效。
+// 名字按 Named 的 "." 分段匹配，db 同时作用于 db.pool，最长匹配优先；覆盖优先于 SetLevel 和 Schedule 的级别。
+// spec 为空时清除所有覆盖
+func SetModuleLevels(spec string) error {
+	m := make(map[string]uint8)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.IndexByte(item, '=')
+		if i <= 0 {
+			return fmt.Errorf("h2sanlog: bad module level %q, want name=level", item)
+		}
+		level, err := ParseLevel(item[i+1:])
+		if err != nil {
+			return err
+		}
+		m[strings.TrimSpace(item[:i])] = level
+	}
+	if len(m) == 0 {
+		moduleLevels.Store((*map[string]uint8)(nil))
+		return nil
+	}
+	moduleLevels.Store(&m)
+	return nil
+}
+
+// ModuleLevels 返回当前覆盖，格式同 SetModuleLevels，按名字排序
+func ModuleLevels() string {
+	p, _ := moduleLevels.Load().(*map[string]uint8)
+	if p == nil {
+		return ""
+	}
+	items := make([]string, 0, len(*p))
+	for name, level := range *p {
+		s := "off"
+		if level != LogLevelNull {
+			s = strings.ToLower(levelNames[level])
+		}
+		items = append(items, name+"="+s)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// moduleLevel 返回 name 的覆盖级别
+func moduleLevel(name string) (uint8, bool) {
+	p, _ := moduleLevels.Load().(*map[string]uint8)
+	if p == nil || name == "" {
+		return 0, false
+	}
+	for {
+		if level, ok := (*p)[name]; ok {
+			return level, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return 0, false
+		}
+		name = name[:i]
+	}
+}
+
+// ModuleLevelsHandler 运维接口，GET 返回当前覆盖，PUT/POST 以请求体为 spec 调用 SetModuleLevels，
+// 如 curl -X PUT -d 'db=debug' localhost:8080/debug/log/levels
+func ModuleLevelsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, 64<<10))
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := SetModuleLevels(string(body)); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			rw.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(rw, ModuleLevels())
+	})
+}
@@ -0,0 +1,96 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// resetModuleLevels 测试结束时清除全局的模块级别
+func resetModuleLevels(t *testing.T) {
+	t.Cleanup(func() { SetModuleLevels("") })
+}
+
+func TestModuleLevelsOverride(t *testing.T) {
+	resetModuleLevels(t)
+	var buf bytes.Buffer
+	root := New(&buf, "", 0)
+	root.SetLevel(LogLevelWarning)
+	db, pool, web, other := root.Named("db"), root.Named("db").Named("pool"), root.Named("http"), root.Named("dbx")
+	if err := SetModuleLevels(" db=debug, db.pool=error ,http=off"); err != nil {
+		t.Fatal(err)
+	}
+	db.Log(LogLevelDebug, "db debug")
+	pool.Log(LogLevelWarning, "pool warn")
+	pool.Log(LogLevelError, "pool error")
+	web.Log(LogLevelTrace, "http trace")
+	// dbx 不是 db 的子模块，仍按 SetLevel
+	other.Log(LogLevelInfo, "dbx info")
+	root.Log(LogLevelInfo, "root info")
+	got := buf.String()
+	for _, s := range []string{"db debug", "pool error", "http trace"} {
+		if !strings.Contains(got, s) {
+			t.Fatalf("%q missing from %q", s, got)
+		}
+	}
+	for _, s := range []string{"pool warn", "dbx info", "root info"} {
+		if strings.Contains(got, s) {
+			t.Fatalf("%q written: %q", s, got)
+		}
+	}
+	if got := ModuleLevels(); got != "db.pool=error,db=debug,http=off" {
+		t.Fatalf("ModuleLevels = %q", got)
+	}
+	// 清除后立即按 SetLevel 过滤
+	SetModuleLevels("")
+	buf.Reset()
+	db.Log(LogLevelDebug, "db debug")
+	if buf.Len() != 0 || ModuleLevels() != "" {
+		t.Fatalf("after reset got %q, levels %q", buf.String(), ModuleLevels())
+	}
+}
+
+func TestSetModuleLevelsErrors(t *testing.T) {
+	resetModuleLevels(t)
+	SetModuleLevels("db=info")
+	for _, spec := range []string{"db", "=info", "db=loud"} {
+		if err := SetModuleLevels(spec); err == nil {
+			t.Fatalf("SetModuleLevels(%q) succeeded", spec)
+		}
+	}
+	// 出错时保留原来的设置
+	if got := ModuleLevels(); got != "db=info" {
+		t.Fatalf("ModuleLevels = %q", got)
+	}
+}
+
+func TestModuleLevelsHandler(t *testing.T) {
+	resetModuleLevels(t)
+	srv := httptest.NewServer(ModuleLevelsHandler())
+	defer srv.Close()
+	do := func(method, body string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(b))
+	}
+	if code, body := do(http.MethodPut, "http=warn,db=debug"); code != 200 || body != "db=debug,http=warning" {
+		t.Fatalf("PUT = %d %q", code, body)
+	}
+	if code, body := do(http.MethodGet, ""); code != 200 || body != "db=debug,http=warning" {
+		t.Fatalf("GET = %d %q", code, body)
+	}
+	if code, _ := do(http.MethodPost, "bad"); code != http.StatusBadRequest {
+		t.Fatalf("bad spec = %d", code)
+	}
+	if code, _ := do(http.MethodDelete, ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE = %d", code)
+	}
+}
@@ -0,0 +1,150 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type stringer struct{}
+
+func (stringer) String() string { return "stringer" }
+
+func decodeOne(t *testing.T, b []byte) map[string]interface{} {
+	t.Helper()
+	d := NewMsgpackDecoder(bytes.NewReader(b))
+	m, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Fatalf("trailing data: %v", err)
+	}
+	return m
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	str16, str32 := strings.Repeat("s", 300), strings.Repeat("S", 70000)
+	bin16, bin32 := bytes.Repeat([]byte{1}, 300), bytes.Repeat([]byte{2}, 70000)
+	cases := []struct {
+		v, want interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{false, false},
+		{int(5), int64(5)},
+		{int8(-1), int64(-1)},
+		{int16(-33), int64(-33)},
+		{int32(-129), int64(-129)},
+		{int64(-32769), int64(-32769)},
+		{int64(math.MinInt32 - 1), int64(math.MinInt32 - 1)},
+		{int64(math.MinInt64), int64(math.MinInt64)},
+		{uint8(200), uint64(200)},
+		{uint16(65535), uint64(65535)},
+		{uint32(math.MaxUint32), uint64(math.MaxUint32)},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{uint(127), int64(127)},
+		{float32(1.5), float64(1.5)},
+		{-2.25, -2.25},
+		{"short", "short"},
+		{strings.Repeat("x", 40), strings.Repeat("x", 40)},
+		{str16, str16},
+		{str32, str32},
+		{[]byte("bin"), []byte("bin")},
+		{bin16, bin16},
+		{bin32, bin32},
+		{errors.New("boom"), "boom"},
+		{stringer{}, "stringer"},
+		{[]interface{}{int64(1), "a", []interface{}{true}}, []interface{}{int64(1), "a", []interface{}{true}}},
+		{map[string]interface{}{"k": map[string]interface{}{"n": nil}}, map[string]interface{}{"k": map[string]interface{}{"n": nil}}},
+		{point{1, -2}, map[string]interface{}{"x": float64(1), "y": float64(-2)}},
+	}
+	e := &Entry{Time: now, Level: LogLevelError, Name: "svc", Caller: "a.go:1", Message: "hello", Stack: []byte("stack")}
+	for i, c := range cases {
+		e.Fields = append(e.Fields, Field{Key: string(rune('a' + i)), Value: c.v})
+	}
+	b, err := MsgpackEncoder{}.Encode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := decodeOne(t, b)
+	for k, want := range map[string]interface{}{"level": "ERROR", "logger": "svc", "caller": "a.go:1", "msg": "hello", "stack": "stack"} {
+		if m[k] != want {
+			t.Fatalf("%s = %v, want %v", k, m[k], want)
+		}
+	}
+	if ts, ok := m["time"].(time.Time); !ok || !ts.Equal(now) {
+		t.Fatalf("time = %v, want %v", m["time"], now)
+	}
+	for i, c := range cases {
+		key := string(rune('a' + i))
+		if got := m[key]; !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("field %d (%T): got %v (%T), want %v (%T)", i, c.v, got, got, c.want, c.want)
+		}
+	}
+}
+
+func TestMsgpackDecoderStream(t *testing.T) {
+	var buf bytes.Buffer
+	for _, msg := range []string{"one", "two"} {
+		b, _ := MsgpackEncoder{}.Encode(&Entry{Time: time.Unix(1, 0), Level: LogLevelInfo, Message: msg})
+		buf.Write(b)
+	}
+	d := NewMsgpackDecoder(&buf)
+	for _, want := range []string{"one", "two"} {
+		m, err := d.Next()
+		if err != nil || m["msg"] != want {
+			t.Fatalf("got %v, %v", m, err)
+		}
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Fatalf("err = %v, want EOF", err)
+	}
+}
+
+func TestMsgpackTimestampFormats(t *testing.T) {
+	// fixext4 秒数、fixext8 30 位纳秒 + 34 位秒
+	m := decodeOne(t, []byte{0x82, 0xa1, 'a', 0xd6, 0xff, 0, 0, 0, 10,
+		0xa1, 'b', 0xd7, 0xff, 0, 0, 0, 4 << 2, 0, 0, 0, 20})
+	if ts := m["a"].(time.Time); !ts.Equal(time.Unix(10, 0)) {
+		t.Fatalf("ts32 = %v", ts)
+	}
+	if ts := m["b"].(time.Time); !ts.Equal(time.Unix(20, 4)) {
+		t.Fatalf("ts64 = %v", ts)
+	}
+}
+
+func TestMsgpackDecoderBadInput(t *testing.T) {
+	good, _ := MsgpackEncoder{}.Encode(&Entry{Time: time.Unix(1, 0), Level: LogLevelInfo, Message: "hello", Fields: []Field{{Key: "n", Value: 1000}}})
+	// 在每个位置截断都必须返回错误，不能 panic 或返回残缺的记录
+	for i := 1; i < len(good)-1; i++ {
+		if m, err := NewMsgpackDecoder(bytes.NewReader(good[:i])).Next(); err == nil {
+			t.Fatalf("truncated at %d: got %v", i, m)
+		} else if err != io.ErrUnexpectedEOF {
+			t.Fatalf("truncated at %d: err = %v", i, err)
+		}
+	}
+	for name, b := range map[string][]byte{
+		"not a map":     {0xa1, 'x'},
+		"reserved byte": {0x81, 0xa1, 'k', 0xc1},
+		"bad timestamp": {0x81, 0xa1, 'k', 0xc7, 3, 0xff, 0, 0, 0},
+		"huge str32":    {0x81, 0xa1, 'k', 0xdb, 0xff, 0xff, 0xff, 0xff, 'x'},
+		"huge array32":  {0x81, 0xa1, 'k', 0xdd, 0xff, 0xff, 0xff, 0xff},
+		"huge map32":    {0xdf, 0xff, 0xff, 0xff, 0xff},
+	} {
+		if m, err := NewMsgpackDecoder(bytes.NewReader(b)).Next(); err == nil {
+			t.Fatalf("%s: got %v", name, m)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"io"
+)
+
+// MultiWriter 把一条日志分发到多个 sink，每个 sink 可以绑定自己的 Encoder，
+// 如控制台文本、文件 JSON，避免为不同格式启动多个 logger
+type MultiWriter struct {
+	sinks []encodedSink
+}
+
+type encodedSink struct {
+	w   io.Writer
+	enc Encoder
+	// ew 未指定 Encoder 且 w 实现 EntryWriter 时由 w 自行编码
+	ew EntryWriter
+}
+
+// errEncoder 总是返回 err，用于不能写入的 sink
+type errEncoder struct{ err error }
+
+func (e errEncoder) Encode(*Entry) ([]byte, error) { return nil, e.err }
+
+// NewMultiWriter 新建 MultiWriter
+func NewMultiWriter() *MultiWriter {
+	return &MultiWriter{}
+}
+
+// Add 添加 sink，enc 为 nil 时 w 实现 EntryWriter 则直接交给它，否则使用 TextEncoder{}；需在开始写日志前完成添加。
+// enc 为 MsgpackEncoder 而 w 开启了 WithWriteTime 时该 sink 不写入，WriteEntry 返回 ErrWriteTime
+func (m *MultiWriter) Add(w io.Writer, enc Encoder) *MultiWriter {
+	s := encodedSink{w: w, enc: enc}
+	if enc == nil {
+		s.enc = TextEncoder{}
+		s.ew, _ = w.(EntryWriter)
+	}
+	if _, ok := enc.(MsgpackEncoder); ok && checkWriteTime(w) != nil {
+		s.enc = errEncoder{ErrWriteTime}
+	}
+	m.sinks = append(m.sinks, s)
+	return m
+}
+
+// Write 把已编码的内容原样写入所有 sink，返回第一个错误
+func (m *MultiWriter) Write(p []byte) (int, error) {
+	return m.write(p, plainWrite)
+}
+
+// WriteSync 实现 SyncWriter，同步写入所有 sink，返回第一个错误
+func (m *MultiWriter) WriteSync(p []byte) (int, error) {
+	return m.write(p, writeSync)
+}
+
+func (m *MultiWriter) write(p []byte, write func(io.Writer, []byte) (int, error)) (int, error) {
+	var err error
+	for _, s := range m.sinks {
+		if _, e := write(s.w, p); e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(p), err
+}
+
+// WriteString 把字符串写入所有 sink，sink 实现 io.StringWriter 时不做 []byte 转换
+func (m *MultiWriter) WriteString(s string) (int, error) {
+	var err error
+	for _, sk := range m.sinks {
+		if _, e := io.WriteString(sk.w, s); e != nil && err == nil {
+			err = e
+		}
+	}
+	return len(s), err
+}
+
+// ReadFrom 读取 r 的全部内容后写入所有 sink
+func (m *MultiWriter) ReadFrom(r io.Reader) (int64, error) {
+	var b bytes.Buffer
+	n, err := b.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+	_, err = m.Write(b.Bytes())
+	return n, err
+}
+
+// WriteEntry 按每个 sink 绑定的 Encoder 编码后写入，encode 或写入失败不影响其他 sink，返回第一个错误
+func (m *MultiWriter) WriteEntry(e *Entry) error {
+	return m.writeEntry(e, false)
+}
+
+// WriteEntrySync 同步写入结构化日志，见 WriteEntry 和 SyncWriter
+func (m *MultiWriter) WriteEntrySync(e *Entry) error {
+	return m.writeEntry(e, true)
+}
+
+func (m *MultiWriter) writeEntry(e *Entry, sync bool) error {
+	var err error
+	for _, s := range m.sinks {
+		if s.ew != nil {
+			var e2 error
+			if !sync {
+				e2 = s.ew.WriteEntry(e)
+			} else if sw, ok := s.ew.(interface{ WriteEntrySync(e *Entry) error }); ok {
+				e2 = sw.WriteEntrySync(e)
+			} else {
+				e2 = ErrSyncUnsupported
+			}
+			if e2 != nil && err == nil {
+				err = e2
+			}
+			continue
+		}
+		data, e2 := s.enc.Encode(e)
+		if e2 == nil && sync {
+			_, e2 = writeSync(s.w, data)
+		} else if e2 == nil {
+			_, e2 = s.w.Write(data)
+		}
+		if e2 != nil && err == nil {
+			err = e2
+		}
+	}
+	return err
+}
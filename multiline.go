@@ -0,0 +1,37 @@
+package h2sanlog
+
+import "strings"
+
+// Multiline 消息和堆栈中换行的处理方式，避免一条日志被按行采集的 shipper 拆成多条
+type Multiline uint8
+
+const (
+	// MultilineKeep 原样输出
+	MultilineKeep Multiline = iota
+	// MultilineEscape 换行转义为 \n，每条日志严格一行
+	MultilineEscape
+	// MultilineIndent 第二行起以 tab 开头，作为续行标记供 shipper 合并
+	MultilineIndent
+)
+
+var (
+	escapeNewline = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+	indentNewline = strings.NewReplacer("\r\n", "\n\t", "\n", "\n\t")
+)
+
+// apply 处理一条已格式化的文本日志，末尾换行由调用方追加
+func (m Multiline) apply(s string) string {
+	if m == MultilineKeep || !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	s = strings.TrimRight(s, "\r\n")
+	if m == MultilineEscape {
+		return escapeNewline.Replace(s)
+	}
+	return indentNewline.Replace(s)
+}
+
+// SetMultiline 设置文本输出中换行的处理方式，默认 MultilineKeep；JSON 等编码器本身会转义换行，不受影响
+func (l *Logger) SetMultiline(m Multiline) {
+	l.multiline = m
+}
@@ -0,0 +1,64 @@
+package h2sanlog
+
+import "sync"
+
+// observerBufferSize 每个观察者的缓冲条数，缓冲满时丢弃，不阻塞写日志
+const observerBufferSize = 256
+
+// observerSet 同一个logger及其子logger共享的观察者列表
+type observerSet struct {
+	mu   sync.RWMutex
+	list []*observer
+}
+
+type observer struct {
+	ch   chan Entry
+	once sync.Once
+}
+
+// AttachObserver 注册只读观察者，进程内接收当前logger及其子logger写入的每条日志副本，
+// 观察者处理慢时丢弃，返回的函数用于取消注册
+func (l *Logger) AttachObserver(fn func(Entry)) (detach func()) {
+	if l.observers == nil {
+		l.observers = &observerSet{}
+	}
+	set := l.observers
+	o := &observer{ch: make(chan Entry, observerBufferSize)}
+	go func() {
+		for e := range o.ch {
+			fn(e)
+		}
+	}()
+	set.mu.Lock()
+	set.list = append(set.list, o)
+	set.mu.Unlock()
+	return func() {
+		o.once.Do(func() {
+			set.mu.Lock()
+			for i, x := range set.list {
+				if x == o {
+					set.list = append(set.list[:i:i], set.list[i+1:]...)
+					break
+				}
+			}
+			set.mu.Unlock()
+			close(o.ch)
+		})
+	}
+}
+
+// notify 把 entry 副本投递给所有观察者
+func (s *observerSet) notify(e *Entry) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.list) == 0 {
+		return
+	}
+	for _, o := range s.list {
+		// 每个观察者一份独立副本，互相修改不影响
+		select {
+		case o.ch <- e.Clone():
+		default:
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// oteladapter 从 OpenTelemetry 的 context 中提取当前 span 的 trace_id/span_id 附带到 h2sanlog 日志，
+// 用于在 Tempo、Jaeger 等后端关联日志和链路
+package oteladapter
+
+import (
+	"context"
+
+	"github.com/h2san/h2sanlog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fields 返回 ctx 中有效 span 的 trace_id、span_id，没有 span 时返回 nil
+func Fields(ctx context.Context) []h2sanlog.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []h2sanlog.Field{
+		h2sanlog.String(h2sanlog.TraceIDKey, sc.TraceID().String()),
+		h2sanlog.String(h2sanlog.SpanIDKey, sc.SpanID().String()),
+	}
+}
+
+// Register 把 Fields 注册为全局的 context 字段提取器，之后 Logger.WithContext 和 LogContext 自动附带 trace_id/span_id；
+// 只需在初始化时调用一次
+func Register() {
+	h2sanlog.RegisterContextExtractor(Fields)
+}
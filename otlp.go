@@ -0,0 +1,450 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrOTLPQueueFull 待发送的日志超过 OTLPConfig.QueueSize，日志被丢弃
+var ErrOTLPQueueFull = errors.New("h2sanlog: otlp queue full, drop")
+
+// OTLPConfig OpenTelemetry 日志导出配置，使用 OTLP/HTTP JSON 协议发送到 collector
+type OTLPConfig struct {
+	// Endpoint collector 地址，如 http://localhost:4318；没有路径时追加 /v1/logs
+	Endpoint string
+	// Headers 每个请求附带的头，如鉴权 token
+	Headers map[string]string
+	// ServiceName 资源属性 service.name
+	ServiceName string
+	// Resource 其他资源属性，如 deployment.environment
+	Resource map[string]string
+	// BatchSize 每个请求最多携带的日志条数，默认 512
+	BatchSize int
+	// FlushInterval 未攒满一批时的发送间隔，默认 1 秒
+	FlushInterval time.Duration
+	// QueueSize 待发送日志的上限，超过后丢弃并返回 ErrOTLPQueueFull，默认 8192
+	QueueSize int
+	// Timeout 单个请求的超时，默认 10 秒
+	Timeout time.Duration
+	// MaxRetries collector 返回 429/502/503/504 或网络错误时的重试次数，默认 2，负数不重试
+	MaxRetries int
+	// Client 发送请求的 http.Client，默认 http.DefaultClient
+	Client *http.Client
+	// OnError 发送失败回调，默认输出到 stderr
+	OnError func(error)
+}
+
+// OTLPExporter 把日志转换为 OTLP log record 批量发送到 collector，实现 EntryWriter，
+// 可直接作为 Logger 的输出，或通过 MultiWriter 与文件 sink 同时使用
+type OTLPExporter struct {
+	cfg      OTLPConfig
+	url      string
+	resource []otlpKeyValue
+
+	mu      sync.Mutex
+	records []otlpRecord
+
+	kick    chan struct{}
+	flushes chan chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewOTLPExporter 新建 OTLP 导出器并启动发送goroutine
+func NewOTLPExporter(cfg OTLPConfig) (*OTLPExporter, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("h2sanlog: otlp endpoint %q must be http or https", cfg.Endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/logs"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 8192
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 2
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = stderrError
+	}
+	x := &OTLPExporter{cfg: cfg, url: u.String(), kick: make(chan struct{}, 1),
+		flushes: make(chan chan struct{}), done: make(chan struct{}), stopped: make(chan struct{})}
+	if cfg.ServiceName != "" {
+		x.resource = append(x.resource, otlpString("service.name", cfg.ServiceName))
+	}
+	for k, v := range cfg.Resource {
+		x.resource = append(x.resource, otlpString(k, v))
+	}
+	go x.run()
+	return x, nil
+}
+
+// WriteEntry 实现 EntryWriter，entry 转换为 log record 后入队，不等待发送
+func (x *OTLPExporter) WriteEntry(e *Entry) error {
+	r := otlpRecord{
+		TimeUnixNano:   strconv.FormatInt(e.Time.UnixNano(), 10),
+		SeverityNumber: otlpSeverity(e.Level),
+		SeverityText:   levelNames[e.Level],
+		Body:           otlpAny(e.Message),
+		scope:          e.Name,
+	}
+	for _, f := range e.Fields {
+		v := f.Interface()
+		if id, ok := v.(string); ok && (f.Key == TraceIDKey || f.Key == SpanIDKey) {
+			if f.Key == TraceIDKey {
+				r.TraceID = id
+			} else {
+				r.SpanID = id
+			}
+			continue
+		}
+		r.Attributes = append(r.Attributes, otlpKeyValue{Key: f.Key, Value: otlpAny(v)})
+	}
+	if e.Caller != "" {
+		r.Attributes = append(r.Attributes, otlpString("code.caller", e.Caller))
+	}
+	if len(e.Stack) > 0 {
+		r.Attributes = append(r.Attributes, otlpString("exception.stacktrace", string(e.Stack)))
+	}
+	return x.add(r)
+}
+
+// Write 已编码的一行日志整体作为 body 发送，级别按行首的 [LEVEL] 标签识别，缺省为 INFO
+func (x *OTLPExporter) Write(p []byte) (int, error) {
+	level := sniffLevel(p)
+	if level == LogLevelNull {
+		level = LogLevelInfo
+	}
+	r := otlpRecord{
+		TimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber: otlpSeverity(level),
+		SeverityText:   levelNames[level],
+		Body:           otlpAny(string(bytes.TrimRight(p, "\n"))),
+	}
+	if err := x.add(r); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (x *OTLPExporter) add(r otlpRecord) error {
+	select {
+	case <-x.done:
+		return ErrClosed
+	default:
+	}
+	x.mu.Lock()
+	if len(x.records) >= x.cfg.QueueSize {
+		x.mu.Unlock()
+		return ErrOTLPQueueFull
+	}
+	x.records = append(x.records, r)
+	full := len(x.records) >= x.cfg.BatchSize
+	x.mu.Unlock()
+	if full {
+		select {
+		case x.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush 发送所有已入队的日志，超过 timeout 返回 ErrFlushTimeout
+func (x *OTLPExporter) Flush(timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	req := make(chan struct{})
+	select {
+	case x.flushes <- req:
+	case <-x.stopped:
+		return ErrClosed
+	case <-t.C:
+		return ErrFlushTimeout
+	}
+	select {
+	case <-req:
+		return nil
+	case <-t.C:
+		return ErrFlushTimeout
+	}
+}
+
+// Sync 同 Flush，等待时间为 SetExitTimeout 的设置
+func (x *OTLPExporter) Sync() error {
+	return x.Flush(exitTimeout)
+}
+
+// Close 发送剩余日志后停止，最多等待 SetExitTimeout 的设置
+func (x *OTLPExporter) Close() error {
+	x.once.Do(func() { close(x.done) })
+	t := time.NewTimer(exitTimeout)
+	defer t.Stop()
+	select {
+	case <-x.stopped:
+		return nil
+	case <-t.C:
+		return ErrFlushTimeout
+	}
+}
+
+func (x *OTLPExporter) run() {
+	defer close(x.stopped)
+	ticker := time.NewTicker(x.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			x.sendAll()
+		case <-x.kick:
+			x.sendAll()
+		case req := <-x.flushes:
+			x.sendAll()
+			close(req)
+		case <-x.done:
+			x.sendAll()
+			return
+		}
+	}
+}
+
+// sendAll 取走所有待发送日志，按 BatchSize 分批发送
+func (x *OTLPExporter) sendAll() {
+	x.mu.Lock()
+	records := x.records
+	x.records = nil
+	x.mu.Unlock()
+	for len(records) > 0 {
+		n := len(records)
+		if n > x.cfg.BatchSize {
+			n = x.cfg.BatchSize
+		}
+		if err := x.post(records[:n]); err != nil {
+			x.cfg.OnError(fmt.Errorf("otlp export %d records to %s fail:%w", n, x.url, err))
+		}
+		records = records[n:]
+	}
+}
+
+// post 发送一批日志，可重试的失败按 1s、2s... 退避重试
+func (x *OTLPExporter) post(records []otlpRecord) error {
+	body, err := json.Marshal(x.payload(records))
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := x.do(body)
+		if err == nil || !retry || attempt >= x.cfg.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		case <-x.done:
+			// 关闭时不再退避重试
+			return err
+		}
+	}
+}
+
+func (x *OTLPExporter) do(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), x.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, x.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range x.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := x.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return false, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+}
+
+// payload 按 logger 名字分组为 scopeLogs
+func (x *OTLPExporter) payload(records []otlpRecord) otlpRequest {
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+	var scopes []otlpScopeLogs
+	index := make(map[string]int)
+	for _, r := range records {
+		r.ObservedTimeUnixNano = observed
+		i, ok := index[r.scope]
+		if !ok {
+			i = len(scopes)
+			index[r.scope] = i
+			scopes = append(scopes, otlpScopeLogs{Scope: otlpScope{Name: r.scope}})
+		}
+		scopes[i].LogRecords = append(scopes[i].LogRecords, r)
+	}
+	return otlpRequest{ResourceLogs: []otlpResourceLogs{{Resource: otlpResource{Attributes: x.resource}, ScopeLogs: scopes}}}
+}
+
+// otlpSeverity 日志级别对应的 OTel SeverityNumber
+func otlpSeverity(level uint8) int {
+	switch level {
+	case LogLevelTrace:
+		return 1
+	case LogLevelDebug:
+		return 5
+	case LogLevelInfo:
+		return 9
+	case LogLevelWarning:
+		return 13
+	case LogLevelError:
+		return 17
+	case LogLevelFatal:
+		return 21
+	}
+	return 0
+}
+
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope    `json:"scope"`
+	LogRecords []otlpRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name,omitempty"`
+}
+
+type otlpRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 otlpValue      `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+	// scope 所属 logger 名字，发送时用于分组
+	scope string
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue OTLP AnyValue，只设置其中一个字段
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BytesValue  []byte   `json:"bytesValue,omitempty"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAny(value)}
+}
+
+// otlpAny 把字段值转换为 AnyValue，int64 按协议编码为字符串
+func otlpAny(v interface{}) otlpValue {
+	str := func(s string) otlpValue { return otlpValue{StringValue: &s} }
+	integer := func(s string) otlpValue { return otlpValue{IntValue: &s} }
+	switch t := v.(type) {
+	case string:
+		return str(t)
+	case bool:
+		return otlpValue{BoolValue: &t}
+	case int:
+		return integer(strconv.FormatInt(int64(t), 10))
+	case int8:
+		return integer(strconv.FormatInt(int64(t), 10))
+	case int16:
+		return integer(strconv.FormatInt(int64(t), 10))
+	case int32:
+		return integer(strconv.FormatInt(int64(t), 10))
+	case int64:
+		return integer(strconv.FormatInt(t, 10))
+	case uint:
+		return otlpAny(uint64(t))
+	case uint8:
+		return integer(strconv.FormatUint(uint64(t), 10))
+	case uint16:
+		return integer(strconv.FormatUint(uint64(t), 10))
+	case uint32:
+		return integer(strconv.FormatUint(uint64(t), 10))
+	case uint64:
+		if t > math.MaxInt64 {
+			// intValue 为 int64，放不下时按字符串发送
+			return str(strconv.FormatUint(t, 10))
+		}
+		return integer(strconv.FormatUint(t, 10))
+	case float32:
+		return otlpAny(float64(t))
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return str(strconv.FormatFloat(t, 'g', -1, 64))
+		}
+		return otlpValue{DoubleValue: &t}
+	case []byte:
+		return otlpValue{BytesValue: append([]byte(nil), t...)}
+	case time.Time:
+		return str(t.Format(time.RFC3339Nano))
+	case time.Duration:
+		return str(t.String())
+	case error:
+		return str(t.Error())
+	case fmt.Stringer:
+		return str(t.String())
+	case nil:
+		return otlpValue{}
+	}
+	return str(fmt.Sprint(v))
+}
@@ -0,0 +1,215 @@
+package h2sanlog
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// otlpCollector 记录收到的请求，依次返回 status 中的状态码，用完后返回 200
+type otlpCollector struct {
+	mu      sync.Mutex
+	status  []int
+	paths   []string
+	headers []http.Header
+	bodies  []otlpRequest
+}
+
+func newOTLPCollector(t *testing.T, status ...int) (*otlpCollector, *httptest.Server) {
+	c := &otlpCollector{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var req otlpRequest
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Errorf("bad body %q: %v", b, err)
+		}
+		c.mu.Lock()
+		c.paths = append(c.paths, r.URL.Path)
+		c.headers = append(c.headers, r.Header)
+		c.bodies = append(c.bodies, req)
+		code := http.StatusOK
+		if len(c.status) > 0 {
+			code, c.status = c.status[0], c.status[1:]
+		}
+		c.mu.Unlock()
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func (c *otlpCollector) requests() []otlpRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]otlpRequest(nil), c.bodies...)
+}
+
+func newTestExporter(t *testing.T, cfg OTLPConfig) *OTLPExporter {
+	t.Helper()
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = time.Hour
+	}
+	x, err := NewOTLPExporter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { x.Close() })
+	return x
+}
+
+func attr(kvs []otlpKeyValue, key string) (otlpValue, bool) {
+	for _, kv := range kvs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return otlpValue{}, false
+}
+
+func TestOTLPExportEntry(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	x := newTestExporter(t, OTLPConfig{Endpoint: srv.URL, ServiceName: "svc",
+		Resource: map[string]string{"deployment.environment": "test"}, Headers: map[string]string{"Authorization": "Bearer x"}})
+	now := time.Unix(1700000000, 5)
+	x.WriteEntry(&Entry{Time: now, Level: LogLevelWarning, Name: "db", Message: "slow", Caller: "a.go:1 f",
+		Fields: []Field{String(TraceIDKey, "0af7651916cd43dd8448eb211c80319c"), String(SpanIDKey, "b7ad6b7169203331"),
+			Int("ms", 250), Bool("ok", false)}})
+	x.WriteEntry(&Entry{Time: now, Level: LogLevelInfo, Name: "http", Message: "get"})
+	x.WriteEntry(&Entry{Time: now, Level: LogLevelError, Name: "db", Message: "down"})
+	if err := x.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	reqs := c.requests()
+	if len(reqs) != 1 || c.paths[0] != "/v1/logs" || c.headers[0].Get("Authorization") != "Bearer x" {
+		t.Fatalf("requests = %d, path %v, headers %v", len(reqs), c.paths, c.headers)
+	}
+	rl := reqs[0].ResourceLogs[0]
+	if v, _ := attr(rl.Resource.Attributes, "service.name"); v.StringValue == nil || *v.StringValue != "svc" {
+		t.Fatalf("service.name = %+v", v)
+	}
+	if v, _ := attr(rl.Resource.Attributes, "deployment.environment"); v.StringValue == nil || *v.StringValue != "test" {
+		t.Fatalf("deployment.environment = %+v", v)
+	}
+	// 按 logger 名字分组，组内保持写入顺序
+	if len(rl.ScopeLogs) != 2 || rl.ScopeLogs[0].Scope.Name != "db" || rl.ScopeLogs[1].Scope.Name != "http" {
+		t.Fatalf("scopes = %+v", rl.ScopeLogs)
+	}
+	db := rl.ScopeLogs[0].LogRecords
+	if len(db) != 2 || *db[0].Body.StringValue != "slow" || *db[1].Body.StringValue != "down" {
+		t.Fatalf("db records = %+v", db)
+	}
+	r := db[0]
+	if r.TimeUnixNano != "1700000000000000005" || r.SeverityNumber != 13 || r.SeverityText != levelNames[LogLevelWarning] {
+		t.Fatalf("record = %+v", r)
+	}
+	if r.TraceID != "0af7651916cd43dd8448eb211c80319c" || r.SpanID != "b7ad6b7169203331" {
+		t.Fatalf("trace = %q span = %q", r.TraceID, r.SpanID)
+	}
+	if _, ok := attr(r.Attributes, TraceIDKey); ok {
+		t.Fatal("trace_id also sent as attribute")
+	}
+	if v, _ := attr(r.Attributes, "ms"); v.IntValue == nil || *v.IntValue != "250" {
+		t.Fatalf("ms = %+v", v)
+	}
+	if v, _ := attr(r.Attributes, "ok"); v.BoolValue == nil || *v.BoolValue {
+		t.Fatalf("ok = %+v", v)
+	}
+	if v, _ := attr(r.Attributes, "code.caller"); v.StringValue == nil || *v.StringValue != "a.go:1 f" {
+		t.Fatalf("code.caller = %+v", v)
+	}
+}
+
+func TestOTLPWriteLine(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	x := newTestExporter(t, OTLPConfig{Endpoint: srv.URL + "/custom"})
+	x.Write([]byte("[ERROR] boom\n"))
+	x.Write([]byte("plain\n"))
+	x.Flush(time.Second)
+	reqs := c.requests()
+	if len(reqs) != 1 || c.paths[0] != "/custom" {
+		t.Fatalf("requests = %d, paths %v", len(reqs), c.paths)
+	}
+	recs := reqs[0].ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(recs) != 2 || recs[0].SeverityNumber != 17 || *recs[0].Body.StringValue != "[ERROR] boom" || recs[1].SeverityNumber != 9 {
+		t.Fatalf("records = %+v", recs)
+	}
+}
+
+func TestOTLPBatchSize(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	x := newTestExporter(t, OTLPConfig{Endpoint: srv.URL, BatchSize: 2})
+	for i := 0; i < 5; i++ {
+		x.Write([]byte("line\n"))
+	}
+	x.Flush(time.Second)
+	var sizes []int
+	for _, r := range c.requests() {
+		sizes = append(sizes, len(r.ResourceLogs[0].ScopeLogs[0].LogRecords))
+	}
+	// 攒满一批立即发送，不等 FlushInterval
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("batch sizes = %v", sizes)
+	}
+}
+
+func TestOTLPRetry(t *testing.T) {
+	c, srv := newOTLPCollector(t, http.StatusServiceUnavailable, http.StatusBadRequest)
+	var mu sync.Mutex
+	var errs []error
+	x := newTestExporter(t, OTLPConfig{Endpoint: srv.URL, MaxRetries: 1, OnError: func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}})
+	// 503 重试后成功
+	x.Write([]byte("a\n"))
+	if err := x.Flush(3 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.requests()); n != 2 {
+		t.Fatalf("requests after 503 = %d, want 2", n)
+	}
+	// 400 不重试
+	x.Write([]byte("b\n"))
+	x.Flush(time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	if n := len(c.requests()); n != 3 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "status 400") {
+		t.Fatalf("requests = %d, errs = %v", n, errs)
+	}
+}
+
+func TestOTLPQueueFullAndClose(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	x := newTestExporter(t, OTLPConfig{Endpoint: srv.URL, QueueSize: 2})
+	x.Write([]byte("a\n"))
+	x.Write([]byte("b\n"))
+	if _, err := x.Write([]byte("c\n")); !errors.Is(err, ErrOTLPQueueFull) {
+		t.Fatalf("err = %v, want ErrOTLPQueueFull", err)
+	}
+	// Close 发送剩余日志
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := c.requests(); len(reqs) != 1 || len(reqs[0].ResourceLogs[0].ScopeLogs[0].LogRecords) != 2 {
+		t.Fatalf("requests = %+v", reqs)
+	}
+	if _, err := x.Write([]byte("d\n")); err != ErrClosed {
+		t.Fatalf("write after close: %v", err)
+	}
+	if err := x.Flush(time.Second); err != ErrClosed {
+		t.Fatalf("flush after close: %v", err)
+	}
+}
+
+func TestOTLPEndpoint(t *testing.T) {
+	if _, err := NewOTLPExporter(OTLPConfig{Endpoint: "grpc://collector:4317"}); err == nil {
+		t.Fatal("want error for non-http endpoint")
+	}
+}
@@ -0,0 +1,65 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WithOwner 新建和轮转出的日志文件（按天分目录时包括日期目录）chown 为 uid:gid，
+// 需要 root 或 CAP_CHOWN，用于日志采集进程以其他用户运行的场景；-1 表示不修改
+func WithOwner(uid, gid int) FileOption {
+	return func(w *FileWriter) {
+		w.uid, w.gid = uid, gid
+		w.setOwner = true
+	}
+}
+
+// WithPreserveOwner 轮转时新文件沿用当前日志文件的属主，运维手动 chown 过的日志轮转后不会丢失权限
+func WithPreserveOwner() FileOption {
+	return func(w *FileWriter) {
+		w.preserveOwner = true
+	}
+}
+
+// openFile 打开日志文件并按配置设置属主，调用方需持有锁（创建时除外）
+func (w *FileWriter) openFile(path string) (*os.File, error) {
+	uid, gid, ok := w.uid, w.gid, w.setOwner
+	if !ok && w.preserveOwner {
+		w.captureOwner()
+		uid, gid, ok = w.ownerUID, w.ownerGID, w.hasOwner
+	}
+	file, err := openLogFile(path)
+	if err != nil {
+		return file, err
+	}
+	if !ok {
+		if w.preserveOwner {
+			if fi, e := file.Stat(); e == nil {
+				w.ownerUID, w.ownerGID, w.hasOwner = fileOwner(fi)
+			}
+		}
+		return file, nil
+	}
+	dir := filepath.Dir(path)
+	for i := 0; i < w.layout.depth(); i++ {
+		if e := os.Lchown(dir, uid, gid); e != nil {
+			w.onError(fmt.Errorf("chown dir:%s fail:%w", dir, e))
+		}
+		dir = filepath.Dir(dir)
+	}
+	if e := file.Chown(uid, gid); e != nil {
+		w.onError(fmt.Errorf("chown file path:%s fail:%w", path, e))
+	}
+	return file, nil
+}
+
+// captureOwner 记录当前日志文件的属主，轮转关闭文件前调用，调用方需持有锁
+func (w *FileWriter) captureOwner() {
+	if !w.preserveOwner || w.file == nil {
+		return
+	}
+	if fi, err := w.file.Stat(); err == nil {
+		w.ownerUID, w.ownerGID, w.hasOwner = fileOwner(fi)
+	}
+}
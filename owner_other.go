@@ -0,0 +1,10 @@
+//go:build windows || plan9 || js || wasip1
+
+package h2sanlog
+
+import "os"
+
+// fileOwner 当前平台没有 uid/gid，WithPreserveOwner 不生效
+func fileOwner(fi os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,228 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithFilePattern 用 strftime 风格的模式命名当前日志文件，如 app.%Y%m%d%H.log 按小时切换文件；
+// 支持 %Y %y %m %d %H %M 和 %%，按模式中最细的时间单位切换，最长按天切换。
+// 模式只是文件名，文件所在目录仍由 fileName 和 WithDailyDirs、WithDateDirs 决定，默认模式见 DefaultFilePattern
+func WithFilePattern(pattern string) FileOption {
+	return func(w *FileWriter) {
+		w.patternSpec = pattern
+	}
+}
+
+// DefaultFilePattern 返回 NewFileWriter 默认的日志文件名模式，如 fileName 为 logs/app 时为 app.%Y-%m-%d.log，
+// 可传给 ParseLogName；使用 WithDailyDirs、WithDateDirs 时文件名不带日期，为 app.log
+func DefaultFilePattern(fileName string) string {
+	return defaultPattern(fileName, flatLayout)
+}
+
+func defaultPattern(fileName string, layout dirLayout) string {
+	base := strings.ReplaceAll(filepath.Base(fileName), "%", "%%")
+	if layout != flatLayout {
+		return base + ".log"
+	}
+	return base + ".%Y-%m-%d.log"
+}
+
+// ParseLogName 按 pattern 解析 writer 生成的日志文件名，name 可以是路径，只解析文件名部分；
+// 返回文件所属时段的开始时间（按 loc，nil 为本地时区）和默认轮转规则的序号，当前文件为 0，<active>.full.N.log 为 N。
+// 文件名中的点号按模式逐段匹配，基础文件名本身带点号也能正确解析
+func ParseLogName(pattern, name string, loc *time.Location) (time.Time, int, error) {
+	p, err := compilePattern(pattern)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	base := filepath.Base(name)
+	t, rest, ok := p.parse(base, loc)
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("h2sanlog: %s does not match pattern %s", base, pattern)
+	}
+	if rest == "" {
+		return t, 0, nil
+	}
+	if n, ok := fullIndex(strings.TrimSuffix(base, rest), base); ok {
+		return t, n, nil
+	}
+	return time.Time{}, 0, fmt.Errorf("h2sanlog: %s is not a log file of pattern %s", base, pattern)
+}
+
+// namePattern 编译后的文件名模式
+type namePattern struct {
+	parts []patternPart
+	// unit 模式中最细的时间单位，见 unitRank
+	unit byte
+}
+
+// patternPart 一段字面量，或 verb 不为 0 时为一个时间占位符
+type patternPart struct {
+	lit  string
+	verb byte
+}
+
+// verbWidth 时间占位符的固定位数
+var verbWidth = map[byte]int{'Y': 4, 'y': 2, 'm': 2, 'd': 2, 'H': 2, 'M': 2}
+
+// unitRank 时间单位由粗到细的顺序
+var unitRank = map[byte]int{'Y': 1, 'y': 1, 'm': 2, 'd': 3, 'H': 4, 'M': 5}
+
+func compilePattern(s string) (*namePattern, error) {
+	if s == "" || strings.ContainsAny(s, `/\`) {
+		return nil, fmt.Errorf("h2sanlog: bad file pattern %q: want a file name", s)
+	}
+	p := &namePattern{}
+	var lit strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			lit.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return nil, fmt.Errorf("h2sanlog: bad file pattern %q: trailing %%", s)
+		}
+		i++
+		c := s[i]
+		if c == '%' {
+			lit.WriteByte('%')
+			continue
+		}
+		if _, ok := verbWidth[c]; !ok {
+			return nil, fmt.Errorf("h2sanlog: bad file pattern %q: unknown verb %%%c", s, c)
+		}
+		if lit.Len() > 0 {
+			p.parts = append(p.parts, patternPart{lit: lit.String()})
+			lit.Reset()
+		}
+		p.parts = append(p.parts, patternPart{verb: c})
+		if unitRank[c] > unitRank[p.unit] {
+			p.unit = c
+		}
+	}
+	if lit.Len() > 0 {
+		p.parts = append(p.parts, patternPart{lit: lit.String()})
+	}
+	return p, nil
+}
+
+func (p *namePattern) format(t time.Time) string {
+	b := make([]byte, 0, 32)
+	for _, part := range p.parts {
+		if part.verb == 0 {
+			b = append(b, part.lit...)
+			continue
+		}
+		var v int
+		switch part.verb {
+		case 'Y':
+			v = t.Year()
+		case 'y':
+			v = t.Year() % 100
+		case 'm':
+			v = int(t.Month())
+		case 'd':
+			v = t.Day()
+		case 'H':
+			v = t.Hour()
+		case 'M':
+			v = t.Minute()
+		}
+		s := strconv.Itoa(v)
+		for i := len(s); i < verbWidth[part.verb]; i++ {
+			b = append(b, '0')
+		}
+		b = append(b, s...)
+	}
+	return string(b)
+}
+
+// parse 从 name 开头匹配模式，返回时段开始时间和模式之后剩余的部分
+func (p *namePattern) parse(name string, loc *time.Location) (time.Time, string, bool) {
+	y, m, d, hh, mm := 1, 1, 1, 0, 0
+	rest := name
+	for _, part := range p.parts {
+		if part.verb == 0 {
+			if !strings.HasPrefix(rest, part.lit) {
+				return time.Time{}, "", false
+			}
+			rest = rest[len(part.lit):]
+			continue
+		}
+		n := verbWidth[part.verb]
+		if len(rest) < n {
+			return time.Time{}, "", false
+		}
+		v, err := strconv.Atoi(rest[:n])
+		if err != nil || rest[0] == '+' || rest[0] == '-' {
+			return time.Time{}, "", false
+		}
+		rest = rest[n:]
+		switch part.verb {
+		case 'Y':
+			y = v
+		case 'y':
+			y = 2000 + v
+		case 'm':
+			m = v
+		case 'd':
+			d = v
+		case 'H':
+			hh = v
+		case 'M':
+			mm = v
+		}
+	}
+	if m < 1 || m > 12 || d < 1 || d > 31 || hh > 23 || mm > 59 {
+		return time.Time{}, "", false
+	}
+	t := time.Date(y, time.Month(m), d, hh, mm, 0, 0, loc)
+	if t.Day() != d {
+		// 2 月 30 日这类不存在的日期
+		return time.Time{}, "", false
+	}
+	return t, rest, true
+}
+
+// next 返回 t 之后下一个需要切换文件的时间：按模式最细的时间单位，最长为一天
+func (p *namePattern) next(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch p.unit {
+	case 'H':
+		return time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+	case 'M':
+		return time.Date(y, m, d, t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+// ownsName 判断文件名是否属于本writer：开头与模式匹配，之后为空（当前文件）或为以 .log 结尾的轮转后缀；
+// 同目录下 base.error.<日期>.log 这类其他 writer 的文件不算
+func (w *FileWriter) ownsName(name string) bool {
+	_, rest, ok := w.pattern.parse(name, time.UTC)
+	if !ok {
+		return false
+	}
+	return rest == "" || rest[0] == '.' && strings.HasSuffix(rest, ".log")
+}
+
+// compilePattern 编译 WithFilePattern 或默认的文件名模式
+func (w *FileWriter) compilePattern() error {
+	spec := w.patternSpec
+	if spec == "" {
+		spec = defaultPattern(w.fileName, w.layout)
+	}
+	p, err := compilePattern(spec)
+	if err != nil {
+		return err
+	}
+	w.pattern = p
+	return nil
+}
@@ -0,0 +1,83 @@
+package h2sanlog
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseLogName(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          time.Time
+		n             int
+	}{
+		{"app.%Y-%m-%d.log", "app.2026-03-01.log", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 0},
+		{"app.%Y-%m-%d.log", "/var/log/app.2026-03-01.log.full.12.log", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 12},
+		{"app.%Y%m%d%H.log", "app.2026030109.log.full.1.log", time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC), 1},
+		{"app-%y%m%d-%H%M.log", "app-260301-0905.log", time.Date(2026, 3, 1, 9, 5, 0, 0, time.UTC), 0},
+		// 基础文件名带点号
+		{"my.app.%Y-%m-%d.log", "my.app.2026-03-01.log.full.2.log", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 2},
+		{"100%%.%Y.log", "100%.2026.log", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+		{"app.log", "app.log.full.3.log", time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), 3},
+	}
+	for _, c := range cases {
+		got, n, err := ParseLogName(c.pattern, c.name, time.UTC)
+		if err != nil || !got.Equal(c.want) || n != c.n {
+			t.Fatalf("ParseLogName(%q, %q) = %v, %d, %v; want %v, %d", c.pattern, c.name, got, n, err, c.want, c.n)
+		}
+	}
+}
+
+func TestParseLogNameErrors(t *testing.T) {
+	for _, c := range [][2]string{
+		{"app.%Y-%m-%d.log", "app.2026-3-01.log"},
+		{"app.%Y-%m-%d.log", "app.2026-13-01.log"},
+		{"app.%Y-%m-%d.log", "app.2026-02-30.log"},
+		{"app.%Y-%m-%d.log", "app.+026-03-01.log"},
+		{"app.%Y-%m-%d.log", "other.2026-03-01.log"},
+		{"app.%Y-%m-%d.log", "app.2026-03-01.log.gz"},
+		{"app.%Y-%m-%d.log", "app.2026-03-01.log.full.x.log"},
+		{"app.%H.log", "app.24.log"},
+		{"app.%Q.log", "app.1.log"},
+		{"app.%", "app.1"},
+		{"logs/app.%Y.log", "app.2026.log"},
+	} {
+		if _, _, err := ParseLogName(c[0], c[1], time.UTC); err == nil {
+			t.Fatalf("ParseLogName(%q, %q) succeeded", c[0], c[1])
+		}
+	}
+}
+
+func TestParseLogNameLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	got, _, err := ParseLogName("app.%Y%m%d%H.log", "app.2026030109.log", loc)
+	if err != nil || !got.Equal(time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+}
+
+// writer 生成的当前文件和轮转文件都能按 DefaultFilePattern 解析
+func TestParseLogNameWriterFiles(t *testing.T) {
+	w := newTestWriter(t, 10, 0, WithUTC())
+	for _, s := range []string{"12345678\n", "abcdefgh\n", "last\n"} {
+		w.Write([]byte(s))
+		w.Flush(time.Second)
+	}
+	day := time.Now().UTC()
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	pattern := DefaultFilePattern(w.fileName)
+	names := append(rotatedFiles(t, w), activePath(w))
+	for i, name := range names {
+		got, n, err := ParseLogName(pattern, name, time.UTC)
+		if want := (i + 1) % len(names); err != nil || !got.Equal(day) || n != want {
+			t.Fatalf("%s: %v, %d, %v; want %v, %d", filepath.Base(name), got, n, err, day, want)
+		}
+	}
+}
+
+func activePath(w *FileWriter) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.filePath
+}
@@ -0,0 +1,90 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RotationPolicy 决定日志文件超过 maxSize 时重命名的目标路径
+type RotationPolicy interface {
+	// RotateName active 为当前日志文件路径，existing 为同目录下所有文件路径
+	RotateName(active string, existing []string) string
+}
+
+// RetentionPolicy 决定轮转后需要删除的老日志文件
+type RetentionPolicy interface {
+	// Expired active 为当前日志文件路径，existing 为轮转后同目录下所有文件路径，返回需要删除的文件路径
+	Expired(active string, existing []string) []string
+}
+
+// WithRotationPolicy 自定义轮转命名规则，默认为 <active>.full.N.log
+func WithRotationPolicy(p RotationPolicy) FileOption {
+	return func(w *FileWriter) {
+		w.rotation = p
+	}
+}
+
+// WithRetentionPolicy 自定义清理规则，默认保留最新的 maxNum 个 .full.N.log 文件
+func WithRetentionPolicy(p RetentionPolicy) FileOption {
+	return func(w *FileWriter) {
+		w.retention = p
+	}
+}
+
+// fullRotation 默认轮转命名: going.2018-05-22.log.full.N.log，N 递增，
+// 织云日志清理规则 默认需要以 .log 结尾
+type fullRotation struct{}
+
+func (fullRotation) RotateName(active string, existing []string) string {
+	maxNum := 0
+	for _, f := range existing {
+		if n, ok := fullIndex(active, f); ok && n > maxNum {
+			maxNum = n
+		}
+	}
+	return fmt.Sprintf("%s.full.%d.log", active, maxNum+1)
+}
+
+// fullRetention 默认清理规则: 保留序号最大的 maxNum 个 .full.N.log 文件，maxNum<=0 不清理
+type fullRetention struct {
+	maxNum int
+}
+
+func (p fullRetention) Expired(active string, existing []string) []string {
+	if p.maxNum <= 0 {
+		return nil
+	}
+	type full struct {
+		n    int
+		path string
+	}
+	var fulls []full
+	for _, f := range existing {
+		if n, ok := fullIndex(active, f); ok {
+			fulls = append(fulls, full{n, f})
+		}
+	}
+	if len(fulls) <= p.maxNum {
+		return nil
+	}
+	sort.Slice(fulls, func(i, j int) bool { return fulls[i].n < fulls[j].n })
+	var expired []string
+	for _, f := range fulls[:len(fulls)-p.maxNum] {
+		expired = append(expired, f.path)
+	}
+	return expired
+}
+
+// fullIndex 解析 <active>.full.N.log 的序号 N
+func fullIndex(active, path string) (int, bool) {
+	prefix := filepath.Base(active) + ".full."
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".log") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".log"))
+	return n, err == nil
+}
@@ -0,0 +1,44 @@
+package h2sanlog
+
+import "sync"
+
+// maxPooledBuf 超过该大小的缓冲不放回池，避免偶发的大日志长期占用内存
+const maxPooledBuf = 64 << 10
+
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// getBuf 从池中取一个空缓冲
+func getBuf() *[]byte {
+	return bufPool.Get().(*[]byte)
+}
+
+// putBuf 缓冲用完后放回池
+func putBuf(b *[]byte) {
+	if cap(*b) > maxPooledBuf {
+		return
+	}
+	*b = (*b)[:0]
+	bufPool.Put(b)
+}
+
+var entryPool = sync.Pool{
+	New: func() interface{} {
+		return new(Entry)
+	},
+}
+
+// getEntry 从池中取一个 Entry
+func getEntry() *Entry {
+	return entryPool.Get().(*Entry)
+}
+
+// putEntry 写完后放回池，放回前清空避免持有字段引用
+func putEntry(e *Entry) {
+	*e = Entry{}
+	entryPool.Put(e)
+}
@@ -0,0 +1,125 @@
+package h2sanlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// errPreallocUnsupported 当前平台或文件系统不支持预分配
+var errPreallocUnsupported = errors.New("file preallocation not supported")
+
+// WithPreallocate 为当前日志文件预分配 size 字节的磁盘空间（不改变文件大小），减少繁忙文件系统上的碎片；
+// 同时在后台准备一个预分配好的备用文件，按大小轮转时直接改名为新的当前文件，避免轮转时分配空间的延迟。
+// 关闭文件前释放未写满的部分。仅 Linux 支持，其他平台或文件系统不支持时忽略；WithFileLock 多进程共享时不生效
+func WithPreallocate(size int64) FileOption {
+	return func(w *FileWriter) {
+		w.prealloc = size
+	}
+}
+
+// preallocEnabled 调用方需持有锁
+func (w *FileWriter) preallocEnabled() bool {
+	return w.prealloc > 0 && !w.useFileLock
+}
+
+// preallocate 为新的当前文件预分配空间，调用方需持有锁
+func (w *FileWriter) preallocate(file *os.File) {
+	if !w.preallocEnabled() {
+		return
+	}
+	if err := preallocate(file, w.prealloc); err != nil {
+		if err == errPreallocUnsupported {
+			w.prealloc = 0
+			return
+		}
+		w.onError(fmt.Errorf("preallocate file:%s fail:%w", file.Name(), err))
+	}
+}
+
+// trimPrealloc 关闭当前文件前截断到实际大小，释放预分配但未写入的空间，调用方需持有锁
+func (w *FileWriter) trimPrealloc() {
+	if !w.preallocEnabled() {
+		return
+	}
+	fi, err := w.file.Stat()
+	if err == nil {
+		err = w.file.Truncate(fi.Size())
+	}
+	if err != nil {
+		w.onError(fmt.Errorf("trim file path:%s fail:%w", w.filePath, err))
+	}
+}
+
+// spareOf 当前日志文件的备用文件，以 . 开头且不以 .log 结尾，不会被当作日志文件
+func spareOf(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".spare")
+}
+
+// takeSpare 按大小轮转、当前文件已改名后，把备用文件改名为新的当前文件，没有备用文件时由 openFile 新建，调用方需持有锁
+func (w *FileWriter) takeSpare() {
+	if !w.preallocEnabled() || w.spare == "" {
+		return
+	}
+	if fi, err := os.Stat(w.spare); err != nil || fi.Size() != 0 {
+		return
+	}
+	if _, err := os.Lstat(w.filePath); err == nil {
+		return
+	}
+	renameFile(w.spare, w.filePath)
+}
+
+// prepareSpare 后台为当前日志文件准备备用文件，当前文件换了目录或日期时删除旧的备用文件，调用方需持有锁
+func (w *FileWriter) prepareSpare() {
+	if !w.preallocEnabled() {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&w.spareBusy, 0, 1) {
+		// 上一个备用文件还在准备，下次轮转时再准备
+		return
+	}
+	spare := spareOf(w.filePath)
+	if w.spare != "" && w.spare != spare {
+		os.Remove(w.spare)
+	}
+	w.spare = spare
+	go w.makeSpare(spare, w.prealloc)
+}
+
+func (w *FileWriter) makeSpare(spare string, size int64) {
+	defer atomic.StoreInt32(&w.spareBusy, 0)
+	if _, err := os.Stat(spare); err == nil {
+		return
+	}
+	tmp := spare + ".tmp"
+	file, err := openAppend(tmp)
+	if err != nil {
+		w.onError(fmt.Errorf("create spare file:%s fail:%w", tmp, err))
+		return
+	}
+	err = preallocate(file, size)
+	file.Close()
+	if err == nil {
+		err = renameFile(tmp, spare)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		if err != errPreallocUnsupported {
+			w.onError(fmt.Errorf("create spare file:%s fail:%w", spare, err))
+		}
+		return
+	}
+	if atomic.LoadInt32(&w.closed) == 1 {
+		os.Remove(spare)
+	}
+}
+
+// removeSpare 关闭时删除备用文件，调用方需持有锁
+func (w *FileWriter) removeSpare() {
+	if w.spare != "" && atomic.LoadInt32(&w.spareBusy) == 0 {
+		os.Remove(w.spare)
+	}
+}
@@ -0,0 +1,28 @@
+package h2sanlog
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize FALLOC_FL_KEEP_SIZE，分配空间但不改变文件大小，追加写仍从实际末尾开始
+const fallocKeepSize = 0x1
+
+// preallocate 为 file 预分配 size 字节，文件系统不支持时返回 errPreallocUnsupported
+func preallocate(file *os.File, size int64) error {
+	rc, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	err = rc.Control(func(fd uintptr) {
+		ferr = syscall.Fallocate(int(fd), fallocKeepSize, 0, size)
+	})
+	if err != nil {
+		return err
+	}
+	if ferr == syscall.EOPNOTSUPP || ferr == syscall.ENOSYS {
+		return errPreallocUnsupported
+	}
+	return ferr
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package h2sanlog
+
+import "os"
+
+// preallocate 当前平台不支持预分配
+func preallocate(*os.File, int64) error {
+	return errPreallocUnsupported
+}
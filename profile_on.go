@@ -0,0 +1,27 @@
+//go:build h2sanlog_profile
+
+package h2sanlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// lock 获取 writer 锁并统计等待时间
+func (w *FileWriter) lock() {
+	start := time.Now()
+	w.mu.Lock()
+	atomic.AddInt64(&w.counters.lockWaitNs, int64(time.Since(start)))
+	atomic.AddUint64(&w.counters.lockWaits, 1)
+}
+
+// sendStart 开始写入队列
+func (w *FileWriter) sendStart() time.Time {
+	return time.Now()
+}
+
+// sendDone 统计写入队列耗时
+func (w *FileWriter) sendDone(start time.Time) {
+	atomic.AddInt64(&w.counters.sendWaitNs, int64(time.Since(start)))
+	atomic.AddUint64(&w.counters.sends, 1)
+}
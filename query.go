@@ -0,0 +1,109 @@
+package h2sanlog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// stdTimeRe 标准库 log 的 Ldate|Ltime[|Lmicroseconds] 时间
+var stdTimeRe = regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)?`)
+
+// querySlack 按文件修改时间筛选文件时的余量
+const querySlack = time.Second
+
+// Query 按时间顺序在当前文件和已轮转的文件中查找 [from, to] 内的日志写入 out，用于"最近 10 分钟日志"这类支持接口。
+// 支持标准库 log 的日期时间前缀（按本地时区解析）和 JSONEncoder 的 time 字段；
+// 取不到时间的行（堆栈、多行消息的后续行）跟随上一行
+func (w *FileWriter) Query(from, to time.Time, out io.Writer) error {
+	files := w.logFiles()
+	var prevEnd time.Time
+	for _, f := range files {
+		start, end := prevEnd, f.info.ModTime()
+		prevEnd = end
+		//文件大致覆盖 (上一个文件的修改时间, 本文件的修改时间]，修改时间精度较粗，留出余量后按行精确过滤
+		if end.Add(querySlack).Before(from) || start.Add(-querySlack).After(to) {
+			continue
+		}
+		done, err := queryFile(f.path, from, to, out)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	return nil
+}
+
+// queryFile 输出一个文件中时间范围内的日志，读到晚于 to 的日志时返回 done
+func queryFile(path string, from, to time.Time, out io.Writer) (done bool, err error) {
+	f, err := openShared(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64<<10)
+	in := false
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			//超长行，读完整行
+			rest, e := r.ReadBytes('\n')
+			line, err = append(append([]byte(nil), line...), rest...), e
+		}
+		if len(line) > 0 {
+			if t, prec, ok := lineTime(line); ok {
+				if t.After(to) {
+					return true, nil
+				}
+				//日志时间按精度截断过，截断前可能晚于 from
+				in = t.Add(prec).After(from)
+			}
+			if in {
+				if _, werr := out.Write(line); werr != nil {
+					return false, werr
+				}
+			}
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// lineTime 解析一行日志的时间，prec 为时间的精度
+func lineTime(line []byte) (t time.Time, prec time.Duration, ok bool) {
+	if i := bytes.Index(line, []byte(`"time":"`)); i >= 0 {
+		rest := line[i+8:]
+		if j := bytes.IndexByte(rest, '"'); j > 0 {
+			if t, err := time.Parse(time.RFC3339Nano, string(rest[:j])); err == nil {
+				return t, time.Nanosecond, true
+			}
+		}
+	}
+	head := line
+	if len(head) > 96 {
+		head = head[:96]
+	}
+	if loc := stdTimeRe.FindIndex(head); loc != nil {
+		layout := "2006/01/02 15:04:05"
+		prec = time.Second
+		if digits := loc[1] - loc[0] - len(layout) - 1; digits > 0 {
+			layout += "." + "000000000"[:digits]
+			for i := 0; i < digits; i++ {
+				prec /= 10
+			}
+		}
+		if t, err := time.ParseInLocation(layout, string(head[loc[0]:loc[1]]), time.Local); err == nil {
+			return t, prec, true
+		}
+	}
+	return time.Time{}, 0, false
+}
@@ -0,0 +1,99 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queryBase 测试日志的起始时间，取整分钟便于构造标准库格式的时间前缀
+var queryBase = time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+
+func queryLine(i int) string {
+	return fmt.Sprintf("%s [INFO] event %d\n", queryBase.Add(time.Duration(i)*time.Minute).Format("2006/01/02 15:04:05"), i)
+}
+
+// writeRotated 写入 n 条每分钟一条的日志，每个文件 3 条，按最后一条日志的时间设置文件修改时间
+func writeRotated(t *testing.T, n int) *FileWriter {
+	t.Helper()
+	w := newTestWriter(t, int64(3*len(queryLine(0))), 0, WithFilePattern("app.log"))
+	for i := 0; i < n; i++ {
+		w.Write([]byte(queryLine(i)))
+		if i%3 == 2 {
+			// 每个文件写完后再写下一条，下一次写入时轮转
+			w.Flush(time.Second)
+		}
+	}
+	w.Flush(time.Second)
+	files := append(rotatedFiles(t, w), w.filePath)
+	for i, f := range files {
+		last := i*3 + 2
+		if last >= n {
+			last = n - 1
+		}
+		mt := queryBase.Add(time.Duration(last) * time.Minute)
+		if err := os.Chtimes(f, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+func TestQueryAcrossRotatedFiles(t *testing.T) {
+	w := writeRotated(t, 10)
+	if n := len(rotatedFiles(t, w)); n != 3 {
+		t.Fatalf("rotated files = %d, want 3", n)
+	}
+	var out bytes.Buffer
+	if err := w.Query(queryBase.Add(2*time.Minute), queryBase.Add(7*time.Minute), &out); err != nil {
+		t.Fatal(err)
+	}
+	var want strings.Builder
+	for i := 2; i <= 7; i++ {
+		want.WriteString(queryLine(i))
+	}
+	if out.String() != want.String() {
+		t.Fatalf("got\n%s\nwant\n%s", out.String(), want.String())
+	}
+
+	out.Reset()
+	w.Query(queryBase.Add(time.Hour), queryBase.Add(2*time.Hour), &out)
+	if out.Len() != 0 {
+		t.Fatalf("range after all logs: %q", out.String())
+	}
+}
+
+func TestQueryContinuationAndJSON(t *testing.T) {
+	w := newTestWriter(t, 0, 0)
+	in := queryLine(0) + queryLine(1) + "goroutine 1 [running]:\n\tmain.go:10\n" +
+		`{"time":"` + queryBase.Add(2*time.Minute).Format(time.RFC3339Nano) + `","msg":"json"}` + "\n" + queryLine(3)
+	w.Write([]byte(in))
+	w.Flush(time.Second)
+	var out bytes.Buffer
+	if err := w.Query(queryBase.Add(time.Minute), queryBase.Add(2*time.Minute), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := queryLine(1) + "goroutine 1 [running]:\n\tmain.go:10\n" + `{"time":"`
+	if got := out.String(); !strings.HasPrefix(got, want) || !strings.HasSuffix(got, `"msg":"json"}`+"\n") {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestLineTimePrecision(t *testing.T) {
+	for line, prec := range map[string]time.Duration{
+		"2026/03/01 10:00:00 x":        time.Second,
+		"2026/03/01 10:00:00.123 x":    time.Millisecond,
+		"2026/03/01 10:00:00.123456 x": time.Microsecond,
+	} {
+		ts, p, ok := lineTime([]byte(line))
+		if !ok || p != prec || ts.Truncate(time.Second) != queryBase {
+			t.Fatalf("%q: %v %v %v", line, ts, p, ok)
+		}
+	}
+	if _, _, ok := lineTime([]byte("no time here")); ok {
+		t.Fatal("parsed a line without time")
+	}
+}
@@ -0,0 +1,353 @@
+package h2sanlog
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultQueueSize 默认队列容量
+const defaultQueueSize = 256
+
+// queue FileWriter 的写入队列，多生产者单消费者
+type queue interface {
+	// push 非阻塞入队，队列满返回false
+	push(b *[]byte) bool
+	// pop 出队，队列为空时等待直到有数据或 tick 触发，tick 触发返回 nil, false；tick 为 nil 时一直等待
+	pop(tick <-chan time.Time) (*[]byte, bool)
+	// tryPop 非阻塞出队，队列为空返回false
+	tryPop() (*[]byte, bool)
+	len() int
+	cap() int
+}
+
+// chanQueue 基于带缓冲channel的队列
+type chanQueue chan *[]byte
+
+func newChanQueue(size int) chanQueue {
+	return make(chanQueue, size)
+}
+
+func (q chanQueue) push(b *[]byte) bool {
+	select {
+	case q <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q chanQueue) pop(tick <-chan time.Time) (*[]byte, bool) {
+	select {
+	case b := <-q:
+		return b, true
+	case <-tick:
+		return nil, false
+	}
+}
+
+func (q chanQueue) tryPop() (*[]byte, bool) {
+	select {
+	case b := <-q:
+		return b, true
+	default:
+		return nil, false
+	}
+}
+
+func (q chanQueue) len() int { return len(q) }
+
+func (q chanQueue) cap() int { return cap(q) }
+
+// WithRingBuffer 使用无锁环形队列代替channel，capacity 向上取整为2的幂，
+// 适合极高吞吐的场景（对比见 BenchmarkQueue），填充程度通过 Stats 的 QueueLen/QueueCap 查看
+func WithRingBuffer(capacity int) FileOption {
+	return func(w *FileWriter) {
+		w.queue = newRingQueue(capacity)
+	}
+}
+
+// ringQueue 有界无锁 MPSC 环形队列，每个槽位用序号标记是否可写/可读
+type ringQueue struct {
+	cells  []ringCell
+	mask   uint64
+	enq    uint64
+	deq    uint64
+	notify chan struct{}
+}
+
+type ringCell struct {
+	seq uint64
+	val *[]byte
+}
+
+func newRingQueue(capacity int) *ringQueue {
+	n := 2
+	for n < capacity {
+		n <<= 1
+	}
+	q := &ringQueue{cells: make([]ringCell, n), mask: uint64(n - 1), notify: make(chan struct{}, 1)}
+	for i := range q.cells {
+		q.cells[i].seq = uint64(i)
+	}
+	return q
+}
+
+func (q *ringQueue) push(b *[]byte) bool {
+	pos := atomic.LoadUint64(&q.enq)
+	var c *ringCell
+	for {
+		c = &q.cells[pos&q.mask]
+		seq := atomic.LoadUint64(&c.seq)
+		switch dif := int64(seq) - int64(pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.enq, pos, pos+1) {
+				c.val = b
+				atomic.StoreUint64(&c.seq, pos+1)
+				select {
+				case q.notify <- struct{}{}:
+				default:
+				}
+				return true
+			}
+			pos = atomic.LoadUint64(&q.enq)
+		case dif < 0:
+			//队列满
+			return false
+		default:
+			pos = atomic.LoadUint64(&q.enq)
+		}
+	}
+}
+
+// tryPop 单消费者出队，队列为空返回false
+func (q *ringQueue) tryPop() (*[]byte, bool) {
+	pos := atomic.LoadUint64(&q.deq)
+	c := &q.cells[pos&q.mask]
+	if int64(atomic.LoadUint64(&c.seq))-int64(pos+1) < 0 {
+		return nil, false
+	}
+	b := c.val
+	c.val = nil
+	atomic.StoreUint64(&c.seq, pos+q.mask+1)
+	atomic.StoreUint64(&q.deq, pos+1)
+	return b, true
+}
+
+func (q *ringQueue) pop(tick <-chan time.Time) (*[]byte, bool) {
+	for {
+		if b, ok := q.tryPop(); ok {
+			return b, true
+		}
+		select {
+		case <-q.notify:
+		case <-tick:
+			return nil, false
+		}
+	}
+}
+
+func (q *ringQueue) len() int {
+	n := int64(atomic.LoadUint64(&q.enq)) - int64(atomic.LoadUint64(&q.deq))
+	if n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+func (q *ringQueue) cap() int { return len(q.cells) }
+
+// WithShardedQueue 使用分片队列：写入方按所在 P 选择分片，各分片独立加锁，由同一个消费者轮流取走，
+// 减少大量 goroutine 并发写时争抢同一个 channel；shards<=0 时取 GOMAXPROCS，capacity 为所有分片总容量。
+// 入队时取全局序号，消费者按序号合并各分片，写出顺序与入队顺序一致
+func WithShardedQueue(shards, capacity int) FileOption {
+	return func(w *FileWriter) {
+		w.queue = newShardedQueue(shards, capacity)
+	}
+}
+
+// shardedQueue 多分片 MPSC 队列。刷盘、关闭标记单独入队，消费者取到标记后先取走所有分片中已有的日志，
+// 保证标记之前入队的日志都已写出。
+// goroutine 可能在两次写入之间换到别的 P，同一 goroutine 的日志会落到不同分片，
+// 所以每条日志在分片锁内取递增的序号，grab 只取序号不超过开始时计数的日志并按序号排序
+type shardedQueue struct {
+	shards  []queueShard
+	markers chan *[]byte
+	// hints 按 P 缓存的分片下标，sync.Pool 的 per-P 缓存让同一个 P 上的写入大多落到同一分片
+	hints sync.Pool
+	next  uint32
+	seq   uint64
+
+	notify  chan struct{}
+	waiting int32
+
+	// batch pos marker 只由消费者访问，pending 为 batch 中尚未取出的条数
+	batch   []shardItem
+	pos     int
+	marker  *[]byte
+	pending int64
+}
+
+type shardItem struct {
+	seq uint64
+	b   *[]byte
+}
+
+type queueShard struct {
+	mu sync.Mutex
+	// items 在锁内取序号并追加，序号递增
+	items []shardItem
+	max   int
+	n     int64
+	// 避免相邻分片的锁落在同一缓存行
+	_ [40]byte
+}
+
+func newShardedQueue(shards, capacity int) *shardedQueue {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	per := (capacity + shards - 1) / shards
+	if per < 1 {
+		per = 1
+	}
+	q := &shardedQueue{shards: make([]queueShard, shards), markers: make(chan *[]byte, 4), notify: make(chan struct{}, 1)}
+	for i := range q.shards {
+		q.shards[i].max = per
+		q.shards[i].items = make([]shardItem, 0, per)
+	}
+	q.hints.New = func() interface{} {
+		i := int(atomic.AddUint32(&q.next, 1)-1) % len(q.shards)
+		return &i
+	}
+	return q
+}
+
+func (q *shardedQueue) push(b *[]byte) bool {
+	if b == flushMarker || b == closeMarker {
+		select {
+		case q.markers <- b:
+		default:
+			return false
+		}
+		q.wake()
+		return true
+	}
+	hint := q.hints.Get().(*int)
+	s := &q.shards[*hint]
+	q.hints.Put(hint)
+	s.mu.Lock()
+	if len(s.items) >= s.max {
+		s.mu.Unlock()
+		return false
+	}
+	s.items = append(s.items, shardItem{seq: atomic.AddUint64(&q.seq, 1), b: b})
+	atomic.StoreInt64(&s.n, int64(len(s.items)))
+	s.mu.Unlock()
+	q.wake()
+	return true
+}
+
+// wake 消费者在等待时唤醒它，避免每次入队都操作 channel
+func (q *shardedQueue) wake() {
+	if atomic.LoadInt32(&q.waiting) == 1 && atomic.CompareAndSwapInt32(&q.waiting, 1, 0) {
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// grab 取走所有分片中序号不超过当前计数的日志并按序号排序，返回是否取到。
+// 之后入队的日志留给下一次：它们可能落在已经取过的分片，一起取走会排到同一 goroutine 更早的日志前面
+func (q *shardedQueue) grab() bool {
+	q.batch, q.pos = q.batch[:0], 0
+	limit := atomic.LoadUint64(&q.seq)
+	for i := range q.shards {
+		s := &q.shards[i]
+		s.mu.Lock()
+		n := 0
+		for n < len(s.items) && s.items[n].seq <= limit {
+			n++
+		}
+		if n > 0 {
+			q.batch = append(q.batch, s.items[:n]...)
+			rest := copy(s.items, s.items[n:])
+			for j := rest; j < len(s.items); j++ {
+				s.items[j] = shardItem{}
+			}
+			s.items = s.items[:rest]
+			atomic.StoreInt64(&s.n, int64(rest))
+		}
+		s.mu.Unlock()
+	}
+	sort.Slice(q.batch, func(i, j int) bool { return q.batch[i].seq < q.batch[j].seq })
+	atomic.StoreInt64(&q.pending, int64(len(q.batch)))
+	return len(q.batch) > 0
+}
+
+// takeMarker 取到标记后先取走分片中已有的日志，标记在这些日志之后返回
+func (q *shardedQueue) takeMarker(m *[]byte) {
+	q.marker = m
+	q.grab()
+}
+
+func (q *shardedQueue) tryPop() (*[]byte, bool) {
+	for {
+		if q.pos < len(q.batch) {
+			b := q.batch[q.pos].b
+			q.batch[q.pos] = shardItem{}
+			q.pos++
+			atomic.AddInt64(&q.pending, -1)
+			return b, true
+		}
+		if m := q.marker; m != nil {
+			q.marker = nil
+			return m, true
+		}
+		select {
+		case m := <-q.markers:
+			q.takeMarker(m)
+			continue
+		default:
+		}
+		if !q.grab() {
+			return nil, false
+		}
+	}
+}
+
+func (q *shardedQueue) pop(tick <-chan time.Time) (*[]byte, bool) {
+	for {
+		if b, ok := q.tryPop(); ok {
+			return b, true
+		}
+		atomic.StoreInt32(&q.waiting, 1)
+		// 设置等待标记后再检查一次，避免错过在此之前入队却没有唤醒的日志
+		if q.grab() {
+			atomic.StoreInt32(&q.waiting, 0)
+			continue
+		}
+		select {
+		case <-q.notify:
+		case m := <-q.markers:
+			q.takeMarker(m)
+		case <-tick:
+			atomic.StoreInt32(&q.waiting, 0)
+			return nil, false
+		}
+		atomic.StoreInt32(&q.waiting, 0)
+	}
+}
+
+func (q *shardedQueue) len() int {
+	n := atomic.LoadInt64(&q.pending) + int64(len(q.markers))
+	for i := range q.shards {
+		n += atomic.LoadInt64(&q.shards[i].n)
+	}
+	return int(n)
+}
+
+func (q *shardedQueue) cap() int { return len(q.shards) * q.shards[0].max }
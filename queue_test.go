@@ -0,0 +1,252 @@
+package h2sanlog
+
+import (
+	"encoding/binary"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testQueues() map[string]func(capacity int) queue {
+	return map[string]func(int) queue{
+		"chan":    func(n int) queue { return newChanQueue(n) },
+		"ring":    func(n int) queue { return newRingQueue(n) },
+		"sharded": func(n int) queue { return newShardedQueue(1, n) },
+	}
+}
+
+// item 生成携带生产者编号和序号的日志
+func item(producer, n int) *[]byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(producer))
+	binary.BigEndian.PutUint32(b[4:], uint32(n))
+	return &b
+}
+
+func TestQueueFIFOAndFull(t *testing.T) {
+	for name, newQ := range testQueues() {
+		q := newQ(4)
+		var pushed int
+		for ; pushed < 16 && q.push(item(0, pushed)); pushed++ {
+		}
+		if pushed != q.cap() || q.len() != pushed {
+			t.Fatalf("%s: pushed %d, len %d, cap %d", name, pushed, q.len(), q.cap())
+		}
+		for i := 0; i < pushed; i++ {
+			b, ok := q.tryPop()
+			if !ok || binary.BigEndian.Uint32((*b)[4:]) != uint32(i) {
+				t.Fatalf("%s: pop %d = %v, %v", name, i, b, ok)
+			}
+		}
+		if _, ok := q.tryPop(); ok || q.len() != 0 {
+			t.Fatalf("%s: not empty after draining", name)
+		}
+		tick := time.After(5 * time.Millisecond)
+		if b, ok := q.pop(tick); ok || b != nil {
+			t.Fatalf("%s: pop on empty queue returned %v", name, b)
+		}
+	}
+}
+
+func TestQueuePopWakes(t *testing.T) {
+	for name, newQ := range testQueues() {
+		q := newQ(8)
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			q.push(item(0, 7))
+		}()
+		b, ok := q.pop(nil)
+		if !ok || binary.BigEndian.Uint32((*b)[4:]) != 7 {
+			t.Fatalf("%s: pop = %v, %v", name, b, ok)
+		}
+	}
+}
+
+func TestShardedQueueMarkerAfterLogs(t *testing.T) {
+	q := newShardedQueue(4, 64)
+	for i := 0; i < 10; i++ {
+		q.push(item(0, i))
+	}
+	q.push(flushMarker)
+	for i := 0; i < 10; i++ {
+		b, ok := q.tryPop()
+		if !ok || b == flushMarker {
+			t.Fatalf("marker returned before log %d", i)
+		}
+	}
+	if b, ok := q.tryPop(); !ok || b != flushMarker {
+		t.Fatalf("marker not returned after logs: %v", b)
+	}
+}
+
+// pinShard 让下一次 push 落到分片 i，模拟 goroutine 换到另一个 P
+func pinShard(q *shardedQueue, i int) {
+	q.hints = sync.Pool{New: func() interface{} { return &i }}
+}
+
+func TestShardedQueueShardHop(t *testing.T) {
+	q := newShardedQueue(2, 8)
+	pinShard(q, 1)
+	q.push(item(0, 0))
+	pinShard(q, 0)
+	q.push(item(0, 1))
+	for i := 0; i < 2; i++ {
+		b, ok := q.tryPop()
+		if !ok || binary.BigEndian.Uint32((*b)[4:]) != uint32(i) {
+			t.Fatalf("pop %d = %v, %v", i, b, ok)
+		}
+	}
+}
+
+// 同一 goroutine 的日志可能落到不同分片，消费者取出的顺序仍要和入队顺序一致
+func TestShardedQueueProducerOrder(t *testing.T) {
+	const producers, per = 8, 2000
+	q := newShardedQueue(runtime.GOMAXPROCS(0)+1, 64)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				for !q.push(item(p, i)) {
+					runtime.Gosched()
+				}
+				if i%7 == 0 {
+					runtime.Gosched()
+				}
+			}
+		}(p)
+	}
+	next := make([]uint32, producers)
+	for got := 0; got < producers*per; got++ {
+		b, ok := q.pop(time.After(5 * time.Second))
+		if !ok {
+			t.Fatalf("timeout after %d logs", got)
+		}
+		p, n := binary.BigEndian.Uint32(*b), binary.BigEndian.Uint32((*b)[4:])
+		if n != next[p] {
+			t.Fatalf("producer %d: got %d, want %d", p, n, next[p])
+		}
+		next[p]++
+	}
+	wg.Wait()
+}
+
+func TestShardedQueueWithFileWriter(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithShardedQueue(4, 1024))
+	for _, s := range []string{"a\n", "b\n", "c\n"} {
+		w.Write([]byte(s))
+	}
+	if err := w.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeContent(t, w); got != "a\nb\nc\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRingQueueWraparound(t *testing.T) {
+	q := newRingQueue(4)
+	// 多次绕回，序号超过槽位数后仍按顺序读写
+	for i := 0; i < 100; i++ {
+		if !q.push(item(0, i)) {
+			t.Fatalf("push %d failed", i)
+		}
+		if i%3 == 2 {
+			q.push(item(0, -1))
+			q.tryPop()
+		}
+		b, ok := q.tryPop()
+		if !ok {
+			t.Fatalf("pop %d failed", i)
+		}
+		if n := int32(binary.BigEndian.Uint32((*b)[4:])); n != int32(i) && n != -1 {
+			t.Fatalf("pop %d = %d", i, n)
+		}
+	}
+	if q.len() != 0 {
+		t.Fatalf("len = %d", q.len())
+	}
+}
+
+func TestRingQueueFull(t *testing.T) {
+	q := newRingQueue(3)
+	if q.cap() != 4 {
+		t.Fatalf("cap = %d, want 4", q.cap())
+	}
+	for i := 0; i < 4; i++ {
+		if !q.push(item(0, i)) {
+			t.Fatalf("push %d failed", i)
+		}
+	}
+	if q.push(item(0, 4)) {
+		t.Fatal("push on full queue succeeded")
+	}
+	q.tryPop()
+	if !q.push(item(0, 4)) {
+		t.Fatal("push after pop failed")
+	}
+	for i := 1; i <= 4; i++ {
+		b, _ := q.tryPop()
+		if binary.BigEndian.Uint32((*b)[4:]) != uint32(i) {
+			t.Fatalf("pop = %v, want %d", *b, i)
+		}
+	}
+}
+
+// 多个生产者并发写一个小容量的环形队列，反复绕回和写满，每个生产者的日志保持顺序且不丢
+func TestRingQueueProducerOrder(t *testing.T) {
+	const producers, per = 8, 5000
+	q := newRingQueue(16)
+	for p := 0; p < producers; p++ {
+		go func(p int) {
+			for i := 0; i < per; i++ {
+				for !q.push(item(p, i)) {
+					runtime.Gosched()
+				}
+			}
+		}(p)
+	}
+	next := make([]uint32, producers)
+	for got := 0; got < producers*per; got++ {
+		b, ok := q.pop(time.After(5 * time.Second))
+		if !ok {
+			t.Fatalf("timeout after %d logs", got)
+		}
+		p, n := binary.BigEndian.Uint32(*b), binary.BigEndian.Uint32((*b)[4:])
+		if n != next[p] {
+			t.Fatalf("producer %d: got %d, want %d", p, n, next[p])
+		}
+		next[p]++
+	}
+}
+
+// BenchmarkQueue 多个生产者并发入队、一个消费者出队，队列满时让出 CPU 重试，ns/op 为每条日志的平均耗时
+func BenchmarkQueue(b *testing.B) {
+	for name, newQ := range testQueues() {
+		if name == "sharded" {
+			newQ = func(n int) queue { return newShardedQueue(0, n) }
+		}
+		b.Run(name, func(b *testing.B) {
+			q := newQ(1024)
+			buf := new([]byte)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < b.N; i++ {
+					q.pop(nil)
+				}
+			}()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					for !q.push(buf) {
+						runtime.Gosched()
+					}
+				}
+			})
+			<-done
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package h2sanlog
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRateBuckets 令牌桶数量上限，超过后清理已回满且没有待报告抑制数的桶
+const maxRateBuckets = 10000
+
+// RateLimiter 按key的令牌桶限流，用于防止同一条日志在错误循环中刷屏
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	key   func(e *Entry) string
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+}
+
+// NewRateLimiter 新建限流器，每个key每秒 rate 条、最多突发 burst 条；
+// key 为 nil 时按 logger名+消息 区分
+func NewRateLimiter(rate float64, burst int, key func(e *Entry) string) *RateLimiter {
+	if key == nil {
+		key = func(e *Entry) string { return e.Name + "\x00" + e.Message }
+	}
+	return &RateLimiter{rate: rate, burst: float64(burst), key: key, buckets: make(map[string]*rateBucket)}
+}
+
+// Allow 返回该条日志是否放行；放行时 suppressed 为该key上次放行后被抑制的条数
+func (r *RateLimiter) Allow(e *Entry) (ok bool, suppressed int) {
+	k := r.key(e)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.buckets[k]
+	if b == nil {
+		if len(r.buckets) >= maxRateBuckets {
+			r.purge(e.Time)
+		}
+		b = &rateBucket{tokens: r.burst, last: e.Time}
+		r.buckets[k] = b
+	}
+	b.tokens += e.Time.Sub(b.last).Seconds() * r.rate
+	if b.tokens > r.burst {
+		b.tokens = r.burst
+	}
+	b.last = e.Time
+	if b.tokens < 1 {
+		b.suppressed++
+		return false, 0
+	}
+	b.tokens--
+	suppressed, b.suppressed = b.suppressed, 0
+	return true, suppressed
+}
+
+// purge 清理已回满且没有抑制计数的桶
+func (r *RateLimiter) purge(now time.Time) {
+	for k, b := range r.buckets {
+		if b.suppressed == 0 && b.tokens+now.Sub(b.last).Seconds()*r.rate >= r.burst {
+			delete(r.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRefill(t *testing.T) {
+	r := NewRateLimiter(2, 3, nil)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	allow := func(d time.Duration) (bool, int) {
+		return r.Allow(&Entry{Time: now.Add(d), Message: "loop"})
+	}
+	// 突发 3 条后限流
+	for i := 0; i < 3; i++ {
+		if ok, _ := allow(0); !ok {
+			t.Fatalf("burst %d limited", i)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if ok, _ := allow(0); ok {
+			t.Fatal("allowed past burst")
+		}
+	}
+	// 每秒补 2 个令牌，250ms 只有半个
+	if ok, _ := allow(250 * time.Millisecond); ok {
+		t.Fatal("allowed with half a token")
+	}
+	if ok, n := allow(500 * time.Millisecond); !ok || n != 3 {
+		t.Fatalf("after refill ok = %v suppressed = %d, want 3", ok, n)
+	}
+	// 令牌最多回满到 burst
+	for i := 0; i < 3; i++ {
+		if ok, n := allow(time.Hour); !ok || n != 0 {
+			t.Fatalf("after an hour %d: ok = %v suppressed = %d", i, ok, n)
+		}
+	}
+	if ok, _ := allow(time.Hour); ok {
+		t.Fatal("refilled past burst")
+	}
+}
+
+func TestRateLimiterKeys(t *testing.T) {
+	now := time.Now()
+	r := NewRateLimiter(1, 1, nil)
+	// 默认按 logger名+消息 区分
+	for _, e := range []*Entry{{Time: now, Message: "a"}, {Time: now, Message: "b"}, {Time: now, Name: "x", Message: "a"}} {
+		if ok, _ := r.Allow(e); !ok {
+			t.Fatalf("%+v limited", e)
+		}
+	}
+	if ok, _ := r.Allow(&Entry{Time: now, Message: "a"}); ok {
+		t.Fatal("same key allowed twice")
+	}
+	r = NewRateLimiter(1, 1, func(e *Entry) string { return levelNames[e.Level] })
+	r.Allow(&Entry{Time: now, Level: LogLevelError, Message: "a"})
+	if ok, _ := r.Allow(&Entry{Time: now, Level: LogLevelError, Message: "b"}); ok {
+		t.Fatal("custom key ignored")
+	}
+}
+
+func TestRateLimiterPurge(t *testing.T) {
+	r := NewRateLimiter(1, 1, func(e *Entry) string { return e.Message })
+	now := time.Now()
+	r.Allow(&Entry{Time: now, Message: "limited"})
+	r.Allow(&Entry{Time: now, Message: "limited"})
+	for i := 1; len(r.buckets) < maxRateBuckets; i++ {
+		r.buckets[strings.Repeat("k", i)] = &rateBucket{tokens: 1, last: now}
+	}
+	r.Allow(&Entry{Time: now.Add(time.Second), Message: "new"})
+	// 回满的桶被清理，有待报告抑制数的桶保留
+	if len(r.buckets) != 2 {
+		t.Fatalf("%d buckets after purge, want 2", len(r.buckets))
+	}
+	if ok, n := r.Allow(&Entry{Time: now.Add(time.Second), Message: "limited"}); !ok || n != 1 {
+		t.Fatalf("ok = %v suppressed = %d", ok, n)
+	}
+}
+
+func TestLoggerRateLimiterSummary(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetRateLimiter(NewRateLimiter(50, 1, nil))
+	for i := 0; i < 4; i++ {
+		l.Error("retry failed")
+	}
+	time.Sleep(40 * time.Millisecond)
+	l.Error("retry failed")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "suppressed 3 messages: retry failed") ||
+		!strings.HasSuffix(lines[2], "retry failed") || strings.Contains(lines[2], "suppressed") {
+		t.Fatalf("got %q", buf.String())
+	}
+}
@@ -0,0 +1,87 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 常用敏感信息正则，可传给 Redactor.AddPattern
+const (
+	// PatternCardNumber 13-19位银行卡号，允许空格或-分隔
+	PatternCardNumber = `\b(?:\d[ -]?){12,18}\d\b`
+	// PatternBearerToken Authorization: Bearer xxx 中的token
+	PatternBearerToken = `(?i)bearer\s+[a-z0-9._~+/=-]+`
+)
+
+// Redactor 编码前对敏感字段和匹配的内容打码
+type Redactor struct {
+	mask string
+
+	mu       sync.RWMutex
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor 新建打码器，mask 为替换后的内容，为空时使用 "******"
+func NewRedactor(mask string) *Redactor {
+	if mask == "" {
+		mask = "******"
+	}
+	return &Redactor{mask: mask, keys: make(map[string]bool)}
+}
+
+// AddKey 注册敏感字段名（不区分大小写），这些字段的值整体打码
+func (r *Redactor) AddKey(keys ...string) *Redactor {
+	r.mu.Lock()
+	for _, k := range keys {
+		r.keys[strings.ToLower(k)] = true
+	}
+	r.mu.Unlock()
+	return r
+}
+
+// AddPattern 注册正则，消息和字段值中匹配的部分打码
+func (r *Redactor) AddPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.patterns = append(r.patterns, re)
+	r.mu.Unlock()
+	return nil
+}
+
+// Redact 对 entry 打码，Fields 会重新分配，不修改调用方的字段切片
+func (r *Redactor) Redact(e *Entry) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e.Message = r.replace(e.Message)
+	if len(e.Fields) == 0 {
+		return
+	}
+	fields := make([]Field, len(e.Fields))
+	for i, f := range e.Fields {
+		fields[i] = f
+		if r.keys[strings.ToLower(f.Key)] {
+			fields[i] = Field{Key: f.Key, Value: r.mask}
+			continue
+		}
+		if v := f.Interface(); len(r.patterns) > 0 && v != nil {
+			s := fmt.Sprint(v)
+			if masked := r.replace(s); masked != s {
+				fields[i] = Field{Key: f.Key, Value: masked}
+			}
+		}
+	}
+	e.Fields = fields
+}
+
+func (r *Redactor) replace(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, r.mask)
+	}
+	return s
+}
@@ -0,0 +1,150 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// PartialRepair 启动时当前日志文件末尾残留半行（进程崩溃或断电时没写完的日志）的处理方式
+type PartialRepair uint8
+
+const (
+	// PartialKeep 不处理，默认
+	PartialKeep PartialRepair = iota
+	// PartialMark 在半行后追加 partialMark 和换行补全为一行，保留已写入的内容
+	PartialMark
+	// PartialTruncate 截断到最后一个换行，丢弃半行
+	PartialTruncate
+)
+
+// partialMark PartialMark 补在半行末尾的标记
+const partialMark = " [h2sanlog: partial line]"
+
+// repairChunk 向前查找最后一个换行时每次读取的字节数
+const repairChunk = 64 << 10
+
+// WithPartialRepair 启动时检查当前日志文件末尾是否有不以换行结尾的半行，按 r 标记或截断，
+// 避免按行解析的下游读到崩溃残留，也避免崩溃前的半行和重启后的第一行拼在一起；
+// 半行之后断电残留的 NUL 字节在两种方式下都会截掉。WithFileLock 时持排他锁检查，不会误伤其他进程正在写的行
+func WithPartialRepair(r PartialRepair) FileOption {
+	return func(w *FileWriter) {
+		w.partialRepair = r
+	}
+}
+
+// FileReport VerifyFile 的检查结果
+type FileReport struct {
+	// Size Lines 文件大小和完整的行数
+	Size  int64
+	Lines int64
+	// Partial 最后一个换行之后的字节数，不为 0 表示末尾有半行
+	Partial int64
+	// NulBytes 文件中 NUL 字节数，断电后文件系统可能在末尾留下一段 0
+	NulBytes int64
+}
+
+// OK 文件没有半行和 NUL 字节
+func (r FileReport) OK() bool {
+	return r.Partial == 0 && r.NulBytes == 0
+}
+
+// VerifyFile 完整读一遍按行写入的日志文件，检查末尾半行和 NUL 字节，供下游解析前或运维排查时使用；
+// 只适用于文本和 JSON 行格式，不适用于 msgpack、压缩和加密后的文件
+func VerifyFile(path string) (FileReport, error) {
+	var r FileReport
+	f, err := openShared(path)
+	if err != nil {
+		return r, err
+	}
+	defer f.Close()
+	buf := make([]byte, repairChunk)
+	for {
+		n, err := f.Read(buf)
+		p := buf[:n]
+		r.Size += int64(n)
+		lines := int64(bytes.Count(p, []byte{'\n'}))
+		r.Lines += lines
+		if lines > 0 {
+			r.Partial = int64(n - 1 - bytes.LastIndexByte(p, '\n'))
+		} else {
+			r.Partial += int64(n)
+		}
+		r.NulBytes += int64(bytes.Count(p, []byte{0}))
+		if err == io.EOF {
+			return r, nil
+		}
+		if err != nil {
+			return r, err
+		}
+	}
+}
+
+// repairPartial 按 WithPartialRepair 处理当前文件末尾的半行，调用方需持有锁
+func (w *FileWriter) repairPartial() {
+	if w.partialRepair == PartialKeep {
+		return
+	}
+	w.exclusiveLock()
+	defer w.unlockFile()
+	fi, err := w.file.Stat()
+	if err != nil || fi.Size() == 0 {
+		return
+	}
+	last, nulTail, err := lastNewline(w.file, fi.Size())
+	if err != nil {
+		w.onError(fmt.Errorf("check partial line path:%s fail:%w", w.filePath, err))
+		return
+	}
+	partial := fi.Size() - last - 1
+	if partial == 0 {
+		return
+	}
+	keep := fi.Size() - nulTail
+	if w.partialRepair == PartialTruncate || keep == last+1 {
+		keep = last + 1
+	}
+	if keep < fi.Size() {
+		if err := os.Truncate(w.filePath, keep); err != nil {
+			w.onError(fmt.Errorf("truncate partial line path:%s fail:%w", w.filePath, err))
+			return
+		}
+	}
+	if keep > last+1 {
+		if _, err := w.file.Write([]byte(partialMark + "\n")); err != nil {
+			w.onError(fmt.Errorf("mark partial line path:%s fail:%w", w.filePath, err))
+			return
+		}
+	}
+	w.setActive(w.file)
+	w.degrade("partial_line", fmt.Errorf("repair partial line path:%s bytes:%d", w.filePath, partial))
+}
+
+// lastNewline 从末尾向前查找最后一个换行的位置，没有时为 -1；nulTail 为文件末尾连续 NUL 字节数
+func lastNewline(f *os.File, size int64) (last int64, nulTail int64, err error) {
+	buf := make([]byte, repairChunk)
+	trailing := true
+	for end := size; end > 0; {
+		start := end - repairChunk
+		if start < 0 {
+			start = 0
+		}
+		p := buf[:end-start]
+		if _, err := f.ReadAt(p, start); err != nil && err != io.EOF {
+			return 0, 0, err
+		}
+		for i := len(p) - 1; i >= 0; i-- {
+			if p[i] == '\n' {
+				return start + int64(i), nulTail, nil
+			}
+			if trailing && p[i] == 0 {
+				nulTail++
+			} else {
+				trailing = false
+			}
+		}
+		end = start
+	}
+	return -1, nulTail, nil
+}
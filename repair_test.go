@@ -0,0 +1,90 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openWithContent 写入 content 后在同一路径上打开 FileWriter
+func openWithContent(t *testing.T, content string, r PartialRepair) (*FileWriter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewFileWriter(filepath.Join(dir, "app"), 0, 0, WithFilePattern("app.log"), WithPartialRepair(r),
+		WithOnError(func(error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w, path
+}
+
+func TestPartialRepair(t *testing.T) {
+	mark := " [h2sanlog: partial line]\n"
+	big := strings.Repeat("x", repairChunk+10)
+	cases := []struct {
+		name, content string
+		r             PartialRepair
+		want          string
+	}{
+		{"keep", "a\nhalf", PartialKeep, "a\nhalf"},
+		{"mark", "a\nhalf", PartialMark, "a\nhalf" + mark},
+		{"truncate", "a\nhalf", PartialTruncate, "a\n"},
+		{"complete", "a\nb\n", PartialTruncate, "a\nb\n"},
+		{"mark nul tail", "a\nhalf\x00\x00\x00", PartialMark, "a\nhalf" + mark},
+		{"only nul", "a\n\x00\x00", PartialMark, "a\n"},
+		{"no newline", "half", PartialTruncate, ""},
+		{"long partial", "a\n" + big, PartialTruncate, "a\n"},
+	}
+	for _, c := range cases {
+		w, path := openWithContent(t, c.content, c.r)
+		w.Write([]byte("next\n"))
+		w.Flush(time.Second)
+		b, _ := ioutil.ReadFile(path)
+		got := string(b)
+		if !strings.HasPrefix(got, c.want) || !strings.Contains(got[len(c.want):], "next\n") {
+			t.Fatalf("%s: got %q", c.name, got)
+		}
+		if c.r != PartialKeep && bytes.IndexByte(b, 0) >= 0 {
+			t.Fatalf("%s: NUL bytes left: %q", c.name, got)
+		}
+		if repaired := strings.Contains(got, "event=partial_line"); repaired != (c.r != PartialKeep && c.want != c.content) {
+			t.Fatalf("%s: partial_line event = %v", c.name, repaired)
+		}
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		content string
+		want    FileReport
+	}{
+		{"", FileReport{}},
+		{"a\nb\n", FileReport{Size: 4, Lines: 2}},
+		{"a\nhalf", FileReport{Size: 6, Lines: 1, Partial: 4}},
+		{"a\n\x00\x00", FileReport{Size: 4, Lines: 1, Partial: 2, NulBytes: 2}},
+		{strings.Repeat("y", repairChunk) + "z", FileReport{Size: repairChunk + 1, Partial: repairChunk + 1}},
+	}
+	for i, c := range cases {
+		path := filepath.Join(dir, "f.log")
+		ioutil.WriteFile(path, []byte(c.content), 0644)
+		r, err := VerifyFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != c.want || r.OK() != (c.want.Partial == 0 && c.want.NulBytes == 0) {
+			t.Fatalf("case %d: got %+v, want %+v", i, r, c.want)
+		}
+	}
+	if _, err := VerifyFile(filepath.Join(dir, "missing.log")); err == nil {
+		t.Fatal("want error for missing file")
+	}
+}
@@ -0,0 +1,91 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RepeatSuppressor 折叠连续重复的日志：window 内与上一条级别、logger名、消息和字段都相同的日志不输出，
+// 出现不同的日志或 window 到期时补一条 "last message repeated N times"，错误风暴时大幅减少日志量
+type RepeatSuppressor struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	last   Entry
+	sum    uint64
+	hasSum bool
+	from   *Logger
+	count  int
+	timer  *time.Timer
+}
+
+// NewRepeatSuppressor 新建重复折叠器，window 从一组重复的第一条开始计算
+func NewRepeatSuppressor(window time.Duration) *RepeatSuppressor {
+	return &RepeatSuppressor{window: window}
+}
+
+// SetRepeatSuppressor 设置重复折叠，nil 取消；子logger与父logger共用同一个折叠器，Synced 子logger不折叠
+func (l *Logger) SetRepeatSuppressor(r *RepeatSuppressor) {
+	l.repeat = r
+}
+
+// check 返回 e 是否为重复日志；不重复时返回需要先输出的汇总
+func (r *RepeatSuppressor) check(l *Logger, e *Entry) (dup bool, summary *Entry, from *Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.from != nil && r.same(e) && e.Time.Sub(r.last.Time) < r.window {
+		r.count++
+		if r.timer == nil {
+			r.timer = time.AfterFunc(r.last.Time.Add(r.window).Sub(e.Time), r.expire)
+		}
+		return true, nil, nil
+	}
+	summary, from = r.takeSummary()
+	r.last = e.Clone()
+	r.hasSum = false
+	r.from = l
+	return false, summary, from
+}
+
+// same 比较 e 与上一条日志，字段摘要只在前面都相同时计算
+func (r *RepeatSuppressor) same(e *Entry) bool {
+	if e.Level != r.last.Level || e.Name != r.last.Name || e.Message != r.last.Message || len(e.Fields) != len(r.last.Fields) {
+		return false
+	}
+	if !r.hasSum {
+		r.sum = fingerprint("", r.last.Fields)
+		r.hasSum = true
+	}
+	return fingerprint("", e.Fields) == r.sum
+}
+
+// takeSummary 生成重复汇总并清零计数，调用方需持有锁
+func (r *RepeatSuppressor) takeSummary() (*Entry, *Logger) {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	if r.count == 0 {
+		return nil, nil
+	}
+	s := r.last
+	s.Time = time.Now()
+	s.Message = fmt.Sprintf("last message repeated %d times", r.count)
+	s.Fields = append(s.Fields[:len(s.Fields):len(s.Fields)], Field{Key: "repeated", Value: r.count})
+	s.Stack = nil
+	r.count = 0
+	return &s, r.from
+}
+
+// expire window 到期时输出汇总，之后同样的日志重新开始计数
+func (r *RepeatSuppressor) expire() {
+	r.mu.Lock()
+	r.timer = nil
+	summary, from := r.takeSummary()
+	r.from = nil
+	r.mu.Unlock()
+	if summary != nil {
+		from.write(summary, 2)
+	}
+}
@@ -0,0 +1,84 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer 可在定时器 goroutine 中写入的 bytes.Buffer
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(b.buf.String(), "\n"), "\n")
+}
+
+func TestRepeatFoldedOnChange(t *testing.T) {
+	var buf lockedBuffer
+	l := New(&buf, "", 0)
+	l.SetRepeatSuppressor(NewRepeatSuppressor(time.Minute))
+	for i := 0; i < 4; i++ {
+		l.Log(LogLevelError, "conn refused", String("host", "db1"))
+	}
+	// 字段不同不算重复
+	l.Log(LogLevelError, "conn refused", String("host", "db2"))
+	l.Info("recovered")
+	lines := buf.lines()
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines: %q", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "conn refused") || !strings.Contains(lines[1], "last message repeated 3 times") ||
+		!strings.Contains(lines[1], "[ERROR]") || !strings.Contains(lines[1], "repeated=3") || !strings.Contains(lines[1], "host=db1") {
+		t.Fatalf("summary = %q", lines[:2])
+	}
+	if !strings.Contains(lines[2], "host=db2") || !strings.Contains(lines[3], "recovered") {
+		t.Fatalf("got %q", lines[2:])
+	}
+}
+
+// window 到期时即使没有新日志也输出汇总，之后同样的日志重新输出
+func TestRepeatWindowExpire(t *testing.T) {
+	var buf lockedBuffer
+	l := New(&buf, "", 0)
+	l.SetRepeatSuppressor(NewRepeatSuppressor(30 * time.Millisecond))
+	for i := 0; i < 3; i++ {
+		l.Warning("disk slow")
+	}
+	waitFor(t, "expire summary", func() bool { return len(buf.lines()) == 2 })
+	if got := buf.lines()[1]; !strings.Contains(got, "last message repeated 2 times") {
+		t.Fatalf("summary = %q", got)
+	}
+	l.Warning("disk slow")
+	if got := buf.lines(); len(got) != 3 || !strings.HasSuffix(got[2], "disk slow") {
+		t.Fatalf("after expire got %q", got)
+	}
+}
+
+func TestRepeatNoSummaryWithoutRepeats(t *testing.T) {
+	var buf lockedBuffer
+	l := New(&buf, "", 0)
+	l.SetRepeatSuppressor(NewRepeatSuppressor(time.Minute))
+	// 子logger共用折叠器，名字不同不算重复
+	l.Info("a")
+	l.Named("x").Info("a")
+	l.Info("b")
+	if got := buf.lines(); len(got) != 3 || strings.Contains(strings.Join(got, "\n"), "repeated") {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WithReplay 开发模式使用：启动时把上次运行写入的最后 n 行输出到 out（如 os.Stderr），
+// 方便重启崩溃的服务后立刻看到发生了什么；out 为 nil 时输出到 stderr
+func WithReplay(n int, out io.Writer) FileOption {
+	return func(w *FileWriter) {
+		w.replayLines = n
+		w.replayOut = out
+		if out == nil {
+			w.replayOut = os.Stderr
+		}
+	}
+}
+
+// replay 读取上次运行日志的最后 replayLines 行，加上首尾标记后返回，需在新日志写入前调用
+func (w *FileWriter) replay() []byte {
+	if w.replayLines <= 0 {
+		return nil
+	}
+	path := w.previousFile()
+	if path == "" {
+		return nil
+	}
+	lines, err := tailLines(path, w.replayLines)
+	if err != nil {
+		w.onError(fmt.Errorf("replay file path:%s fail:%w", path, err))
+		return nil
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "---- last %d lines of previous run: %s ----\n", bytes.Count(lines, []byte("\n")), path)
+	b.Write(lines)
+	fmt.Fprintf(&b, "---- end of previous run ----\n")
+	return b.Bytes()
+}
+
+// previousFile 返回最近修改过的非空日志文件，包含当天文件和 .full 文件
+func (w *FileWriter) previousFile() string {
+	files := w.logFiles()
+	for i := len(files) - 1; i >= 0; i-- {
+		if files[i].info.Size() > 0 {
+			return files[i].path
+		}
+	}
+	return ""
+}
+
+// tailLines 从文件末尾向前读取最后 n 行
+func tailLines(path string, n int) ([]byte, error) {
+	f, err := openShared(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const chunk = 4096
+	size := fi.Size()
+	var buf []byte
+	for off := size; off > 0; {
+		step := int64(chunk)
+		if off < step {
+			step = off
+		}
+		off -= step
+		b := make([]byte, step)
+		if _, err := f.ReadAt(b, off); err != nil {
+			return nil, err
+		}
+		buf = append(b, buf...)
+		// 末尾换行不算一行的分隔
+		if bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) >= n {
+			break
+		}
+	}
+	trimmed := bytes.TrimSuffix(buf, []byte("\n"))
+	for i := len(trimmed) - 1; i >= 0; i-- {
+		if trimmed[i] == '\n' {
+			n--
+			if n == 0 {
+				buf = buf[i+1:]
+				break
+			}
+		}
+	}
+	if len(buf) > 0 && buf[len(buf)-1] != '\n' {
+		buf = append(buf, '\n')
+	}
+	return buf, nil
+}
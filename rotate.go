@@ -0,0 +1,150 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// setActive 切换到新打开的当前日志文件，按文件现有大小和当前时间预先算好轮转阈值，调用方需持有锁
+func (w *FileWriter) setActive(file *os.File) {
+	w.file = file
+	w.writer = file
+	w.bufferActive()
+	w.size = 0
+	if fi, err := file.Stat(); err == nil {
+		w.size = fi.Size()
+	}
+	w.nextPeriod = w.pattern.next(w.now())
+	w.preallocate(file)
+}
+
+// maybeRotate 写入 n 字节前判断是否需要轮转：先按时间切换，再按大小轮转，调用方需持有锁。
+// 按大小和按时间的轮转都在写盘路径上持同一把锁完成，文件不会超过 maxSize 后再写一分钟；
+// 单次写入超过 maxSize 时仍整体写入新文件
+func (w *FileWriter) maybeRotate(n int) {
+	if !w.now().Before(w.nextPeriod) {
+		w.rotatePeriod()
+	}
+	if w.now().Before(w.retryAt) {
+		return
+	}
+	var err error
+	if w.overQuota(n) {
+		err = w.enforceQuota(n)
+	}
+	if err == nil && w.maxSize > 0 {
+		if w.lockFile != nil {
+			err = w.rotateShared(n)
+		} else if w.size > 0 && w.size+int64(n) > w.maxSize {
+			err = w.rotateFull(w.size)
+		}
+	}
+	if err != nil {
+		// 轮转失败时继续写原文件，一分钟后再试，避免每次写入都重命名失败并产生降级事件
+		w.retryAt = w.now().Add(time.Minute)
+	}
+}
+
+// rotatePeriod 切换到当前时段（默认为当天）的日志文件，调用方需持有锁
+func (w *FileWriter) rotatePeriod() {
+	path := w.pathAt(w.now())
+	if path == w.filePath {
+		// 模式中没有日期，文件名不变
+		w.nextPeriod = w.pattern.next(w.now())
+		return
+	}
+	file, err := w.openFile(path)
+	if err != nil {
+		w.degrade("daily_rotate_failed", fmt.Errorf("open file path:%s fail:%w", path, err))
+		// 推迟到下一分钟重试，避免每次写入都尝试打开
+		w.nextPeriod = w.now().Add(time.Minute)
+		return
+	}
+	w.syncBeforeClose()
+	w.trimPrealloc()
+	w.file.Close()
+	w.setActive(file)
+	old := w.filePath
+	w.filePath = path
+	w.prepareSpare()
+	w.watchActive()
+	w.rotated(old, path)
+	w.enforceBudget(0)
+}
+
+// rotateShared 多进程共享文件时其他进程的写入也计入大小，按文件实际大小判断，
+// 超限后升级为排他锁再确认一次，其他进程可能已经完成轮转；调用方需持有锁和共享文件锁
+func (w *FileWriter) rotateShared(n int) error {
+	fi, err := w.file.Stat()
+	if err != nil || fi.Size() == 0 || fi.Size()+int64(n) <= w.maxSize {
+		return nil
+	}
+	w.exclusiveLock()
+	if !w.followActive() {
+		if fi, err = w.file.Stat(); err == nil && fi.Size() > 0 {
+			err = w.rotateFull(fi.Size())
+		}
+	}
+	w.sharedLock()
+	return err
+}
+
+// Rotate 立即按 RotationPolicy 轮转当前日志文件，不管大小和时间；
+// 调用前已入队的日志先写入旧文件，当前文件为空时不轮转
+func (w *FileWriter) Rotate() error {
+	if err := w.Flush(exitTimeout); err != nil {
+		return err
+	}
+	w.lock()
+	defer w.mu.Unlock()
+	w.exclusiveLock()
+	defer w.unlockFile()
+	w.followActive()
+	fi, err := w.file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() == 0 {
+		return nil
+	}
+	return w.rotateFull(fi.Size())
+}
+
+// Rotate 同时轮转主文件和错误文件，返回第一个错误
+func (s *SplitWriter) Rotate() error {
+	err := s.main.Rotate()
+	if e := s.errs.Rotate(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// RotateAll 轮转所有 FileWriter，返回第一个错误
+func RotateAll() error {
+	var err error
+	for _, w := range registered() {
+		if e := w.Rotate(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// RotateHandler 运维接口，POST 请求时轮转所有 FileWriter，
+// 如 http.Handle("/debug/log/rotate", h2sanlog.RotateHandler())
+func RotateHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := RotateAll(); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(rw, "ok")
+	})
+}
@@ -0,0 +1,150 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errorLog 收集 onError 回调的错误
+type errorLog struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (e *errorLog) fn(err error) {
+	e.mu.Lock()
+	e.errs = append(e.errs, err)
+	e.mu.Unlock()
+}
+
+func (e *errorLog) get() []error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]error(nil), e.errs...)
+}
+
+// rotatedFiles 返回 .full.N.log 文件，按序号排序
+func rotatedFiles(t *testing.T, w *FileWriter) []string {
+	t.Helper()
+	var files []string
+	for _, f := range w.listDir() {
+		if _, ok := fullIndex(w.filePath, f); ok {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		a, _ := fullIndex(w.filePath, files[i])
+		b, _ := fullIndex(w.filePath, files[j])
+		return a < b
+	})
+	return files
+}
+
+func TestSizeRotation(t *testing.T) {
+	var errs errorLog
+	w := newTestWriter(t, 100, 0, WithOnError(errs.fn))
+	var want strings.Builder
+	for i := 0; i < 40; i++ {
+		line := fmt.Sprintf("line%04d\n", i)
+		want.WriteString(line)
+		w.Write([]byte(line))
+	}
+	w.Flush(time.Second)
+	files := rotatedFiles(t, w)
+	if len(files) < 3 {
+		t.Fatalf("rotated files = %v", files)
+	}
+	var got strings.Builder
+	for _, f := range append(files, w.filePath) {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 100 {
+			t.Fatalf("%s has %d bytes, over maxSize", f, len(b))
+		}
+		got.Write(b)
+	}
+	if got.String() != want.String() {
+		t.Fatalf("content across files differs:\n%s", got.String())
+	}
+	if e := errs.get(); len(e) > 0 {
+		t.Fatalf("errors: %v", e)
+	}
+}
+
+func TestSizeRotationWithoutTrailingNewline(t *testing.T) {
+	var errs errorLog
+	w := newTestWriter(t, 100, 0, WithOnError(errs.fn))
+	chunk := []byte(strings.Repeat("\x01", 40))
+	for i := 0; i < 10; i++ {
+		w.Write(chunk)
+	}
+	w.Flush(time.Second)
+	if files := rotatedFiles(t, w); len(files) < 3 {
+		t.Fatalf("binary output did not rotate: %v", files)
+	}
+	if e := errs.get(); len(e) > 0 {
+		t.Fatalf("rotation rolled back: %v", e)
+	}
+	w.mu.Lock()
+	size := w.size
+	w.mu.Unlock()
+	if size > 100 {
+		t.Fatalf("active file grew to %d", size)
+	}
+}
+
+// missingDirRotation 重命名到不存在的目录，每次轮转都失败
+type missingDirRotation struct{}
+
+func (missingDirRotation) RotateName(active string, existing []string) string {
+	return filepath.Join(filepath.Dir(active), "missing", "rotated.log")
+}
+
+func TestRotationRenameFailureKeepsWriting(t *testing.T) {
+	var errs errorLog
+	w := newTestWriter(t, 20, 0, WithOnError(errs.fn), WithRotationPolicy(missingDirRotation{}))
+	for i := 0; i < 10; i++ {
+		w.Write([]byte("0123456789\n"))
+	}
+	w.Flush(time.Second)
+	if got := strings.Count(activeContent(t, w), "0123456789\n"); got != 10 {
+		t.Fatalf("active file has %d lines, want 10", got)
+	}
+	// 失败后一分钟内不重试，只有一次重命名错误
+	n := 0
+	for _, err := range errs.get() {
+		if strings.Contains(err.Error(), "rename file") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("rename errors = %d, want 1: %v", n, errs.get())
+	}
+}
+
+func TestVerifyRotated(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "rotated.log")
+	if err := ioutil.WriteFile(name, []byte("no newline"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyRotated(name, 10); err != nil {
+		t.Fatalf("file without trailing newline: %v", err)
+	}
+	if err := verifyRotated(name, 11); err == nil {
+		t.Fatal("truncated file passed verification")
+	}
+	os.Remove(name)
+	if err := verifyRotated(name, 0); err == nil {
+		t.Fatal("missing file passed verification")
+	}
+}
@@ -0,0 +1,296 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Router 编译后的路由规则，按 Entry 选择输出的 sink
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	match func(e *Entry) bool
+	sink  string
+	w     io.Writer
+}
+
+// NewRouter 编译路由规则，sinks 为 sink 名到 writer 的映射，表达式语法错误或 sink 不存在时返回错误
+//
+// 表达式支持:
+//
+//	level, name, msg, fields.<key>
+//	== != >= > <= <    （level 按级别比较，其余按字符串比较，只支持 == !=）
+//	&& || ! ( )
+//	"字符串"、数字、级别名等裸词
+func NewRouter(routes []RouteConfig, sinks map[string]io.Writer) (*Router, error) {
+	r := &Router{}
+	for _, rc := range routes {
+		w, ok := sinks[rc.Sink]
+		if !ok {
+			return nil, fmt.Errorf("h2sanlog: route %q: unknown sink %q", rc.Match, rc.Sink)
+		}
+		match, err := compileMatch(rc.Match)
+		if err != nil {
+			return nil, err
+		}
+		r.routes = append(r.routes, route{match: match, sink: rc.Sink, w: w})
+	}
+	return r, nil
+}
+
+// Match 返回 entry 命中的所有 sink writer
+func (r *Router) Match(e *Entry) []io.Writer {
+	var ws []io.Writer
+	r.each(e, func(i int) { ws = append(ws, r.routes[i].w) })
+	return ws
+}
+
+// each 对 entry 命中的每条规则回调其下标，返回是否有命中
+func (r *Router) each(e *Entry, fn func(i int)) bool {
+	hit := false
+	for i, rt := range r.routes {
+		if rt.match(e) {
+			fn(i)
+			hit = true
+		}
+	}
+	return hit
+}
+
+// compileMatch 编译路由表达式，空表达式匹配所有日志
+func compileMatch(expr string) (func(e *Entry) bool, error) {
+	if strings.TrimSpace(expr) == "" {
+		return func(*Entry) bool { return true }, nil
+	}
+	toks, err := lexMatch(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &matchParser{expr: expr, toks: toks}
+	fn, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, p.errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return fn, nil
+}
+
+const (
+	tokIdent = iota
+	tokString
+	tokOp
+)
+
+type matchToken struct {
+	kind int
+	text string
+}
+
+// lexMatch 把表达式切分成 标识符/字符串/运算符
+func lexMatch(expr string) ([]matchToken, error) {
+	var toks []matchToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			s, err := strconv.QuotedPrefix(expr[i:])
+			if err != nil {
+				return nil, fmt.Errorf("h2sanlog: route %q: bad string at %d", expr, i)
+			}
+			v, _ := strconv.Unquote(s)
+			toks = append(toks, matchToken{tokString, v})
+			i += len(s)
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"),
+			strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="),
+			strings.HasPrefix(expr[i:], ">="), strings.HasPrefix(expr[i:], "<="):
+			toks = append(toks, matchToken{tokOp, expr[i : i+2]})
+			i += 2
+		case strings.IndexByte("!()<>", c) >= 0:
+			toks = append(toks, matchToken{tokOp, expr[i : i+1]})
+			i++
+		default:
+			j := i
+			for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j])) || strings.IndexByte("._-", expr[j]) >= 0) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("h2sanlog: route %q: unexpected %q at %d", expr, c, i)
+			}
+			toks = append(toks, matchToken{tokIdent, expr[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type matchParser struct {
+	expr string
+	toks []matchToken
+	pos  int
+}
+
+func (p *matchParser) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("h2sanlog: route %q: %s", p.expr, fmt.Sprintf(format, v...))
+}
+
+func (p *matchParser) peekOp(op string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == tokOp && p.toks[p.pos].text == op
+}
+
+func (p *matchParser) parseOr() (func(e *Entry) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Entry) bool { return l(e) || right(e) }
+	}
+	return left, nil
+}
+
+func (p *matchParser) parseAnd() (func(e *Entry) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Entry) bool { return l(e) && right(e) }
+	}
+	return left, nil
+}
+
+func (p *matchParser) parseUnary() (func(e *Entry) bool, error) {
+	if p.peekOp("!") {
+		p.pos++
+		fn, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry) bool { return !fn(e) }, nil
+	}
+	if p.peekOp("(") {
+		p.pos++
+		fn, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekOp(")") {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return fn, nil
+	}
+	return p.parseCompare()
+}
+
+// parseCompare 解析 operand op operand
+func (p *matchParser) parseCompare() (func(e *Entry) bool, error) {
+	if p.pos+3 > len(p.toks) {
+		return nil, p.errorf("incomplete comparison")
+	}
+	left, op, right := p.toks[p.pos], p.toks[p.pos+1], p.toks[p.pos+2]
+	if op.kind != tokOp || left.kind == tokOp || right.kind == tokOp {
+		return nil, p.errorf("expected comparison near %q", left.text)
+	}
+	p.pos += 3
+	if left.kind != tokIdent || !isMatchVar(left.text) {
+		// 允许把变量写在右边: warn <= level
+		left, right = right, left
+		op.text = flipOp(op.text)
+	}
+	if left.kind != tokIdent || !isMatchVar(left.text) {
+		return nil, p.errorf("comparison %q needs one of level, name, msg, fields.<key>", left.text)
+	}
+	if left.text == "level" {
+		lv, err := ParseLevel(right.text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		cmp, ok := levelCmp(op.text)
+		if !ok {
+			return nil, p.errorf("unknown operator %q", op.text)
+		}
+		return func(e *Entry) bool { return cmp(e.Level, lv) }, nil
+	}
+	if op.text != "==" && op.text != "!=" {
+		return nil, p.errorf("operator %q only supported on level", op.text)
+	}
+	get := matchGetter(left.text)
+	want, eq := right.text, op.text == "=="
+	return func(e *Entry) bool { return (get(e) == want) == eq }, nil
+}
+
+func isMatchVar(s string) bool {
+	return s == "level" || s == "name" || s == "msg" || strings.HasPrefix(s, "fields.")
+}
+
+func flipOp(op string) string {
+	switch op {
+	case ">":
+		return "<"
+	case "<":
+		return ">"
+	case ">=":
+		return "<="
+	case "<=":
+		return ">="
+	}
+	return op
+}
+
+func levelCmp(op string) (func(a, b uint8) bool, bool) {
+	switch op {
+	case "==":
+		return func(a, b uint8) bool { return a == b }, true
+	case "!=":
+		return func(a, b uint8) bool { return a != b }, true
+	case ">=":
+		return func(a, b uint8) bool { return a >= b }, true
+	case ">":
+		return func(a, b uint8) bool { return a > b }, true
+	case "<=":
+		return func(a, b uint8) bool { return a <= b }, true
+	case "<":
+		return func(a, b uint8) bool { return a < b }, true
+	}
+	return nil, false
+}
+
+// matchGetter 返回取 name/msg/字段值 字符串的函数，字段不存在时为空串
+func matchGetter(v string) func(e *Entry) string {
+	switch v {
+	case "name":
+		return func(e *Entry) string { return e.Name }
+	case "msg":
+		return func(e *Entry) string { return e.Message }
+	}
+	key := strings.TrimPrefix(v, "fields.")
+	return func(e *Entry) string {
+		val, ok := e.Field(key)
+		if !ok {
+			return ""
+		}
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,114 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompileMatch(t *testing.T) {
+	e := &Entry{Level: LogLevelWarning, Name: "db", Message: "slow query",
+		Fields: []Field{{Key: "table", Value: "users"}, {Key: "rows", Value: 42}}}
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"level == warning", true},
+		{"level >= ERROR", false},
+		{"level > info", true},
+		{"level < warning", false},
+		{"level <= 4", true},
+		{"level != warning", false},
+		{"warning <= level", true},
+		{"error > level", true},
+		{`name == "db"`, true},
+		{"name == db", true},
+		{"name != db", false},
+		{`msg == "slow query"`, true},
+		{"fields.table == users", true},
+		{"fields.rows == 42", true},
+		{`fields.missing == ""`, true},
+		{"name == db && level >= error", false},
+		{"name == api || level >= warning", true},
+		{"!(name == db)", false},
+		{"!name == api", true},
+		{"name == api || name == db && level == trace", false},
+		{"(name == api || name == db) && level == warning", true},
+	}
+	for _, c := range cases {
+		fn, err := compileMatch(c.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", c.expr, err)
+		}
+		if got := fn(e); got != c.want {
+			t.Fatalf("%q = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestCompileMatchErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		`name == "db`:             "bad string",
+		"name == db @":            "unexpected '@'",
+		"name ==":                 "incomplete comparison",
+		"name db":                 "incomplete comparison",
+		"name db x":               "expected comparison",
+		"foo == bar":              "needs one of level",
+		"level == loud":           "unknown level",
+		"name >= db":              "only supported on level",
+		"(name == db":             "missing )",
+		"name == db)":             "unexpected \")\"",
+		"name == db && ":          "incomplete comparison",
+		"level == warning || ! (": "incomplete comparison",
+	} {
+		_, err := compileMatch(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: err = %v, want %q", expr, err, want)
+		}
+	}
+}
+
+func TestRouterMatchOrder(t *testing.T) {
+	var errs, db, all bytes.Buffer
+	r, err := NewRouter([]RouteConfig{
+		{Match: "level >= error", Sink: "errs"},
+		{Match: "name == db", Sink: "db"},
+		{Match: "fields.audit == true", Sink: "all"},
+	}, map[string]io.Writer{"errs": &errs, "db": &db, "all": &all})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 命中的规则全部输出，按规则顺序
+	ws := r.Match(&Entry{Level: LogLevelError, Name: "db"})
+	if len(ws) != 2 || ws[0] != &errs || ws[1] != &db {
+		t.Fatalf("Match = %v", ws)
+	}
+	if ws := r.Match(&Entry{Level: LogLevelInfo, Name: "api"}); len(ws) != 0 {
+		t.Fatalf("Match = %v, want none", ws)
+	}
+	if _, err := NewRouter([]RouteConfig{{Match: "", Sink: "nope"}}, nil); err == nil ||
+		!strings.Contains(err.Error(), `unknown sink "nope"`) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// 没有规则命中的日志写入默认输出
+func TestLoggerRouterFallthrough(t *testing.T) {
+	var def, errs bytes.Buffer
+	r, err := NewRouter([]RouteConfig{{Match: "level >= error", Sink: "errs"}}, map[string]io.Writer{"errs": &errs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(&def, "", 0)
+	l.SetRouter(r)
+	l.Info("normal")
+	l.Error("broken")
+	if !strings.Contains(def.String(), "normal") || strings.Contains(def.String(), "broken") {
+		t.Fatalf("default = %q", def.String())
+	}
+	if !strings.Contains(errs.String(), "broken") || strings.Contains(errs.String(), "normal") {
+		t.Fatalf("errs = %q", errs.String())
+	}
+}
@@ -0,0 +1,39 @@
+package h2sanlog
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler 按消息采样：每个 tick 周期内同一消息前 first 条全部输出，之后每 thereafter 条输出一条
+type Sampler struct {
+	tick       time.Duration
+	first      int
+	thereafter int
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// NewSampler 新建采样器，thereafter<=0 表示超过 first 后全部丢弃
+func NewSampler(tick time.Duration, first, thereafter int) *Sampler {
+	return &Sampler{tick: tick, first: first, thereafter: thereafter, counts: make(map[string]int)}
+}
+
+// Sample 返回该条日志是否应该输出，按 logger名+消息 计数，周期到期后清零
+func (s *Sampler) Sample(e *Entry) bool {
+	key := e.Name + "\x00" + e.Message
+	s.mu.Lock()
+	if e.Time.Sub(s.start) >= s.tick {
+		s.start = e.Time
+		s.counts = make(map[string]int)
+	}
+	s.counts[key]++
+	n := s.counts[key]
+	s.mu.Unlock()
+	if n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
@@ -0,0 +1,81 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampled 返回 n 条同样的日志中被输出的序号，从 1 开始
+func sampled(s *Sampler, e *Entry, n int) []int {
+	var out []int
+	for i := 1; i <= n; i++ {
+		if s.Sample(e) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func TestSamplerFirstThereafter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := NewSampler(time.Second, 2, 3)
+	if got := sampled(s, &Entry{Time: now, Message: "a"}, 10); !intsEqual(got, []int{1, 2, 5, 8}) {
+		t.Fatalf("a = %v", got)
+	}
+	// 不同消息、不同 logger 分别计数
+	if got := sampled(s, &Entry{Time: now, Message: "b"}, 3); !intsEqual(got, []int{1, 2}) {
+		t.Fatalf("b = %v", got)
+	}
+	if got := sampled(s, &Entry{Time: now, Name: "x", Message: "a"}, 2); !intsEqual(got, []int{1, 2}) {
+		t.Fatalf("x/a = %v", got)
+	}
+	// 周期到期后重新计数
+	if got := sampled(s, &Entry{Time: now.Add(time.Second), Message: "a"}, 3); !intsEqual(got, []int{1, 2}) {
+		t.Fatalf("next tick = %v", got)
+	}
+}
+
+func TestSamplerDropAfterFirst(t *testing.T) {
+	s := NewSampler(time.Minute, 1, 0)
+	if got := sampled(s, &Entry{Time: time.Now(), Message: "a"}, 5); !intsEqual(got, []int{1}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestLoggerSampler(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetSampler(LogLevelInfo, NewSampler(time.Minute, 1, 0))
+	// 子logger共用采样计数，其他级别不采样
+	child := l.With("k", "v")
+	for i := 0; i < 3; i++ {
+		l.Info("hot")
+		child.Info("hot")
+		l.Warning("warn")
+	}
+	if n := strings.Count(buf.String(), "hot"); n != 1 {
+		t.Fatalf("hot written %d times: %q", n, buf.String())
+	}
+	if n := strings.Count(buf.String(), "warn"); n != 3 {
+		t.Fatalf("warn written %d times", n)
+	}
+	l.SetSampler(LogLevelInfo, nil)
+	l.Info("hot")
+	if n := strings.Count(buf.String(), "hot"); n != 2 {
+		t.Fatalf("hot written %d times after removing sampler", n)
+	}
+}
+
+func intsEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
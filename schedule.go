@@ -0,0 +1,117 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleWindow 一个时间段内生效的级别和采样策略，例如
+//
+//	{Start: "09:00", End: "18:00", Days: ["mon","tue","wed","thu","fri"], Level: "debug"}
+//	{Start: "22:00", End: "06:00", Level: "warn", SampleFirst: 10, SampleThereafter: 100}
+type ScheduleWindow struct {
+	// Start End 为 HH:MM，End 小于 Start 表示跨午夜
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+	// Days 生效的星期，mon..sun，为空表示每天；跨午夜时按开始那天算
+	Days []string `json:"days" yaml:"days"`
+	// Level 时间段内的最低级别，为空表示沿用 logger 的级别
+	Level string `json:"level" yaml:"level"`
+	// SampleFirst SampleThereafter 大于 0 时时间段内按每秒同一消息采样
+	SampleFirst      int `json:"sample_first" yaml:"sample_first"`
+	SampleThereafter int `json:"sample_thereafter" yaml:"sample_thereafter"`
+}
+
+// Schedule 编译后的时间段策略，按顺序取第一个命中的时间段
+type Schedule struct {
+	loc     *time.Location
+	windows []window
+}
+
+type window struct {
+	start, end int // 当天的分钟数
+	days       [7]bool
+	hasLevel   bool
+	level      uint8
+	sampler    *Sampler
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// NewSchedule 编译时间段策略，loc 为 nil 时使用本地时区
+func NewSchedule(windows []ScheduleWindow, loc *time.Location) (*Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &Schedule{loc: loc}
+	for _, sw := range windows {
+		var w window
+		var err error
+		if w.start, err = parseClock(sw.Start); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseClock(sw.End); err != nil {
+			return nil, err
+		}
+		if len(sw.Days) == 0 {
+			w.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		for _, d := range sw.Days {
+			key := strings.ToLower(d)
+			if len(key) > 3 {
+				key = key[:3]
+			}
+			wd, ok := weekdays[key]
+			if !ok {
+				return nil, fmt.Errorf("h2sanlog: schedule: unknown day %q", d)
+			}
+			w.days[wd] = true
+		}
+		if sw.Level != "" {
+			if w.level, err = ParseLevel(sw.Level); err != nil {
+				return nil, err
+			}
+			w.hasLevel = true
+		}
+		if sw.SampleFirst > 0 || sw.SampleThereafter > 0 {
+			w.sampler = NewSampler(time.Second, sw.SampleFirst, sw.SampleThereafter)
+		}
+		s.windows = append(s.windows, w)
+	}
+	return s, nil
+}
+
+// parseClock 解析 HH:MM 为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("h2sanlog: schedule: bad time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// active 返回 t 时刻命中的时间段，没有命中返回 nil
+func (s *Schedule) active(t time.Time) *window {
+	t = t.In(s.loc)
+	now := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+	for i := range s.windows {
+		w := &s.windows[i]
+		if w.start <= w.end {
+			if w.days[today] && now >= w.start && now < w.end {
+				return w
+			}
+			continue
+		}
+		// 跨午夜
+		if (w.days[today] && now >= w.start) || (w.days[yesterday] && now < w.end) {
+			return w
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// windowIndex 返回 t 时刻命中的时间段序号，没有命中返回 -1
+func windowIndex(s *Schedule, t time.Time) int {
+	w := s.active(t)
+	for i := range s.windows {
+		if w == &s.windows[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestScheduleActive(t *testing.T) {
+	s, err := NewSchedule([]ScheduleWindow{
+		{Start: "09:00", End: "18:00", Days: []string{"mon", "Tuesday", "WED", "thu", "fri"}, Level: "debug"},
+		{Start: "22:00", End: "06:00", Days: []string{"fri"}, Level: "warn"},
+		{Start: "12:00", End: "13:00", Level: "error"},
+	}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-03-06 是星期五
+	at := func(day int, hhmm string) time.Time {
+		c, _ := parseClock(hhmm)
+		return time.Date(2026, 3, 6+day, 0, c, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		at   time.Time
+		want int
+	}{
+		{at(0, "08:59"), -1},
+		{at(0, "09:00"), 0},
+		// 按顺序取第一个命中的时间段
+		{at(0, "12:30"), 0},
+		{at(0, "17:59"), 0},
+		{at(0, "18:00"), -1},
+		{at(0, "22:00"), 1},
+		{at(0, "05:00"), -1},
+		// 跨午夜的时间段按开始那天算，周六凌晨属于周五的时间段，周日凌晨不属于
+		{at(1, "05:59"), 1},
+		{at(1, "06:00"), -1},
+		{at(2, "01:00"), -1},
+		// 周末只有每天生效的时间段
+		{at(1, "12:30"), 2},
+		{at(1, "10:00"), -1},
+	}
+	for _, c := range cases {
+		if got := windowIndex(s, c.at); got != c.want {
+			t.Fatalf("%s %s: window %d, want %d", c.at.Weekday(), c.at.Format("15:04"), got, c.want)
+		}
+	}
+}
+
+func TestScheduleLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	s, err := NewSchedule([]ScheduleWindow{{Start: "09:00", End: "10:00"}}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := windowIndex(s, time.Date(2026, 3, 6, 1, 30, 0, 0, time.UTC)); got != 0 {
+		t.Fatalf("09:30 UTC+8 not matched")
+	}
+	if got := windowIndex(s, time.Date(2026, 3, 6, 9, 30, 0, 0, time.UTC)); got != -1 {
+		t.Fatalf("09:30 UTC matched")
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	for _, w := range []ScheduleWindow{
+		{Start: "9am", End: "18:00"},
+		{Start: "09:00", End: "24:00"},
+		{Start: "09:00", End: "18:00", Days: []string{"someday"}},
+		{Start: "09:00", End: "18:00", Level: "loud"},
+	} {
+		if _, err := NewSchedule([]ScheduleWindow{w}, nil); err == nil {
+			t.Fatalf("NewSchedule(%+v) succeeded", w)
+		}
+	}
+}
+
+// nowWindow 返回包含当前时刻的时间段
+func nowWindow() ScheduleWindow {
+	now := time.Now()
+	return ScheduleWindow{Start: now.Add(-time.Minute).Format("15:04"), End: now.Add(2 * time.Minute).Format("15:04")}
+}
+
+func TestLoggerSchedule(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetLevel(LogLevelError)
+	w := nowWindow()
+	w.Level, w.SampleFirst = "debug", 2
+	s, err := NewSchedule([]ScheduleWindow{w}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 时间段内的级别代替 SetLevel，同一消息每秒只输出前 2 条
+	l.SetSchedule(s)
+	l.Log(LogLevelDebug, "debug")
+	for i := 0; i < 5; i++ {
+		l.Log(LogLevelInfo, "hot")
+	}
+	if got := buf.String(); !strings.Contains(got, "debug") || strings.Count(got, "hot") != 2 {
+		t.Fatalf("in window got %q", got)
+	}
+	// 模块级别优先于时间段
+	resetModuleLevels(t)
+	SetModuleLevels("db=warn")
+	buf.Reset()
+	l.Named("db").Log(LogLevelInfo, "db info")
+	if buf.Len() != 0 {
+		t.Fatalf("module level ignored: %q", buf.String())
+	}
+	// 取消后按 SetLevel 过滤
+	l.SetSchedule(nil)
+	l.Log(LogLevelInfo, "info")
+	if buf.Len() != 0 {
+		t.Fatalf("outside window got %q", buf.String())
+	}
+}
@@ -0,0 +1,45 @@
+package h2sanlog
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// shared 按 fileName 绝对路径共享的 FileWriter，避免多个 logger 配置同一路径时
+// 启动多套轮转goroutine争抢同一个文件
+var shared = struct {
+	sync.Mutex
+	writers map[string]*FileWriter
+}{writers: make(map[string]*FileWriter)}
+
+// sharedKey 返回 fileName 的去重key
+func sharedKey(fileName string) string {
+	if abs, err := filepath.Abs(fileName); err == nil {
+		return abs
+	}
+	return filepath.Clean(fileName)
+}
+
+// acquireShared 返回已存在的同路径 writer 并增加引用计数，不存在返回 nil
+func acquireShared(key string) *FileWriter {
+	w := shared.writers[key]
+	if w != nil {
+		w.refs++
+	}
+	return w
+}
+
+// releaseShared 减少引用计数，返回是否已是最后一个引用需要真正关闭
+func releaseShared(w *FileWriter) bool {
+	shared.Lock()
+	defer shared.Unlock()
+	if w.refs > 1 {
+		w.refs--
+		return false
+	}
+	w.refs = 0
+	if shared.writers[w.key] == w {
+		delete(shared.writers, w.key)
+	}
+	return true
+}
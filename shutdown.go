@@ -0,0 +1,62 @@
+package h2sanlog
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// CloseAll 写完并关闭所有 FileWriter 和 NewFromConfig 创建的自定义 Sink，同路径共享的 writer 会释放全部引用，返回第一个错误
+func CloseAll() error {
+	FlushAll(exitTimeout)
+	var err error
+	for _, s := range trackedSinks(true) {
+		if e := s.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, w := range registered() {
+		for atomic.LoadInt32(&w.closed) == 0 {
+			if e := w.Close(); e != nil {
+				if e != ErrClosed && err == nil {
+					err = e
+				}
+				break
+			}
+		}
+	}
+	return err
+}
+
+// HandleShutdownSignals 收到 SIGTERM/SIGINT 时用 l 记录一条日志（l 可为 nil），关闭所有 FileWriter 和自定义 Sink 后
+// 以 128+信号值（143/130）调用退出函数，避免容器发布时丢失最后几秒的日志。返回的 stop 取消监听；
+// 应用自己处理信号做优雅退出时不要使用，改为在退出流程最后调用 CloseAll
+func HandleShutdownSignals(l *Logger) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-ch:
+			signal.Stop(ch)
+			if l != nil {
+				l.Warning("received signal ", sig, ", flushing logs and exiting")
+			}
+			CloseAll()
+			code := 128 + 15
+			if sig == os.Interrupt {
+				code = 128 + 2
+			}
+			exitFunc(code)
+		case <-done:
+		}
+	}()
+	var once int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&once, 0, 1) {
+			signal.Stop(ch)
+			close(done)
+		}
+	}
+}
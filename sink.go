@@ -0,0 +1,119 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink 日志输出目的地，FileWriter、SplitWriter 实现该接口；第三方实现后通过 RegisterSink 注册，
+// 即可在 Config 的 sinks 中按 type 引用，无需修改本包。不支持轮转的 Sink 的 Rotate 直接返回 nil
+type Sink interface {
+	io.Writer
+	// Flush 等待已写入的日志写出，最多等 timeout
+	Flush(timeout time.Duration) error
+	Rotate() error
+	Close() error
+}
+
+// SinkFactory 按配置创建 Sink，自定义参数在 sc.Options 中，编码由 sc.Encoder 在 Sink 外层完成
+type SinkFactory func(sc SinkConfig) (Sink, error)
+
+var sinkFactories = struct {
+	sync.RWMutex
+	m map[string]SinkFactory
+}{m: make(map[string]SinkFactory)}
+
+// RegisterSink 注册 typ 类型的 Sink，如 RegisterSink("kafka", newKafkaSink)，一般在 init 中调用；
+// 类型名不区分大小写，重复注册时覆盖之前的，可以替换内置的 file、stdout、stderr
+func RegisterSink(typ string, f SinkFactory) {
+	sinkFactories.Lock()
+	sinkFactories.m[strings.ToLower(typ)] = f
+	sinkFactories.Unlock()
+}
+
+func lookupSink(typ string) (SinkFactory, bool) {
+	sinkFactories.RLock()
+	defer sinkFactories.RUnlock()
+	f, ok := sinkFactories.m[typ]
+	return f, ok
+}
+
+func init() {
+	RegisterSink("stdout", func(SinkConfig) (Sink, error) { return stdSink{os.Stdout}, nil })
+	RegisterSink("stderr", func(SinkConfig) (Sink, error) { return stdSink{os.Stderr}, nil })
+	RegisterSink("file", openFileSink)
+}
+
+// stdSink 标准输出和标准错误，直接写入，不轮转也不关闭
+type stdSink struct{ *os.File }
+
+func (stdSink) Flush(time.Duration) error { return nil }
+
+func (stdSink) Rotate() error { return nil }
+
+func (stdSink) Close() error { return nil }
+
+// WriteSync 实现 SyncWriter，见 writeSync
+func (s stdSink) WriteSync(p []byte) (int, error) { return writeSync(s.File, p) }
+
+// openFileSink 内置的 file 类型
+func openFileSink(sc SinkConfig) (Sink, error) {
+	if sc.Path == "" {
+		return nil, fmt.Errorf("file sink without path")
+	}
+	var opts []FileOption
+	if sc.DailyDirs {
+		opts = append(opts, WithDailyDirs())
+	}
+	if sc.DateDirs {
+		opts = append(opts, WithDateDirs())
+	}
+	if sc.UTC {
+		opts = append(opts, WithUTC())
+	}
+	if sc.Pattern != "" {
+		opts = append(opts, WithFilePattern(sc.Pattern))
+	}
+	if sc.MaxTotalSize > 0 {
+		opts = append(opts, WithMaxTotalSize(sc.MaxTotalSize))
+	}
+	return NewFileWriter(sc.Path, sc.MaxSize, sc.MaxNum, opts...)
+}
+
+// openedSinks NewFromConfig 创建的自定义 Sink，FlushAll、CloseAll 时与 FileWriter 一起刷新和关闭；
+// FileWriter、SplitWriter 已在 registry 中，stdout、stderr 无需处理，都不在这里
+// 用切片保存，第三方 Sink 的动态类型不一定可以作为 map 的键
+var openedSinks struct {
+	sync.Mutex
+	sinks []Sink
+}
+
+func trackSink(s Sink) {
+	switch s.(type) {
+	case *FileWriter, *SplitWriter, stdSink:
+		return
+	}
+	openedSinks.Lock()
+	openedSinks.sinks = append(openedSinks.sinks, s)
+	openedSinks.Unlock()
+}
+
+// trackedSinks 返回自定义 Sink 的快照，take 为 true 时同时取消跟踪
+func trackedSinks(take bool) []Sink {
+	openedSinks.Lock()
+	defer openedSinks.Unlock()
+	sinks := append([]Sink(nil), openedSinks.sinks...)
+	if take {
+		openedSinks.sinks = nil
+	}
+	return sinks
+}
+
+var (
+	_ Sink = (*FileWriter)(nil)
+	_ Sink = (*SplitWriter)(nil)
+)
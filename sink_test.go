@@ -0,0 +1,122 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memSink 记录 Flush、Close 次数的自定义 Sink
+type memSink struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	pending bytes.Buffer
+	topic   interface{}
+	flushes int
+	closes  int
+}
+
+func (m *memSink) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pending.Write(p)
+}
+
+func (m *memSink) Flush(time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushes++
+	m.pending.WriteTo(&m.buf)
+	return nil
+}
+
+func (m *memSink) Rotate() error { return nil }
+
+func (m *memSink) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closes++
+	return nil
+}
+
+func (m *memSink) counts() (string, int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buf.String(), m.flushes, m.closes
+}
+
+// registerMemSink 注册 typ 类型，返回每次创建的 memSink
+func registerMemSink(typ string) *[]*memSink {
+	var created []*memSink
+	RegisterSink(typ, func(sc SinkConfig) (Sink, error) {
+		s := &memSink{topic: sc.Options["topic"]}
+		created = append(created, s)
+		return s, nil
+	})
+	return &created
+}
+
+func TestRegisterSinkFromConfig(t *testing.T) {
+	created := registerMemSink("MemTest")
+	l, err := NewFromConfig(&Config{Output: "m", Sinks: map[string]SinkConfig{
+		"m": {Type: "memtest", Encoder: "json", Options: map[string]interface{}{"topic": "t1"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseAll()
+	l.Info("hello")
+	s := (*created)[0]
+	if s.topic != "t1" {
+		t.Fatalf("options not passed: %v", s.topic)
+	}
+	s.Flush(0)
+	if got, _, _ := s.counts(); !strings.Contains(got, `"msg":"hello"`) {
+		t.Fatalf("got %q", got)
+	}
+
+	_, err = NewFromConfig(&Config{Sinks: map[string]SinkConfig{"x": {Type: "nope"}}})
+	if err == nil || !strings.Contains(err.Error(), `unknown sink type "nope"`) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestCustomSinkFlushAllCloseAll(t *testing.T) {
+	created := registerMemSink("memexit")
+	l, err := NewFromConfig(&Config{Output: "m", Sinks: map[string]SinkConfig{"m": {Type: "memexit"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := (*created)[0]
+	l.Info("before exit")
+	if err := FlushAll(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	got, flushes, _ := s.counts()
+	if flushes != 1 || !strings.Contains(got, "before exit") {
+		t.Fatalf("FlushAll: flushes = %d, content %q", flushes, got)
+	}
+	if err := CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+	CloseAll()
+	if _, _, closes := s.counts(); closes != 1 {
+		t.Fatalf("closes = %d, want 1", closes)
+	}
+}
+
+func TestCustomSinkClosedOnConfigError(t *testing.T) {
+	created := registerMemSink("memfail")
+	_, err := NewFromConfig(&Config{Output: "missing", Sinks: map[string]SinkConfig{"m": {Type: "memfail"}}})
+	if err == nil {
+		t.Fatal("want error for unknown output")
+	}
+	if _, _, closes := (*created)[0].counts(); closes != 1 {
+		t.Fatalf("closes = %d, want 1", closes)
+	}
+	if n := len(trackedSinks(false)); n != 0 {
+		t.Fatalf("%d sinks tracked after failed config", n)
+	}
+}
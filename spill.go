@@ -0,0 +1,142 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// spillChunk 回放溢出文件时每次读取的字节数
+const spillChunk = 64 << 10
+
+// spiller 队列写满时的磁盘溢出缓冲
+type spiller struct {
+	mu     sync.Mutex
+	path   string
+	max    int64
+	file   *os.File
+	size   int64
+	active int32
+}
+
+// WithSpill 队列满时不丢日志，改为追加到 dir 下的 <文件名>.spill，消费者追上（队列排空）、
+// Flush 或 Close 时按顺序回放到日志文件。溢出期间新日志也写入溢出文件以保持顺序，
+// 溢出文件超过 maxBytes 后仍然丢弃，maxBytes<=0 不限制。进程崩溃留下的溢出文件在下次启动时回放
+func WithSpill(dir string, maxBytes int64) FileOption {
+	return func(w *FileWriter) {
+		w.spill = &spiller{path: filepath.Join(dir, filepath.Base(w.fileName)+".spill"), max: maxBytes}
+	}
+}
+
+// openSpill 打开溢出文件，回放上次遗留的内容，调用方需保证消费者尚未启动
+func (w *FileWriter) openSpill() error {
+	s := w.spill
+	if err := os.MkdirAll(filepath.Dir(s.path), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		s.size = fi.Size()
+		w.drainSpill()
+	}
+	return nil
+}
+
+// trySpill 溢出中或 start 为 true 时把 buf 追加到溢出文件，返回是否已处理
+func (w *FileWriter) trySpill(buf *[]byte, start bool) (bool, int, error) {
+	s := w.spill
+	if s == nil || (!start && atomic.LoadInt32(&s.active) == 0) {
+		return false, 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !start && s.active == 0 {
+		//消费者已回放完，回到正常入队
+		return false, 0, nil
+	}
+	n := len(*buf)
+	defer func() {
+		releaseMem(n)
+		putBuf(buf)
+	}()
+	if s.max > 0 && s.size+int64(n) > s.max {
+		atomic.AddUint64(&w.counters.dropped, 1)
+		w.startDropping()
+		return true, 0, ErrQueueFull
+	}
+	if _, err := s.file.Write(*buf); err != nil {
+		atomic.AddUint64(&w.counters.dropped, 1)
+		w.onError(fmt.Errorf("spill file path:%s fail:%w", s.path, err))
+		return true, 0, ErrQueueFull
+	}
+	s.size += int64(n)
+	atomic.StoreInt32(&s.active, 1)
+	return true, n, nil
+}
+
+// checkSpill 消费者写盘后调用，队列中更早的日志都已写完时回放溢出文件
+func (w *FileWriter) checkSpill() {
+	if w.spill == nil || atomic.LoadInt32(&w.spill.active) == 0 || w.queue.len() > 0 {
+		return
+	}
+	w.drainSpill()
+}
+
+// drainSpill 把溢出文件按行边界分块写入日志文件后清空，回放期间写入方等待
+func (w *FileWriter) drainSpill() {
+	s := w.spill
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size == 0 {
+		atomic.StoreInt32(&s.active, 0)
+		return
+	}
+	chunk := make([]byte, spillChunk)
+	var carry []byte
+	for off := int64(0); off < s.size; {
+		n, err := s.file.ReadAt(chunk, off)
+		off += int64(n)
+		p := append(carry, chunk[:n]...)
+		if i := bytes.LastIndexByte(p, '\n'); i >= 0 {
+			w.writeFile(p[:i+1], bytes.Count(p[:i+1], []byte{'\n'}), nil)
+			carry = append([]byte(nil), p[i+1:]...)
+		} else {
+			carry = p
+		}
+		if err != nil {
+			break
+		}
+	}
+	if len(carry) > 0 {
+		w.writeFile(carry, 1, nil)
+	}
+	if err := s.file.Truncate(0); err != nil {
+		w.onError(fmt.Errorf("truncate spill file path:%s fail:%w", s.path, err))
+	}
+	s.size = 0
+	atomic.StoreInt32(&s.active, 0)
+}
+
+// closeSpill 关闭并删除已回放完的溢出文件
+func (w *FileWriter) closeSpill() {
+	s := w.spill
+	if s == nil || s.file == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.Close()
+	if s.size == 0 {
+		os.Remove(s.path)
+	}
+}
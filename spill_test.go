@@ -0,0 +1,108 @@
+package h2sanlog
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spillLines 生成 line-<from> 到 line-<to-1>
+func spillLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&b, "line-%02d\n", i)
+	}
+	return b.String()
+}
+
+// blockConsumer 持有 writer 锁让消费者停在写盘前，写满队列后开始溢出
+func blockConsumer(t *testing.T, w *FileWriter) {
+	t.Helper()
+	w.mu.Lock()
+	w.Write([]byte("line-00\n"))
+	waitFor(t, "consumer blocked", func() bool { return w.queue.len() == 0 })
+}
+
+func TestSpillReplayOrder(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, 0, 0, WithRingBuffer(2), WithSpill(dir, 0))
+	blockConsumer(t, w)
+	for i := 1; i < 20; i++ {
+		if _, err := w.Write([]byte(fmt.Sprintf("line-%02d\n", i))); err != nil {
+			w.mu.Unlock()
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	// 队列中的两条之后的日志都在溢出文件里
+	b, _ := ioutil.ReadFile(w.spill.path)
+	w.mu.Unlock()
+	if string(b) != spillLines(3, 20) {
+		t.Fatalf("spill file = %q", b)
+	}
+	// 回放后溢出结束，再写入的日志排在回放的日志之后
+	w.Flush(time.Second)
+	w.Write([]byte("line-20\n"))
+	w.Flush(time.Second)
+	if got := activeContent(t, w); got != spillLines(0, 21) {
+		t.Fatalf("got %q", got)
+	}
+	if st := w.Stats(); st.Dropped != 0 {
+		t.Fatalf("Dropped = %d", st.Dropped)
+	}
+}
+
+func TestSpillMaxBytes(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithRingBuffer(2), WithSpill(t.TempDir(), 16),
+		WithOnError(func(error) {}))
+	blockConsumer(t, w)
+	var errs []error
+	for i := 1; i < 6; i++ {
+		_, err := w.Write([]byte(fmt.Sprintf("line-%02d\n", i)))
+		errs = append(errs, err)
+	}
+	w.mu.Unlock()
+	// 两条入队，两条溢出，第三条超过 16 字节被丢弃
+	for i, err := range errs {
+		if want := i == 4; errors.Is(err, ErrQueueFull) != want {
+			t.Fatalf("write %d: err = %v", i+1, err)
+		}
+	}
+	w.Flush(time.Second)
+	// 开始丢弃时写入 event=drop
+	var logs []string
+	for _, line := range strings.SplitAfter(activeContent(t, w), "\n") {
+		if !strings.Contains(line, "event=drop") {
+			logs = append(logs, line)
+		}
+	}
+	if got := strings.Join(logs, ""); got != spillLines(0, 5) {
+		t.Fatalf("got %q", got)
+	}
+	if st := w.Stats(); st.Dropped != 1 {
+		t.Fatalf("Dropped = %d, want 1", st.Dropped)
+	}
+}
+
+// 上次进程留下的溢出文件在启动时先于新日志写入
+func TestSpillReplayOnStart(t *testing.T) {
+	spillDir := t.TempDir()
+	path := filepath.Join(spillDir, "app.spill")
+	if err := ioutil.WriteFile(path, []byte("old-1\nold-2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWriter(t, 0, 0, WithSpill(spillDir, 0))
+	w.Write([]byte("new\n"))
+	w.Flush(time.Second)
+	if got := activeContent(t, w); got != "old-1\nold-2\nnew\n" {
+		t.Fatalf("got %q", got)
+	}
+	w.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("spill file not removed after replay: %v", err)
+	}
+}
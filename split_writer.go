@@ -0,0 +1,119 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"log"
+)
+
+// SplitWriter 按级别拆分日志文件：splitLevel 及以上级别写入 <fileName>.error.<日期>.log，
+// 其余写入 <fileName>.<日期>.log，两个文件使用相同的轮转配置，方便值班只 tail 错误日志
+type SplitWriter struct {
+	main       *FileWriter
+	errs       *FileWriter
+	splitLevel uint8
+	enc        Encoder
+}
+
+// NewSplitWriter 新建按级别拆分的writer，splitLevel 通常为 LogLevelError，
+// enc 为 nil 时使用 TextEncoder{Flag: log.LstdFlags}
+func NewSplitWriter(fileName string, maxSize int64, maxNum int, splitLevel uint8, enc Encoder, opts ...FileOption) (*SplitWriter, error) {
+	main, err := NewFileWriter(fileName, maxSize, maxNum, opts...)
+	if err != nil {
+		return nil, err
+	}
+	errs, err := NewFileWriter(fileName+".error", maxSize, maxNum, opts...)
+	if err != nil {
+		main.Close()
+		return nil, err
+	}
+	if enc == nil {
+		enc = TextEncoder{Flag: log.LstdFlags}
+	}
+	return &SplitWriter{main: main, errs: errs, splitLevel: splitLevel, enc: enc}, nil
+}
+
+// Main 返回普通级别日志的 FileWriter
+func (s *SplitWriter) Main() *FileWriter { return s.main }
+
+// Errors 返回高级别日志的 FileWriter
+func (s *SplitWriter) Errors() *FileWriter { return s.errs }
+
+// WriteEntry 按级别选择文件写入
+func (s *SplitWriter) WriteEntry(e *Entry) error {
+	data, err := s.enc.Encode(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.writerFor(e.Level).Write(data)
+	return err
+}
+
+// Write 供包级函数和标准库 log 使用，从行首的 [LEVEL] 标签识别级别
+func (s *SplitWriter) Write(p []byte) (int, error) {
+	return s.writerFor(sniffLevel(p)).Write(p)
+}
+
+// sinkError 返回两个文件中最近的写盘错误，见 FileWriter.sinkError
+func (s *SplitWriter) sinkError() error {
+	if err := s.main.sinkError(); err != nil {
+		return err
+	}
+	return s.errs.sinkError()
+}
+
+func (s *SplitWriter) writerFor(level uint8) *FileWriter {
+	if s.splitLevel != LogLevelNull && level >= s.splitLevel {
+		return s.errs
+	}
+	return s.main
+}
+
+// sniffLevel 在一行的前 64 字节中查找 [LEVEL] 标签，JSON 行查找 level 字段，找不到返回 LogLevelNull
+func sniffLevel(p []byte) uint8 {
+	if len(p) > 0 && p[0] == '{' {
+		if lv := sniffJSONLevel(p); lv != LogLevelNull {
+			return lv
+		}
+	}
+	if len(p) > 64 {
+		p = p[:64]
+	}
+	i := bytes.IndexByte(p, '[')
+	for i >= 0 {
+		p = p[i+1:]
+		j := bytes.IndexByte(p, ']')
+		if j < 0 {
+			break
+		}
+		for lv := LogLevelTrace; lv <= LogLevelFatal; lv++ {
+			if string(p[:j]) == levelNames[lv] {
+				return uint8(lv)
+			}
+		}
+		i = bytes.IndexByte(p, '[')
+	}
+	return LogLevelNull
+}
+
+// sniffJSONLevel 在 JSON 行的前 128 字节中查找 "level":"LEVEL"，JSONEncoder 把 level 放在 time 之后
+func sniffJSONLevel(p []byte) uint8 {
+	if len(p) > 128 {
+		p = p[:128]
+	}
+	key := []byte(`"level":"`)
+	i := bytes.Index(p, key)
+	if i < 0 {
+		return LogLevelNull
+	}
+	p = p[i+len(key):]
+	j := bytes.IndexByte(p, '"')
+	if j < 0 {
+		return LogLevelNull
+	}
+	for lv := LogLevelTrace; lv <= LogLevelFatal; lv++ {
+		if string(p[:j]) == levelNames[lv] {
+			return uint8(lv)
+		}
+	}
+	return LogLevelNull
+}
@@ -0,0 +1,26 @@
+package h2sanlog
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// 创建 .error 文件的 writer 失败时关闭已创建的主 writer
+func TestSplitWriterClosesMainOnError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app")
+	other, err := NewFileWriter(name+".error", 1<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := NewSplitWriter(name, 1<<10, 0, LogLevelError, nil); !errors.Is(err, ErrSharedConflict) {
+		t.Fatalf("err = %v, want ErrSharedConflict", err)
+	}
+	// 主 writer 已关闭并移出共享表，可以用不同的参数重新打开
+	main, err := NewFileWriter(name, 1<<20, 0)
+	if err != nil {
+		t.Fatalf("main writer still open: %v", err)
+	}
+	main.Close()
+}
@@ -0,0 +1,49 @@
+package h2sanlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats FileWriter 运行统计
+type Stats struct {
+	// QueueLen 队列中等待写盘的日志条数，QueueCap 队列容量
+	QueueLen int
+	QueueCap int
+	// Written 已写盘的日志条数，Dropped 因队列满或内存预算被丢弃的条数
+	Written uint64
+	Dropped uint64
+
+	// 以下字段只在 -tags h2sanlog_profile 编译时统计
+	// LockWaits 获取 writer 锁的次数，LockWait 累计等待时间
+	LockWaits uint64
+	LockWait  time.Duration
+	// Sends 写入队列的次数，SendWait 累计耗时
+	Sends    uint64
+	SendWait time.Duration
+}
+
+// counters FileWriter 内部计数，均为原子操作
+type counters struct {
+	written    uint64
+	dropped    uint64
+	lockWaits  uint64
+	lockWaitNs int64
+	sends      uint64
+	sendWaitNs int64
+}
+
+// Stats 返回当前统计快照
+func (w *FileWriter) Stats() Stats {
+	c := &w.counters
+	return Stats{
+		QueueLen:  w.queue.len(),
+		QueueCap:  w.queue.cap(),
+		Written:   atomic.LoadUint64(&c.written),
+		Dropped:   atomic.LoadUint64(&c.dropped),
+		LockWaits: atomic.LoadUint64(&c.lockWaits),
+		LockWait:  time.Duration(atomic.LoadInt64(&c.lockWaitNs)),
+		Sends:     atomic.LoadUint64(&c.sends),
+		SendWait:  time.Duration(atomic.LoadInt64(&c.sendWaitNs)),
+	}
+}
@@ -0,0 +1,65 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"log"
+)
+
+// stdBridge 把标准库 log.Logger 的输出转为 Logger 的日志
+type stdBridge struct {
+	l *Logger
+	// tagged 写带 [LEVEL] 标签的日志，本包的包级函数多经过一层 log.Output，转发默认 logger 时多跳过一层
+	tagged *Logger
+	level  uint8
+}
+
+// Write 每次调用为一条日志，去掉末尾换行；行首有 [LEVEL] 标签（如本包的 Info 等包级函数）时按标签的级别写入并去掉标签
+func (b stdBridge) Write(p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\n")
+	l, level := b.l, b.level
+	if len(msg) > 0 && msg[0] == '[' {
+		for lv := LogLevelTrace; lv <= LogLevelFatal; lv++ {
+			tag := "[" + levelNames[lv] + "] "
+			if bytes.HasPrefix(msg, []byte(tag)) {
+				l, level, msg = b.tagged, uint8(lv), msg[len(tag):]
+				break
+			}
+		}
+	}
+	if err := l.output(level, string(msg), false, nil, nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// stdBridgeSkip 业务代码经 log.Printf 等到 stdBridge.Write 多出的栈层数
+const stdBridgeSkip = 2
+
+// AsStdLogger 返回写入 l 的标准库 *log.Logger，每次 Print 以 level 级别写一条日志，供只接受 *log.Logger 的第三方库使用，
+// 如 http.Server.ErrorLog；时间、调用位置等由 l 输出，返回的 log.Logger 不带前缀和 flag
+func (l *Logger) AsStdLogger(level uint8) *log.Logger {
+	return log.New(l.stdBridge(level, 0), "", 0)
+}
+
+// RedirectStdLog 把标准库默认 logger（log.Print 等）的输出转到 l，以 level 级别写入，返回恢复原输出、前缀和 flag 的函数。
+// 本包的 Info 等包级函数也写标准库默认 logger，转发后按其 [LEVEL] 标签的级别写入；注意 l 本身不能输出到标准库默认 logger
+func (l *Logger) RedirectStdLog(level uint8) func() {
+	out, prefix, flags := log.Writer(), log.Prefix(), log.Flags()
+	log.SetOutput(l.stdBridge(level, 1))
+	log.SetPrefix("")
+	log.SetFlags(0)
+	return func() {
+		log.SetOutput(out)
+		log.SetPrefix(prefix)
+		log.SetFlags(flags)
+	}
+}
+
+// stdBridge tagSkip 为带 [LEVEL] 标签的日志额外跳过的栈层数
+func (l *Logger) stdBridge(level uint8, tagSkip int) stdBridge {
+	c := l.clone()
+	c.skip += stdBridgeSkip
+	t := l.clone()
+	t.skip += stdBridgeSkip + tagSkip
+	return stdBridge{l: c, tagged: t, level: level}
+}
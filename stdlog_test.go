@@ -0,0 +1,72 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestAsStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetCaller(true, 0)
+	std := l.AsStdLogger(LogLevelWarning)
+	std.Printf("tls handshake error from %s", "1.2.3.4")
+	std.Print("[ERROR] tagged")
+	// 低于 logger 级别的照常过滤
+	l.SetLevel(LogLevelError)
+	l.AsStdLogger(LogLevelWarning).Print("filtered")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "[WARNING]") || !strings.HasSuffix(lines[0], "tls handshake error from 1.2.3.4") {
+		t.Fatalf("line 0 = %q", lines[0])
+	}
+	// 按行首标签的级别写入并去掉标签
+	if !strings.HasPrefix(lines[1], "[ERROR]") || strings.Count(lines[1], "[ERROR]") != 1 {
+		t.Fatalf("line 1 = %q", lines[1])
+	}
+	// 调用位置为调用 Print 的地方
+	for _, line := range lines {
+		if !strings.Contains(line, "stdlog_test.go:") {
+			t.Fatalf("caller missing: %q", line)
+		}
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	out, prefix, flags := log.Writer(), log.Prefix(), log.Flags()
+	defer func() {
+		log.SetOutput(out)
+		log.SetPrefix(prefix)
+		log.SetFlags(flags)
+	}()
+	var std bytes.Buffer
+	log.SetOutput(&std)
+	log.SetPrefix("app: ")
+	log.SetFlags(log.Lshortfile)
+	var buf bytes.Buffer
+	l := New(&buf, "", 0)
+	l.SetCaller(true, 0)
+	restore := l.RedirectStdLog(LogLevelInfo)
+	log.Print("from std")
+	// 本包的包级函数按其标签的级别转发
+	Warning("package %s", "warning")
+	restore()
+	log.Print("restored")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "[INFO]") || !strings.HasSuffix(lines[0], "from std") ||
+		!strings.HasPrefix(lines[1], "[WARNING]") || !strings.HasSuffix(lines[1], "package warning") {
+		t.Fatalf("got %q", buf.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "stdlog_test.go:") {
+			t.Fatalf("caller missing: %q", line)
+		}
+	}
+	if got := std.String(); !strings.HasPrefix(got, "app: stdlog_test.go:") || !strings.HasSuffix(got, "restored\n") {
+		t.Fatalf("std after restore = %q", got)
+	}
+}
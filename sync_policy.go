@@ -0,0 +1,111 @@
+package h2sanlog
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	syncNever = iota
+	syncEveryWrite
+	syncEveryN
+	syncInterval
+)
+
+// SyncPolicy 写盘后 fsync 的策略，默认不主动 fsync，由操作系统决定何时落盘
+type SyncPolicy struct {
+	mode int
+	n    int
+	d    time.Duration
+}
+
+// SyncNever 不主动 fsync
+func SyncNever() SyncPolicy { return SyncPolicy{mode: syncNever} }
+
+// SyncEveryWrite 每次写盘后 fsync，批量写时一个批次 fsync 一次
+func SyncEveryWrite() SyncPolicy { return SyncPolicy{mode: syncEveryWrite} }
+
+// SyncEveryN 每写 n 条日志 fsync 一次
+func SyncEveryN(n int) SyncPolicy { return SyncPolicy{mode: syncEveryN, n: n} }
+
+// SyncEvery 每隔 d fsync 一次
+func SyncEvery(d time.Duration) SyncPolicy { return SyncPolicy{mode: syncInterval, d: d} }
+
+// WithSyncPolicy 设置 fsync 策略，避免机器宕机时丢失只在 page cache 里的日志
+func WithSyncPolicy(p SyncPolicy) FileOption {
+	return func(w *FileWriter) {
+		w.syncPolicy = p
+	}
+}
+
+// afterWrite 写盘 n 条日志后按策略 fsync，调用方需持有锁
+func (w *FileWriter) afterWrite(n int) {
+	switch w.syncPolicy.mode {
+	case syncEveryWrite:
+		w.syncFile()
+	case syncEveryN:
+		w.unsynced += n
+		if w.unsynced >= w.syncPolicy.n {
+			w.syncFile()
+		}
+	case syncInterval:
+		w.unsynced += n
+	}
+}
+
+// WithFlushOnError 写入 Error、Fatal 级别的日志时立即写出当前批次并 fsync，
+// 批量间隔较长或 fsync 策略较松时，崩溃前最关键的几行也已落盘；级别按行首的 [LEVEL] 标签或 JSON 的 level 字段识别
+func WithFlushOnError() FileOption {
+	return func(w *FileWriter) {
+		w.flushOnError = true
+	}
+}
+
+// urgent 开启 WithFlushOnError 时 p 是否为需要立即落盘的日志
+func (w *FileWriter) urgent(p []byte) bool {
+	return w.flushOnError && sniffLevel(p) >= LogLevelError
+}
+
+// syncNow 持锁 fsync 当前文件
+func (w *FileWriter) syncNow() {
+	w.lock()
+	w.syncFile()
+	w.mu.Unlock()
+}
+
+// syncFile fsync 当前文件，调用方需持有锁
+func (w *FileWriter) syncFile() {
+	w.flushBuffer()
+	w.unsynced = 0
+	if err := w.file.Sync(); err != nil {
+		err = fmt.Errorf("sync file path:%s fail:%w", w.filePath, err)
+		w.writeFailed(err)
+		w.onError(err)
+	}
+}
+
+// syncBeforeClose 轮转关闭文件前写入缓冲，配置了 fsync 策略时再 fsync，调用方需持有锁
+func (w *FileWriter) syncBeforeClose() {
+	w.flushBuffer()
+	if w.syncPolicy.mode != syncNever {
+		w.syncFile()
+	}
+}
+
+// syncLoop SyncEvery 策略下定时 fsync，期间没有新日志时跳过
+func (w *FileWriter) syncLoop() {
+	ticker := w.clock.NewTicker(w.syncPolicy.d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+		case <-w.done:
+			return
+		}
+		w.lock()
+		if w.unsynced > 0 {
+			w.syncFile()
+		}
+		w.mu.Unlock()
+	}
+}
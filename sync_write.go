@@ -0,0 +1,176 @@
+package h2sanlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+// ErrSyncUnsupported Synced 的输出不支持同步写入，日志没有写出
+var ErrSyncUnsupported = errors.New("h2sanlog: output does not support synchronous writes")
+
+// SyncWriter 同步写入：不经过异步队列，写入并 fsync 后才返回，队列满时也不会丢。
+// MultiWriter、EncryptWriter、FailoverWriter、AuditWriter 转发给下层 writer 的同步写入，下层不支持时返回 ErrSyncUnsupported
+type SyncWriter interface {
+	WriteSync(p []byte) (int, error)
+}
+
+// writeSync 同步写入 w：w 实现 SyncWriter 时调用 WriteSync，*os.File 写入后对普通文件 fsync，其他返回 ErrSyncUnsupported
+func writeSync(w io.Writer, p []byte) (int, error) {
+	switch w := w.(type) {
+	case SyncWriter:
+		return w.WriteSync(p)
+	case *os.File:
+		n, err := w.Write(p)
+		if err != nil {
+			return n, err
+		}
+		// 终端、管道不支持 fsync，写入即已交给对端
+		if fi, err := w.Stat(); err == nil && fi.Mode().IsRegular() {
+			err = w.Sync()
+		}
+		return n, err
+	}
+	return 0, ErrSyncUnsupported
+}
+
+// plainWrite 普通写入，与 writeSync 对应，供同时实现 Write 和 WriteSync 的 writer 共用写入逻辑
+func plainWrite(w io.Writer, p []byte) (int, error) {
+	return w.Write(p)
+}
+
+// WriteSync 实现 SyncWriter，直接写当前日志文件并 fsync。
+// 与 Write 一样按 WithMaxEntryBytes 截断或拒绝，磁盘空间不足时返回 ErrDiskFull；
+// 不等待队列中更早的异步日志，两者在文件中的先后顺序不保证
+func (w *FileWriter) WriteSync(p []byte) (int, error) {
+	if atomic.LoadInt32(&w.closed) == 1 {
+		return 0, ErrClosed
+	}
+	total := len(p)
+	if n := w.entryLen(total); n < total {
+		if !w.truncateEntry {
+			atomic.AddUint64(&w.counters.dropped, 1)
+			return 0, ErrEntryTooLarge
+		}
+		// 限制容量，追加标记时复制而不改写调用方的 p
+		p = appendTruncated(p[:n:n], total-n)
+	}
+	if w.atomicReject(len(p)) {
+		atomic.AddUint64(&w.counters.dropped, 1)
+		return 0, ErrEntryTooLarge
+	}
+	if atomic.LoadInt32(&w.diskFull) == 1 {
+		atomic.AddUint64(&w.counters.dropped, 1)
+		return 0, ErrDiskFull
+	}
+	w.lock()
+	w.sharedLock()
+	p = w.stamp(p)
+	w.maybeRotate(len(p))
+	n, err := w.writeActive(p)
+	w.size += int64(n)
+	if n > total || err == nil {
+		// 按调用方传入的字节数返回，不含 WithWriteTime 加上的时间和截断标记
+		n = total
+	}
+	if err == nil && w.buf != nil {
+		err = w.buf.Flush()
+	}
+	if err == nil {
+		err = w.file.Sync()
+	} else {
+		w.resetBuffer()
+	}
+	if err == nil {
+		w.lastWrite = w.now()
+		if w.firstWrite.IsZero() {
+			w.firstWrite = w.lastWrite
+		}
+	}
+	w.unlockFile()
+	w.mu.Unlock()
+	if err != nil {
+		err = fmt.Errorf("sync write file path:%s fail:%w", w.filePath, err)
+		w.writeFailed(err)
+		w.onError(err)
+		return n, err
+	}
+	atomic.AddUint64(&w.counters.written, 1)
+	w.wrote()
+	return n, nil
+}
+
+// WriteSync 实现 SyncWriter，按行首的 [LEVEL] 标签选择文件
+func (s *SplitWriter) WriteSync(p []byte) (int, error) {
+	return s.writerFor(sniffLevel(p)).WriteSync(p)
+}
+
+// WriteEntrySync 同步写入结构化日志，见 FileWriter.WriteSync
+func (s *SplitWriter) WriteEntrySync(e *Entry) error {
+	data, err := s.enc.Encode(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.writerFor(e.Level).WriteSync(data)
+	return err
+}
+
+// syncOutput 把 Write 转为 WriteSync
+type syncOutput struct{ w SyncWriter }
+
+func (s syncOutput) Write(p []byte) (int, error) { return s.w.WriteSync(p) }
+
+// syncEntryOutput 把 Write/WriteEntry 转为同步写入
+type syncEntryOutput struct {
+	syncOutput
+	ew interface{ WriteEntrySync(e *Entry) error }
+}
+
+func (s syncEntryOutput) WriteEntry(e *Entry) error { return s.ew.WriteEntrySync(e) }
+
+// syncUnsupported 不支持同步写入的输出，Write 总是返回 ErrSyncUnsupported，不会退化为异步写入
+type syncUnsupported struct{ w io.Writer }
+
+func (s syncUnsupported) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("%T: %w", s.w, ErrSyncUnsupported)
+}
+
+// syncOf 返回 w 的同步写入版本，w 不支持同步写入时返回总是失败的 writer
+func syncOf(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok {
+		return syncOutput{syncFile{f}}
+	}
+	sw, ok := w.(SyncWriter)
+	if !ok {
+		return syncUnsupported{w}
+	}
+	if ew, ok := w.(interface{ WriteEntrySync(e *Entry) error }); ok {
+		return syncEntryOutput{syncOutput{sw}, ew}
+	}
+	return syncOutput{sw}
+}
+
+// syncFile 适配 *os.File，见 writeSync
+type syncFile struct{ f *os.File }
+
+func (s syncFile) WriteSync(p []byte) (int, error) { return writeSync(s.f, p) }
+
+// Synced 返回同步写入的子logger，用于审计、安全等不能丢的日志：写入并 fsync 后才返回，不经过异步队列，
+// 也不受采样和限流影响；写入失败总是返回错误。输出（含路由命中的 sink）需实现 SyncWriter 或为 *os.File，
+// 否则每条日志都返回 ErrSyncUnsupported，创建时也会输出到 stderr，不会悄悄退化为异步写入。
+// 子logger 使用调用时父logger的输出、前缀和 flag
+func (l *Logger) Synced() *Logger {
+	c := l.clone()
+	c.sync = true
+	c.strict = true
+	out := syncOf(l.Writer())
+	if _, ok := out.(syncUnsupported); ok {
+		stderrError(fmt.Errorf("Synced: output %T: %w", l.Writer(), ErrSyncUnsupported))
+	}
+	c.Logger = log.New(out, l.Prefix(), l.Flags())
+	c.SetRouter(l.router)
+	return c
+}
@@ -0,0 +1,108 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncedDeliversBeforeReturn(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	cases := map[string]func(w *FileWriter) io.Writer{
+		"file":     func(w *FileWriter) io.Writer { return w },
+		"multi":    func(w *FileWriter) io.Writer { return NewMultiWriter().Add(w, JSONEncoder{}) },
+		"failover": func(w *FileWriter) io.Writer { return NewFailoverWriter(w, os.Stderr) },
+		"audit":    func(w *FileWriter) io.Writer { return NewAuditWriter(w, key, 0) },
+		"encrypt": func(w *FileWriter) io.Writer {
+			e, err := NewEncryptWriter(w, testKey(32))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return e
+		},
+	}
+	for name, wrap := range cases {
+		w := newTestWriter(t, 0, 0, WithBatch(1<<20, 0))
+		l := New(wrap(w), "", 0).Synced()
+		if err := l.Info("audit event"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// 不调用 Flush：批量写的队列里不应有这条日志
+		if activeContent(t, w) == "" {
+			t.Fatalf("%s: not written when Info returned", name)
+		}
+	}
+}
+
+func TestSyncedOSFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := New(f, "", 0).Synced().Info("x"); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(b), "x") {
+		t.Fatalf("got %q", b)
+	}
+}
+
+func TestSyncedUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	for name, out := range map[string]io.Writer{
+		"buffer": &buf,
+		"multi":  NewMultiWriter().Add(&buf, JSONEncoder{}),
+	} {
+		err := New(out, "", 0).Synced().Info("x")
+		if !errors.Is(err, ErrSyncUnsupported) {
+			t.Fatalf("%s: err = %v, want ErrSyncUnsupported", name, err)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("written although sync unsupported: %q", buf.String())
+	}
+}
+
+func TestWriteSyncEntryLimits(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithMaxEntryBytes(4, false))
+	if _, err := w.WriteSync([]byte("123456\n")); !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("err = %v, want ErrEntryTooLarge", err)
+	}
+	if got := activeContent(t, w); got != "" {
+		t.Fatalf("written although too large: %q", got)
+	}
+
+	w = newTestWriter(t, 0, 0, WithMaxEntryBytes(4, true))
+	p := make([]byte, 7, 64)
+	copy(p, "123456\n")
+	n, err := w.WriteSync(p)
+	if err != nil || n != 7 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if got := activeContent(t, w); got != "1234... [truncated 3 bytes]\n" {
+		t.Fatalf("got %q", got)
+	}
+	if string(p) != "123456\n" || p[:8][7] != 0 {
+		t.Fatal("caller's buffer modified")
+	}
+}
+
+func TestWriteSyncDiskFull(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithDiskGuard(math.MaxUint64, false), WithOnError(func(error) {}))
+	if _, err := w.WriteSync([]byte("x\n")); !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("err = %v, want ErrDiskFull", err)
+	}
+	if st := w.Stats(); st.Dropped != 1 {
+		t.Fatalf("Dropped = %d", st.Dropped)
+	}
+}
@@ -0,0 +1,145 @@
+package h2sanlog
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// tailPoll Tailer 读到文件末尾后检查新内容和轮转的间隔
+const tailPoll = 200 * time.Millisecond
+
+// Tailer 类似 tail -F 持续读取当前日志文件，按天轮转和按大小轮转后自动切到新文件，
+// 切换前先读完旧文件剩余内容；同 tail -F，两次检查（200ms）之间轮转多次时跳过中间的文件。
+// 实现 io.ReadCloser，可配合 bufio.Scanner 按行读取
+type Tailer struct {
+	resolve func() string
+	done    chan struct{}
+	once    sync.Once
+
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// Follow 跟随 w 正在写的文件，fromStart 为 false 时从当前末尾开始
+func (w *FileWriter) Follow(fromStart bool) (*Tailer, error) {
+	return newTailer(func() string {
+		w.lock()
+		defer w.mu.Unlock()
+		return w.filePath
+	}, fromStart)
+}
+
+// NewTailer 在其他进程（如 sidecar）中跟随 fileName 的日志，dailyDirs 和 loc 需与写入方的 WithDailyDirs、WithLocation 一致，
+// loc 为 nil 时使用本地时区；写入方使用 WithDateDirs、WithFilePattern 时在 layout 中传入同样的选项，其余选项不起作用
+func NewTailer(fileName string, dailyDirs bool, loc *time.Location, fromStart bool, layout ...FileOption) (*Tailer, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	w := &FileWriter{fileName: fileName}
+	if dailyDirs {
+		w.layout = dailyLayout
+	}
+	for _, opt := range layout {
+		opt(w)
+	}
+	if err := w.compilePattern(); err != nil {
+		return nil, err
+	}
+	return newTailer(func() string {
+		return w.pathAt(time.Now().In(loc))
+	}, fromStart)
+}
+
+func newTailer(resolve func() string, fromStart bool) (*Tailer, error) {
+	t := &Tailer{resolve: resolve, done: make(chan struct{})}
+	path := resolve()
+	f, err := openShared(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if f != nil && !fromStart {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	t.file, t.path = f, path
+	return t, nil
+}
+
+// Read 读取新写入的日志，没有新内容时阻塞，Close 后返回 ErrClosed
+func (t *Tailer) Read(p []byte) (int, error) {
+	for {
+		n, err := t.read(p)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+		select {
+		case <-t.done:
+			return 0, ErrClosed
+		case <-time.After(tailPoll):
+		}
+	}
+}
+
+// read 读当前文件，读到末尾时检查是否已轮转，已轮转则读完旧文件后切到新文件
+func (t *Tailer) read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.done:
+		return 0, ErrClosed
+	default:
+	}
+	if t.file != nil {
+		n, err := t.file.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+	}
+	path := t.resolve()
+	fi, err := os.Stat(path)
+	if err != nil {
+		//新文件还没创建
+		return 0, io.EOF
+	}
+	if t.file != nil {
+		cur, err := t.file.Stat()
+		if err == nil && path == t.path && os.SameFile(cur, fi) {
+			if off, _ := t.file.Seek(0, io.SeekCurrent); fi.Size() >= off {
+				return 0, io.EOF
+			}
+			//文件被截断，从头读
+			t.file.Seek(0, io.SeekStart)
+			return 0, io.EOF
+		}
+		//已轮转，读完旧文件剩余内容再切换
+		if n, _ := t.file.Read(p); n > 0 {
+			return n, nil
+		}
+		t.file.Close()
+		t.file = nil
+	}
+	f, err := openShared(path)
+	if err != nil {
+		return 0, io.EOF
+	}
+	t.file, t.path = f, path
+	return t.file.Read(p)
+}
+
+// Close 停止跟随，阻塞中的 Read 返回 ErrClosed
+func (t *Tailer) Close() error {
+	t.once.Do(func() { close(t.done) })
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file != nil {
+		err := t.file.Close()
+		t.file = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package h2sanlog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// scanTail 在后台按行读取 tl，返回读到的行
+func scanTail(tl *Tailer) <-chan string {
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(tl)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+	return lines
+}
+
+func expectLines(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-lines:
+			if got != w {
+				t.Fatalf("got %q, want %q", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", w)
+		}
+	}
+}
+
+func TestTailerFollowsRotation(t *testing.T) {
+	w := newTestWriter(t, 20, 0, WithFilePattern("app.log"))
+	w.Write([]byte("old\n"))
+	w.Flush(time.Second)
+	tl, err := w.Follow(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	lines := scanTail(tl)
+	for i := 0; i < 12; i++ {
+		// 每行 8 字节，每个文件写两行后按大小轮转；读到一行再写下一行，两次检查之间最多轮转一次
+		s := fmt.Sprintf("line-%02d", i)
+		w.Write([]byte(s + "\n"))
+		w.Flush(time.Second)
+		expectLines(t, lines, s)
+	}
+	if n := len(rotatedFiles(t, w)); n < 4 {
+		t.Fatalf("rotated files = %d", n)
+	}
+}
+
+func TestTailerFromStartAndClose(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithFilePattern("app.log"))
+	w.Write([]byte("first\n"))
+	w.Flush(time.Second)
+	tl, err := w.Follow(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := scanTail(tl)
+	expectLines(t, lines, "first")
+	tl.Close()
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Fatal("line after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read not unblocked by Close")
+	}
+	if _, err := tl.Read(make([]byte, 1)); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Read after Close: %v", err)
+	}
+}
+
+// 跟随其他进程写的文件：文件还不存在时等待创建，按模式切换到下一个文件
+func TestNewTailerWaitsForFile(t *testing.T) {
+	dir := t.TempDir()
+	tl, err := NewTailer(filepath.Join(dir, "app"), false, nil, false, WithFilePattern("app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	lines := scanTail(tl)
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.log"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectLines(t, lines, "hello")
+}
@@ -0,0 +1,113 @@
+package h2sanlog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrWriteTime WithWriteTime 按行加时间，会破坏 MsgpackEncoder 编码和 EncryptWriter 加密的输出
+var ErrWriteTime = errors.New("h2sanlog: WithWriteTime cannot stamp msgpack or encrypted output")
+
+// TimeEncoder 把日志时间追加到 b 并返回，用于 TextEncoder、JSONEncoder 的 Time 和 WithWriteTime。
+// JSONEncoder 中结果全为数字时作为 JSON 数字输出，否则作为字符串
+type TimeEncoder func(b []byte, t time.Time) []byte
+
+// RFC3339NanoTime 如 2024-05-01T10:00:00.123456789+08:00
+func RFC3339NanoTime(b []byte, t time.Time) []byte {
+	return t.AppendFormat(b, time.RFC3339Nano)
+}
+
+// EpochMillisTime Unix 毫秒时间戳
+func EpochMillisTime(b []byte, t time.Time) []byte {
+	return strconv.AppendInt(b, t.UnixMilli(), 10)
+}
+
+// EpochNanosTime Unix 纳秒时间戳
+func EpochNanosTime(b []byte, t time.Time) []byte {
+	return strconv.AppendInt(b, t.UnixNano(), 10)
+}
+
+// LayoutTime 按 time.Format 的 layout 编码
+func LayoutTime(layout string) TimeEncoder {
+	return func(b []byte, t time.Time) []byte {
+		return t.AppendFormat(b, layout)
+	}
+}
+
+// appendJSONTime 按 enc 编码时间写入 JSON，数字时间戳不加引号
+func appendJSONTime(b *bytes.Buffer, enc TimeEncoder, t time.Time) {
+	var tmp [64]byte
+	ts := enc(tmp[:0], t)
+	if len(ts) > 0 && len(bytes.Trim(ts, "0123456789")) == 0 {
+		b.Write(ts)
+		return
+	}
+	writeJSONString(b, string(ts))
+}
+
+// WithWriteTime 写盘时在每行前加上编码后的写入时间和一个空格：时间取自后台goroutine实际写入文件的时刻，
+// 而不是调用日志方法的时刻，供要求时间单调、按落盘时间解析的下游使用；
+// 一般配合不输出时间的 logger（flag 为 0）使用，否则每行会有两个时间。
+// 只用于文本和 JSON 行，NewEncryptWriter 和 MultiWriter 以 MsgpackEncoder 写入这样的 writer 时返回 ErrWriteTime
+func WithWriteTime(enc TimeEncoder) FileOption {
+	return func(w *FileWriter) {
+		w.writeTime = enc
+	}
+}
+
+// checkWriteTime w 为开启 WithWriteTime 的 FileWriter 时返回 ErrWriteTime
+func checkWriteTime(w io.Writer) error {
+	if fw, ok := w.(*FileWriter); ok && fw.writeTime != nil {
+		return ErrWriteTime
+	}
+	return nil
+}
+
+// stampBufMax 复用的加时间缓冲超过该大小时用完即释放
+const stampBufMax = 1 << 20
+
+// stampWidth 按当前时间估算 stamp 给每行加上的字节数，未开启 WithWriteTime 时为 0
+func (w *FileWriter) stampWidth() int {
+	if w.writeTime == nil {
+		return 0
+	}
+	var tmp [64]byte
+	return len(w.writeTime(tmp[:0], w.now())) + 1
+}
+
+// lineCount 返回 stamp 会在 p 中加时间的行数
+func lineCount(p []byte) int {
+	n := bytes.Count(p, []byte{'\n'})
+	if len(p) > 0 && p[len(p)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// stamp 开启 WithWriteTime 时在 p 的每行前加上写入时间，返回的切片在下一次调用前有效，调用方需持有锁
+func (w *FileWriter) stamp(p []byte) []byte {
+	if w.writeTime == nil || len(p) == 0 {
+		return p
+	}
+	now := w.now()
+	b := w.stampBuf[:0]
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+		}
+		b = w.writeTime(b, now)
+		b = append(b, ' ')
+		b = append(b, line...)
+		p = p[len(line):]
+	}
+	if cap(b) <= stampBufMax {
+		w.stampBuf = b
+	} else {
+		w.stampBuf = nil
+	}
+	return b
+}
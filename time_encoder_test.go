@@ -0,0 +1,57 @@
+package h2sanlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 批量写入时每行加上的时间计入大小，轮转出的文件不超过 maxSize
+func TestWriteTimeRotateSize(t *testing.T) {
+	const maxSize = 100
+	w := newTestWriter(t, maxSize, 0, WithWriteTime(LayoutTime("15:04:05")), WithBatch(1<<10, time.Hour))
+	for i := 0; i < 20; i++ {
+		w.Write([]byte("line-0123\n"))
+	}
+	w.Flush(time.Second)
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(w.fileName), "*"))
+	if len(files) < 2 {
+		t.Fatalf("files = %v, want rotation", files)
+	}
+	for _, f := range files {
+		st, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Size() > maxSize {
+			t.Fatalf("%s size = %d > %d", filepath.Base(f), st.Size(), maxSize)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(readFile(t, f), "\n"), "\n") {
+			if len(line) != len("15:04:05 line-0123") {
+				t.Fatalf("%s: line %q", filepath.Base(f), line)
+			}
+		}
+	}
+}
+
+func TestWriteTimeRejectsBinary(t *testing.T) {
+	w := newTestWriter(t, 0, 0, WithWriteTime(EpochMillisTime))
+	if _, err := NewEncryptWriter(w, testKey(32)); !errors.Is(err, ErrWriteTime) {
+		t.Fatalf("NewEncryptWriter err = %v, want ErrWriteTime", err)
+	}
+	m := NewMultiWriter().Add(w, MsgpackEncoder{})
+	if err := m.WriteEntry(&Entry{Time: time.Now(), Level: LogLevelInfo, Message: "hello"}); !errors.Is(err, ErrWriteTime) {
+		t.Fatalf("WriteEntry err = %v, want ErrWriteTime", err)
+	}
+	w.Flush(time.Second)
+	if got := activeContent(t, w); got != "" {
+		t.Fatalf("written %q", got)
+	}
+	// 文本行照常写入
+	if err := NewMultiWriter().Add(w, TextEncoder{}).WriteEntry(&Entry{Time: time.Now(), Level: LogLevelInfo, Message: "text"}); err != nil {
+		t.Fatal(err)
+	}
+}
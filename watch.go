@@ -0,0 +1,60 @@
+package h2sanlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// errWatchUnsupported 当前平台不支持监听文件删除
+var errWatchUnsupported = errors.New("file watch not supported on this platform")
+
+// pollInterval 不支持监听时检查日志文件是否被删除的间隔
+var pollInterval = time.Second
+
+// startWatch 开始监听当前日志文件，创建失败时 check 退回轮询
+func (w *FileWriter) startWatch() {
+	wt, err := newDirWatcher()
+	if err != nil {
+		if err != errWatchUnsupported {
+			w.onError(fmt.Errorf("watch file path:%s fail:%w, fall back to polling", w.filePath, err))
+		}
+		return
+	}
+	w.watcher = wt
+	w.watchActive()
+}
+
+// watchActive 当前日志文件路径或目录变化后重新监听，调用方需持有锁
+func (w *FileWriter) watchActive() {
+	if w.watcher == nil {
+		return
+	}
+	if err := w.watcher.watch(w.filePath); err != nil {
+		w.onError(fmt.Errorf("watch file path:%s fail:%w", w.filePath, err))
+	}
+}
+
+// recreate 当前日志文件已被删除时重新创建，返回是否发生了重建，调用方需持有锁
+func (w *FileWriter) recreate() bool {
+	_, err := os.Stat(w.filePath)
+	if !isDeleted(err) {
+		return false
+	}
+	//日志已被误删除，重新创建新日志文件
+	if closeBeforeReopen {
+		w.file.Close()
+	}
+	file, e := w.openFile(w.filePath)
+	if e == nil {
+		if !closeBeforeReopen {
+			w.file.Close()
+		}
+		w.setActive(file)
+	} else {
+		w.degrade("recreate_failed", fmt.Errorf("recreate file path:%s fail:%w", w.filePath, e))
+	}
+	w.watchActive()
+	return true
+}
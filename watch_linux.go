@@ -0,0 +1,108 @@
+package h2sanlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// dirWatcher 用 inotify 监听当前日志文件所在目录，日志文件被删除或移走时通知 check 立即重建
+type dirWatcher struct {
+	// fd 原始 inotify fd，不能用 f.Fd()，它会把 fd 改回阻塞模式
+	fd     int
+	f      *os.File
+	events chan struct{}
+
+	mu     sync.Mutex
+	wd     int
+	dir    string
+	base   string
+	closed bool
+}
+
+// watchMask 目录中文件被删除、移走，或目录本身被删除、移走
+const watchMask = syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+func newDirWatcher() (*dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// 非阻塞 fd 交给 runtime poller，Close 时 Read 立即返回
+	d := &dirWatcher{fd: fd, f: os.NewFile(uintptr(fd), "inotify"), events: make(chan struct{}, 1), wd: -1}
+	go d.read()
+	return d, nil
+}
+
+// watch 监听 path 所在目录，目录未变且监听仍有效时不重复添加
+func (d *dirWatcher) watch(path string) error {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.base = base
+	if d.wd >= 0 && d.dir == dir {
+		return nil
+	}
+	if d.wd >= 0 {
+		syscall.InotifyRmWatch(d.fd, uint32(d.wd))
+		d.wd = -1
+	}
+	wd, err := syscall.InotifyAddWatch(d.fd, dir, watchMask)
+	if err != nil {
+		return err
+	}
+	d.wd, d.dir = wd, dir
+	return nil
+}
+
+// read 读取 inotify 事件，只关心当前日志文件和目录本身
+func (d *dirWatcher) read() {
+	var buf [4096]byte
+	for {
+		n, err := d.f.Read(buf[:])
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			start := off + syscall.SizeofInotifyEvent
+			off = start + int(ev.Len)
+			name := ""
+			if ev.Len > 0 && off <= n {
+				name = strings.TrimRight(string(buf[start:off]), "\x00")
+			}
+			d.handle(int(ev.Wd), ev.Mask, name)
+		}
+	}
+}
+
+func (d *dirWatcher) handle(wd int, mask uint32, name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if wd != d.wd {
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		// 目录已被删除，重建文件后由 watch 重新添加
+		d.wd = -1
+	} else if mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) == 0 && name != d.base {
+		return
+	}
+	select {
+	case d.events <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dirWatcher) close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	d.f.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package h2sanlog
+
+// dirWatcher 当前平台不支持 inotify，check 退回轮询
+type dirWatcher struct {
+	events chan struct{}
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	return nil, errWatchUnsupported
+}
+
+func (d *dirWatcher) watch(path string) error { return nil }
+
+func (d *dirWatcher) close() {}
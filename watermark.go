@@ -0,0 +1,62 @@
+package h2sanlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// QueueEvent 写入队列越过水位线
+type QueueEvent struct {
+	// File NewFileWriter 的 fileName
+	File string
+	// High 为 true 时队列长度升到高水位，为 false 时回落到低水位
+	High bool
+	Len  int
+	Cap  int
+	Time time.Time
+}
+
+// WithQueueWatermarks 队列长度达到容量的 high（如 0.8）时回调一次 High 事件，之后回落到 low（如 0.5）以下时回调一次恢复事件，
+// 应用可以在开始丢日志之前自行降载或告警；fn 在新的goroutine中调用，不阻塞写日志
+func WithQueueWatermarks(high, low float64, fn func(QueueEvent)) FileOption {
+	return func(w *FileWriter) {
+		w.highWater, w.lowWater = high, low
+		w.onWatermark = fn
+	}
+}
+
+// initWatermarks 按队列容量换算水位线，队列确定后调用
+func (w *FileWriter) initWatermarks() {
+	if w.onWatermark == nil {
+		return
+	}
+	c := float64(w.queue.cap())
+	w.highMark = int(w.highWater * c)
+	if w.highMark < 1 {
+		w.highMark = 1
+	}
+	w.lowMark = int(w.lowWater * c)
+	if w.lowMark >= w.highMark {
+		w.lowMark = w.highMark - 1
+	}
+}
+
+// checkHighWater 入队后检查是否升到高水位
+func (w *FileWriter) checkHighWater() {
+	if w.onWatermark == nil || atomic.LoadInt32(&w.aboveHigh) == 1 {
+		return
+	}
+	if n := w.queue.len(); n >= w.highMark && atomic.CompareAndSwapInt32(&w.aboveHigh, 0, 1) {
+		go w.onWatermark(QueueEvent{File: w.fileName, High: true, Len: n, Cap: w.queue.cap(), Time: time.Now()})
+	}
+}
+
+// checkLowWater 消费者写盘后检查是否回落到低水位
+func (w *FileWriter) checkLowWater() {
+	if w.onWatermark == nil || atomic.LoadInt32(&w.aboveHigh) == 0 {
+		return
+	}
+	if n := w.queue.len(); n <= w.lowMark && atomic.CompareAndSwapInt32(&w.aboveHigh, 1, 0) {
+		go w.onWatermark(QueueEvent{File: w.fileName, Len: n, Cap: w.queue.cap(), Time: time.Now()})
+	}
+}
@@ -0,0 +1,41 @@
+// zapadapter 把 h2sanlog 的 FileWriter 接入 zap，复用其异步写盘和轮转
+package zapadapter
+
+import (
+	"github.com/h2san/h2sanlog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewCore 返回写入 w 的 zapcore.Core，enc 为 nil 时使用 zap 默认的生产环境 JSON encoder。
+// zap 在 Fatal/Panic 级别写入后调用 Sync，由 FileWriter 负责写盘并 fsync
+func NewCore(w *h2sanlog.FileWriter, enc zapcore.Encoder, level zapcore.LevelEnabler) zapcore.Core {
+	if enc == nil {
+		enc = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	}
+	return zapcore.NewCore(enc, w, level)
+}
+
+// New 创建 FileWriter 并返回写入它的 zap.Logger，logger 不再使用时调用 FileWriter.Close
+func New(fileName string, maxSize int64, maxNum int, level zapcore.LevelEnabler, opts ...h2sanlog.FileOption) (*zap.Logger, *h2sanlog.FileWriter, error) {
+	w, err := h2sanlog.NewFileWriter(fileName, maxSize, maxNum, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zap.New(NewCore(w, nil, level)), w, nil
+}
+
+// Level 把 h2sanlog 的级别转换为 zap 的级别，Trace 对应 Debug
+func Level(level uint8) zapcore.Level {
+	switch level {
+	case h2sanlog.LogLevelNull, h2sanlog.LogLevelTrace, h2sanlog.LogLevelDebug:
+		return zapcore.DebugLevel
+	case h2sanlog.LogLevelInfo:
+		return zapcore.InfoLevel
+	case h2sanlog.LogLevelWarning:
+		return zapcore.WarnLevel
+	case h2sanlog.LogLevelError:
+		return zapcore.ErrorLevel
+	}
+	return zapcore.FatalLevel
+}